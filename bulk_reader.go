@@ -0,0 +1,172 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// bulk reader for ots2
+package goots
+
+import (
+	"context"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+const BATCH_GET_ROW_MAX_ROWS = 100 // OTS 限制了每次BatchGetRow 最多读取100 行
+
+// the position of a row in OTSBatchGetRowRequest
+type bulk_get_position struct {
+	table int
+	row   int
+}
+
+// 说明：批量读取多行数据，每个表的行数不受BatchGetRow 的限制。
+//
+// 		``batch_list``与BatchGetRow 相同，类型为``otstype.OTSBatchGetRowRequest``。
+// 		请求会按BATCH_GET_ROW_MAX_ROWS 自动切分成多次BatchGetRow；单行失败时，使用OTSClient 的RetryPolicy
+// 		判断是否可以重试，只重新读取可以重试的行。
+//
+// 		返回：对应行的结果列表，与BatchGetRow 相同，结果的顺序与请求中表和行的顺序一一对应；
+// 		      重试后仍然失败的行IsOk 为false，可以通过GetErrorCode 和GetErrorMessage 获取错误信息。
+// 		      错误信息，整个BatchGetRow 请求失败时返回，此时结果为nil；
+// 		      重试等待时ctx 被取消或超时返回包含ctx.Err() 的错误，同时返回已读取的结果，等待重试的行保留最后一次的失败结果。
+//
+// 		示例：
+//
+// 		rows := OTSPrimaryKeyRows{}
+// 		for i := 0; i < 1000; i++ {
+// 			rows = append(rows, OTSPrimaryKey{"gid": i, "uid": i})
+// 		}
+// 		batch_list := &OTSBatchGetRowRequest{
+// 			{
+// 				TableName:    "myTable",
+// 				Rows:         rows,
+// 				ColumnsToGet: OTSColumnsToGet{"name", "address", "age"},
+// 			},
+// 		}
+// 		response_rows_list, ots_err := ots_client.BulkGetRow(batch_list)
+//
+func (o *OTSClient) BulkGetRow(batch_list *OTSBatchGetRowRequest) (response_rows_list *OTSBatchGetRowResponse, err *OTSError) {
+	return o.BulkGetRowWithContext(context.Background(), batch_list)
+}
+
+// 说明：同BulkGetRow，ctx用于控制所有请求（包括重试等待）的超时和取消。
+func (o *OTSClient) BulkGetRowWithContext(ctx context.Context, batch_list *OTSBatchGetRowRequest) (response_rows_list *OTSBatchGetRowResponse, err *OTSError) {
+	if batch_list == nil {
+		return nil, new(OTSError).SetClientMessage("[BulkGetRow] batch_list should not be nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	retry_policy := o.RetryPolicy
+	if retry_policy == nil {
+		retry_policy = OTSNoRetryPolicy
+	}
+
+	// results aligned with the request
+	response_rows_list = &OTSBatchGetRowResponse{
+		Tables: make([]*OTSTableInBatchGetRowResponseItem, len(*batch_list)),
+	}
+	pending := []bulk_get_position{}
+	for i, table_item := range *batch_list {
+		response_rows_list.Tables[i] = &OTSTableInBatchGetRowResponseItem{
+			TableName: table_item.TableName,
+			Rows:      make([]*OTSRowInBatchGetRowResponseItem, len(table_item.Rows)),
+		}
+		for j := range table_item.Rows {
+			pending = append(pending, bulk_get_position{i, j})
+		}
+	}
+
+	retry_times := 0
+	for len(pending) > 0 {
+		retry_rows := []bulk_get_position{}
+		var retry_err *OTSServiceError
+
+		for len(pending) > 0 {
+			n := len(pending)
+			if n > BATCH_GET_ROW_MAX_ROWS {
+				n = BATCH_GET_ROW_MAX_ROWS
+			}
+			chunk := pending[:n]
+			pending = pending[n:]
+
+			request, positions := _make_bulk_get_request(batch_list, chunk)
+			response, ots_err := o.BatchGetRowWithContext(ctx, request)
+			if ots_err != nil {
+				return nil, ots_err
+			}
+
+			for k, position := range chunk {
+				result := _get_bulk_get_result(response, positions[k])
+				if result == nil {
+					result = &OTSRowInBatchGetRowResponseItem{
+						ErrorMessage: "row result not found in response",
+					}
+				}
+				response_rows_list.Tables[position.table].Rows[position.row] = result
+				if result.IsOk || result.GetErrorCode() == "" {
+					continue
+				}
+
+				row_err := new(OTSServiceError).SetErrorCode(result.GetErrorCode()).SetErrorMessage("%s", result.GetErrorMessage())
+				if retry_policy.ShouldRetry(retry_times, row_err, "BatchGetRow") {
+					retry_rows = append(retry_rows, position)
+					retry_err = row_err
+				}
+			}
+		}
+
+		if len(retry_rows) == 0 {
+			break
+		}
+		retry_delay := retry_policy.GetRetryDelay(retry_times, retry_err, "BatchGetRow")
+		if ctx_err := _retry_sleep(ctx, retry_delay); ctx_err != nil {
+			// the failed results of the last try are kept
+			return response_rows_list, new(OTSError).SetServiceError(_set_context_error(retry_err, ctx_err))
+		}
+		retry_times += 1
+		pending = retry_rows
+	}
+
+	return response_rows_list, nil
+}
+
+// make a legal BatchGetRow request from the rows of chunk, and the position
+// of every row in the new request
+func _make_bulk_get_request(batch_list *OTSBatchGetRowRequest, chunk []bulk_get_position) (*OTSBatchGetRowRequest, []bulk_get_position) {
+	request := OTSBatchGetRowRequest{}
+	positions := make([]bulk_get_position, len(chunk))
+	tables := map[int]int{}
+
+	for k, position := range chunk {
+		t, ok := tables[position.table]
+		if !ok {
+			t = len(request)
+			tables[position.table] = t
+			table_item := (*batch_list)[position.table]
+			request = append(request, OTSTableInBatchGetRowRequestItem{
+				TableName:          table_item.TableName,
+				ColumnsToGet:       table_item.ColumnsToGet,
+				Filter:             table_item.Filter,
+				SchemaOfPrimaryKey: table_item.SchemaOfPrimaryKey,
+			})
+		}
+
+		positions[k] = bulk_get_position{t, len(request[t].Rows)}
+		request[t].Rows = append(request[t].Rows, (*batch_list)[position.table].Rows[position.row])
+	}
+
+	return &request, positions
+}
+
+func _get_bulk_get_result(response *OTSBatchGetRowResponse, position bulk_get_position) *OTSRowInBatchGetRowResponseItem {
+	if response == nil || position.table >= len(response.Tables) || response.Tables[position.table] == nil {
+		return nil
+	}
+	if position.row >= len(response.Tables[position.table].Rows) {
+		return nil
+	}
+
+	return response.Tables[position.table].Rows[position.row]
+}
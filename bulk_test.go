@@ -0,0 +1,448 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test bulk writer and reader for ots2
+package goots
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
+)
+
+func Test_make_bulk_write_request(t *testing.T) {
+	rows := []bulk_write_row{
+		{"tableA", OTSBulkOperation_PUT, OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSPrimaryKey{"gid": 1}}},
+		{"tableB", OTSBulkOperation_DELETE, OTSDeleteRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSPrimaryKey{"gid": 2}}},
+		{"tableA", OTSBulkOperation_UPDATE, OTSUpdateRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSPrimaryKey{"gid": 3}}},
+		{"tableA", OTSBulkOperation_PUT, OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSPrimaryKey{"gid": 4}}},
+	}
+
+	batch_list, positions := _make_bulk_write_request(rows)
+	if len(*batch_list) != 2 || (*batch_list)[0].TableName != "tableA" || (*batch_list)[1].TableName != "tableB" {
+		t.Fatalf("unexpected batch_list: %v", batch_list)
+	}
+	if len((*batch_list)[0].PutRows) != 2 || len((*batch_list)[0].UpdateRows) != 1 || len((*batch_list)[1].DeleteRows) != 1 {
+		t.Fatalf("unexpected batch_list: %v", batch_list)
+	}
+
+	response := &OTSBatchWriteRowResponse{
+		Tables: []*OTSTableInBatchWriteRowResponseItem{
+			{
+				TableName:  "tableA",
+				PutRows:    []*OTSRowInBatchWriteRowResponseItem{{IsOk: true}, {ErrorCode: "OTSConditionCheckFail"}},
+				UpdateRows: []*OTSRowInBatchWriteRowResponseItem{{IsOk: true}},
+			},
+			{
+				TableName:  "tableB",
+				DeleteRows: []*OTSRowInBatchWriteRowResponseItem{{ErrorCode: "OTSServerBusy"}},
+			},
+		},
+	}
+	expected := []string{"", "OTSServerBusy", "", "OTSConditionCheckFail"}
+	for i, v := range expected {
+		result := _get_bulk_write_result(response, positions[i])
+		if result == nil || result.GetErrorCode() != v {
+			t.Errorf("result of row %d excpected %q but %v", i, v, result)
+		}
+	}
+}
+
+func Test_get_bulk_batch_rows(t *testing.T) {
+	put := func(table_name string, primary_key OTSPrimaryKeyInterface, attribute_columns OTSAttribute) bulk_write_row {
+		return bulk_write_row{table_name, OTSBulkOperation_PUT, OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: primary_key, AttributeColumns: attribute_columns}}
+	}
+
+	// at most max_batch_rows rows
+	rows := []bulk_write_row{put("tableA", &OTSPrimaryKey{"gid": 1}, nil), put("tableA", &OTSPrimaryKey{"gid": 2}, nil), put("tableA", &OTSPrimaryKey{"gid": 3}, nil)}
+	if n := _get_bulk_batch_rows(rows, 2); n != 2 {
+		t.Fatalf("excpected %d rows but %d", 2, n)
+	}
+	if n := _get_bulk_batch_rows(rows, 3); n != 3 {
+		t.Fatalf("excpected %d rows but %d", 3, n)
+	}
+
+	// a repeated primary key starts a new batch, the same primary key in another table does not
+	rows = []bulk_write_row{
+		put("tableA", &OTSPrimaryKey{"gid": 1, "uid": "a"}, nil),
+		put("tableB", OTSPrimaryKey{"gid": 1, "uid": "a"}, nil),
+		{"tableA", OTSBulkOperation_DELETE, OTSDeleteRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSOrderedPrimaryKey{{K: "uid", V: "a"}, {K: "gid", V: int64(1)}}}},
+	}
+	if n := _get_bulk_batch_rows(rows, 3); n != 2 {
+		t.Fatalf("excpected %d rows but %d", 2, n)
+	}
+	rows[2] = put("tableA", &OTSPrimaryKey{"gid": 1, "uid": []byte("a")}, nil)
+	if n := _get_bulk_batch_rows(rows, 3); n != 3 {
+		t.Fatalf("excpected %d rows but %d", 3, n)
+	}
+
+	// at most BATCH_WRITE_ROW_MAX_SIZE bytes, a larger row is written alone
+	value := strings.Repeat("a", BATCH_WRITE_ROW_MAX_SIZE/3)
+	rows = []bulk_write_row{
+		put("tableA", &OTSPrimaryKey{"gid": 1}, OTSAttribute{"value": value}),
+		put("tableA", &OTSPrimaryKey{"gid": 2}, OTSAttribute{"value": value}),
+		put("tableA", &OTSPrimaryKey{"gid": 3}, OTSAttribute{"value": value}),
+	}
+	if n := _get_bulk_batch_rows(rows, 3); n != 2 {
+		t.Fatalf("excpected %d rows but %d", 2, n)
+	}
+	rows[0] = put("tableA", &OTSPrimaryKey{"gid": 1}, OTSAttribute{"value": value + value + value})
+	if n := _get_bulk_batch_rows(rows, 3); n != 1 {
+		t.Fatalf("excpected %d rows but %d", 1, n)
+	}
+}
+
+// the INTEGER column name of columns
+func test_column_int(columns []*Column, name string) int64 {
+	for _, column := range columns {
+		if column.GetName() == name {
+			return column.GetValue().GetVInt()
+		}
+	}
+	return 0
+}
+
+// a test server of BatchWriteRow, row_error returns the error of each row by its uid,
+// the age of the put rows are saved in ages by uid
+func newWithBatchWriteServer(t *testing.T, row_error func(uid int64) (string, string), ages map[int64]int64) (*OTSClient, *httptest.Server, *int32) {
+	var lock sync.Mutex
+	requests := int32(0)
+	client, server := newWithTestServer(t, func(api_name string, body []byte) (int, proto.Message) {
+		request := &BatchWriteRowRequest{}
+		if err := proto.Unmarshal(body, request); err != nil || api_name != "BatchWriteRow" {
+			return test_error(400, "OTSParameterInvalid", "Invalid request.")
+		}
+		atomic.AddInt32(&requests, 1)
+
+		lock.Lock()
+		defer lock.Unlock()
+		result := func(primary_key []*Column) *RowInBatchWriteRowResponse {
+			if code, message := row_error(test_column_int(primary_key, "uid")); code != "" {
+				return &RowInBatchWriteRowResponse{IsOk: proto.Bool(false), Error: &Error{Code: proto.String(code), Message: proto.String(message)}}
+			}
+			return &RowInBatchWriteRowResponse{IsOk: proto.Bool(true), Consumed: test_consumed(0, 1)}
+		}
+		response := &BatchWriteRowResponse{}
+		for _, table := range request.Tables {
+			table_response := &TableInBatchWriteRowResponse{TableName: table.TableName}
+			for _, row := range table.PutRows {
+				row_response := result(row.PrimaryKey)
+				if row_response.GetIsOk() && ages != nil {
+					ages[test_column_int(row.PrimaryKey, "uid")] = test_column_int(row.AttributeColumns, "age")
+				}
+				table_response.PutRows = append(table_response.PutRows, row_response)
+			}
+			for _, row := range table.UpdateRows {
+				table_response.UpdateRows = append(table_response.UpdateRows, result(row.PrimaryKey))
+			}
+			for _, row := range table.DeleteRows {
+				table_response.DeleteRows = append(table_response.DeleteRows, result(row.PrimaryKey))
+			}
+			response.Tables = append(response.Tables, table_response)
+		}
+		return 200, response
+	})
+
+	return client, server, &requests
+}
+
+func Test_bulk_writer_retry(t *testing.T) {
+	// uid%3 == 0 fails once with a retriable error, uid%3 == 1 fails with a permanent error,
+	// uid == 2 fails until the retry times are used up
+	submitted := map[int64]int{}
+	client, server, _ := newWithBatchWriteServer(t, func(uid int64) (string, string) {
+		submitted[uid] += 1
+		switch {
+		case uid == 2:
+			return "OTSServerBusy", "Server is busy."
+		case uid%3 == 0 && submitted[uid] == 1:
+			return "OTSServerBusy", "Server is busy."
+		case uid%3 == 1:
+			return "OTSConditionCheckFail", "Condition check failed."
+		}
+		return "", ""
+	}, nil)
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+
+	writer := client.NewBulkWriter(0, -1)
+	for i := 0; i < 9; i++ {
+		writer.Put("myTable", OTSPutRowItem{
+			Condition:        OTSCondition_IGNORE,
+			PrimaryKey:       OTSPrimaryKey{"gid": 1, "uid": i},
+			AttributeColumns: OTSAttribute{"index": i},
+		})
+	}
+	if ots_err := writer.Close(); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("Close should report the failed rows: %v", ots_err)
+	}
+
+	// only the retriable rows are re-submitted
+	for uid, times := range submitted {
+		excpected := 1
+		if uid == 2 {
+			excpected = OTSNoDelayRetryPolicy.MaxRetryTimes + 1
+		} else if uid%3 == 0 {
+			excpected = 2
+		}
+		if times != excpected {
+			t.Errorf("row %d excpected to be submitted %d times but %d", uid, excpected, times)
+		}
+	}
+
+	// the permanent failures and the rows out of retry times are reported
+	failures := writer.Failures()
+	if len(failures) != 4 {
+		t.Fatalf("excpected %d failures but %d", 4, len(failures))
+	}
+	for _, failure := range failures {
+		item, ok := failure.Item.(OTSPutRowItem)
+		if !ok || failure.TableName != "myTable" || failure.Operation != OTSBulkOperation_PUT || failure.Err != nil {
+			t.Fatalf("unexpected failure: %v", failure)
+		}
+		uid := item.PrimaryKey.(OTSPrimaryKey)["uid"].(int)
+		if (uid == 2 && failure.ErrorCode != "OTSServerBusy") || (uid%3 == 1 && failure.ErrorCode != "OTSConditionCheckFail") || (uid != 2 && uid%3 != 1) {
+			t.Errorf("unexpected failure of row %d: %s", uid, failure.ErrorCode)
+		}
+	}
+	if len(writer.Failures()) != 0 {
+		t.Fatal("Failures should be cleared after returned")
+	}
+}
+
+func Test_bulk_writer_flush(t *testing.T) {
+	ages := map[int64]int64{}
+	client, server, requests := newWithBatchWriteServer(t, func(uid int64) (string, string) { return "", "" }, ages)
+	defer server.Close()
+
+	put := func(writer *OTSBulkWriter, uid int) {
+		if ots_err := writer.Put("myTable", OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: OTSPrimaryKey{"gid": 1, "uid": uid}}); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+
+	// flush when the buffered rows reach max_batch_rows
+	writer := client.NewBulkWriter(3, -1)
+	put(writer, 1)
+	put(writer, 2)
+	if n := atomic.LoadInt32(requests); n != 0 {
+		t.Fatalf("excpected %d requests but %d", 0, n)
+	}
+	put(writer, 3)
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Fatalf("excpected %d requests but %d", 1, n)
+	}
+	put(writer, 4)
+	if ots_err := writer.Close(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Fatalf("excpected %d requests but %d", 2, n)
+	}
+	if ots_err := writer.Put("myTable", OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: OTSPrimaryKey{"gid": 1, "uid": 5}}); ots_err == nil {
+		t.Fatal("Put should fail after Close")
+	}
+
+	// the rows of the same primary key are written in order by different requests
+	atomic.StoreInt32(requests, 0)
+	writer = client.NewBulkWriter(0, -1)
+	writer.Put("myTable", OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: OTSPrimaryKey{"gid": 2, "uid": 11}, AttributeColumns: OTSAttribute{"age": 1}})
+	writer.Put("myTable", OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: OTSPrimaryKey{"gid": 2, "uid": 12}, AttributeColumns: OTSAttribute{"age": 2}})
+	writer.Put("myTable", OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: OTSPrimaryKey{"gid": 2, "uid": 11}, AttributeColumns: OTSAttribute{"age": 3}})
+	if ots_err := writer.Close(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Fatalf("excpected %d requests but %d", 2, n)
+	}
+	if ages[11] != 3 {
+		t.Fatalf("the last row should be written at last: %v", ages)
+	}
+
+	// flush every flush_interval
+	atomic.StoreInt32(requests, 0)
+	writer = client.NewBulkWriter(0, 20*time.Millisecond)
+	defer writer.Close()
+	put(writer, 6)
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(requests) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Fatalf("excpected %d requests but %d", 1, n)
+	}
+}
+
+func Test_make_bulk_get_request(t *testing.T) {
+	rows := OTSPrimaryKeyRows{}
+	for i := 0; i < 150; i++ {
+		rows = append(rows, OTSPrimaryKey{"gid": i})
+	}
+	batch_list := &OTSBatchGetRowRequest{
+		{TableName: "tableA", Rows: rows[:120], ColumnsToGet: OTSColumnsToGet{"name"}},
+		{TableName: "tableB", Rows: rows[120:]},
+	}
+
+	// the second chunk of BATCH_GET_ROW_MAX_ROWS rows crosses two tables
+	chunk := []bulk_get_position{}
+	for j := 100; j < 120; j++ {
+		chunk = append(chunk, bulk_get_position{0, j})
+	}
+	for j := 0; j < 30; j++ {
+		chunk = append(chunk, bulk_get_position{1, j})
+	}
+
+	request, positions := _make_bulk_get_request(batch_list, chunk)
+	if len(*request) != 2 || len((*request)[0].Rows) != 20 || len((*request)[1].Rows) != 30 {
+		t.Fatalf("unexpected request: %v", request)
+	}
+	if len((*request)[0].ColumnsToGet) != 1 || (*request)[1].TableName != "tableB" {
+		t.Fatalf("unexpected request: %v", request)
+	}
+	if positions[0] != (bulk_get_position{0, 0}) || positions[20] != (bulk_get_position{1, 0}) || positions[49] != (bulk_get_position{1, 29}) {
+		t.Fatalf("unexpected positions: %v", positions)
+	}
+	if (*request)[0].Rows[0]["gid"] != 100 || (*request)[1].Rows[29]["gid"] != 149 {
+		t.Fatalf("unexpected request: %v", request)
+	}
+}
+
+// a test server of BatchGetRow, the rows of gid 1 and uid 0..rows-1 exist with the INTEGER column index = uid,
+// row_error returns the error of each row by its uid
+func newWithBatchGetServer(t *testing.T, rows int64, row_error func(uid int64) (string, string)) (*OTSClient, *httptest.Server) {
+	var lock sync.Mutex
+	return newWithTestServer(t, func(api_name string, body []byte) (int, proto.Message) {
+		request := &BatchGetRowRequest{}
+		if err := proto.Unmarshal(body, request); err != nil || api_name != "BatchGetRow" {
+			return test_error(400, "OTSParameterInvalid", "Invalid request.")
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		response := &BatchGetRowResponse{}
+		for _, table := range request.Tables {
+			table_response := &TableInBatchGetRowResponse{TableName: table.TableName}
+			for _, row := range table.Rows {
+				uid := test_column_int(row.PrimaryKey, "uid")
+				if code, message := row_error(uid); code != "" {
+					table_response.Rows = append(table_response.Rows, &RowInBatchGetRowResponse{IsOk: proto.Bool(false), Error: &Error{Code: proto.String(code), Message: proto.String(message)}})
+					continue
+				}
+				row_response := &RowInBatchGetRowResponse{IsOk: proto.Bool(true), Consumed: test_consumed(1, 0), Row: &Row{}}
+				if test_column_int(row.PrimaryKey, "gid") == 1 && uid >= 0 && uid < rows {
+					row_response.Row.PrimaryKeyColumns = test_primary_key(1, uid)
+					row_response.Row.AttributeColumns = []*Column{{Name: proto.String("index"), Value: &ColumnValue{Type: ColumnType_INTEGER.Enum(), VInt: proto.Int64(uid)}}}
+				}
+				table_response.Rows = append(table_response.Rows, row_response)
+			}
+			response.Tables = append(response.Tables, table_response)
+		}
+		return 200, response
+	})
+}
+
+func Test_bulk_get_row_retry(t *testing.T) {
+	// the even rows fail once with a retriable error, row 7 fails with a permanent error
+	submitted := map[int64]int{}
+	client, server := newWithBatchGetServer(t, 10, func(uid int64) (string, string) {
+		submitted[uid] += 1
+		if uid%2 == 0 && submitted[uid] == 1 {
+			return "OTSServerBusy", "Server is busy."
+		} else if uid == 7 {
+			return "OTSParameterInvalid", "Invalid row."
+		}
+		return "", ""
+	})
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+
+	rows := OTSPrimaryKeyRows{}
+	for i := 0; i < 10; i++ {
+		rows = append(rows, OTSPrimaryKey{"gid": 1, "uid": i})
+	}
+	response, ots_err := client.BulkGetRow(&OTSBatchGetRowRequest{{TableName: "myTable", Rows: rows}})
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	for i, row := range response.Tables[0].Rows {
+		excpected := 1
+		if i%2 == 0 {
+			excpected = 2
+		}
+		if times := submitted[int64(i)]; times != excpected {
+			t.Errorf("row %d excpected to be read %d times but %d", i, excpected, times)
+		}
+		if i == 7 {
+			if row.IsOk || row.GetErrorCode() != "OTSParameterInvalid" {
+				t.Errorf("row %d should fail: %v", i, row)
+			}
+			continue
+		}
+		if !row.IsOk || row.Row.GetAttributeColumns()["index"] != int64(i) {
+			t.Errorf("unexpected row %d: %v", i, row)
+		}
+	}
+}
+
+// cancels the context when the retry delay is asked
+type cancel_retry_policy struct {
+	NoDelayRetryPolicy
+	cancel context.CancelFunc
+}
+
+func (o cancel_retry_policy) GetRetryDelay(retry_times int, exception *OTSServiceError, api_name string) float64 {
+	o.cancel()
+	return 60
+}
+
+func Test_bulk_get_row_cancel(t *testing.T) {
+	client, server := newWithBatchGetServer(t, 4, func(uid int64) (string, string) {
+		if uid == 1 {
+			return "OTSServerBusy", "Server is busy."
+		}
+		return "", ""
+	})
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.RetryPolicy = cancel_retry_policy{NoDelayRetryPolicy: OTSNoDelayRetryPolicy, cancel: cancel}
+
+	rows := OTSPrimaryKeyRows{}
+	for i := 0; i < 4; i++ {
+		rows = append(rows, OTSPrimaryKey{"gid": 1, "uid": i})
+	}
+
+	// the context is canceled during the retry delay
+	start := time.Now()
+	response, ots_err := client.BulkGetRowWithContext(ctx, &OTSBatchGetRowRequest{{TableName: "myTable", Rows: rows}})
+	if !errors.Is(ots_err, context.Canceled) {
+		t.Fatalf("error should be context.Canceled, not %v", ots_err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("the retry delay should be canceled, %v", elapsed)
+	}
+	if response == nil || len(response.Tables) != 1 || len(response.Tables[0].Rows) != 4 {
+		t.Fatalf("partial results should be returned: %v", response)
+	}
+	for i, row := range response.Tables[0].Rows {
+		if i == 1 {
+			if row.IsOk || row.GetErrorCode() != "OTSServerBusy" {
+				t.Errorf("row %d should keep the last failure: %v", i, row)
+			}
+			continue
+		}
+		if !row.IsOk || row.Row.GetAttributeColumns()["index"] != int64(i) {
+			t.Errorf("unexpected row %d: %v", i, row)
+		}
+	}
+}
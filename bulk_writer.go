@@ -0,0 +1,541 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// bulk writer for ots2
+package goots
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+const (
+	BATCH_WRITE_ROW_MAX_ROWS    = 200             // OTS 限制了每次BatchWriteRow 最多写入200 行
+	BATCH_WRITE_ROW_MAX_SIZE    = 4 * 1024 * 1024 // OTS 限制了每次BatchWriteRow 的数据最多4MB
+	DEFAULT_BULK_FLUSH_INTERVAL = time.Second
+)
+
+// the estimated encoded size of a row and a column besides names and values
+const (
+	bulk_row_overhead    = 16
+	bulk_column_overhead = 8
+)
+
+const (
+	// OTSBulkOperation
+	OTSBulkOperation_PUT    = "PUT"
+	OTSBulkOperation_UPDATE = "UPDATE"
+	OTSBulkOperation_DELETE = "DELETE"
+)
+
+// 在OTSBulkWriter 中最终写入失败的一行
+type OTSBulkWriteFailure struct {
+	// 该行所在的表名
+	TableName string
+	// 该行的操作类型，取值为 OTSBulkOperation_XXX
+	Operation string
+	// 该行的请求信息，类型为OTSPutRowItem、OTSUpdateRowItem或OTSDeleteRowItem
+	Item interface{}
+	// 该行操作的错误号，整个请求失败时为空
+	ErrorCode string
+	// 该行操作的错误信息，整个请求失败时为空
+	ErrorMessage string
+	// 整个BatchWriteRow 请求失败时的错误信息
+	Err *OTSError
+}
+
+// one buffered row of OTSBulkWriter
+type bulk_write_row struct {
+	table_name string
+	operation  string
+	item       interface{}
+}
+
+// 批量写入器，缓存PutRow、UpdateRow和DeleteRow操作，按BatchWriteRow 的行数和数据大小限制切分后批量写入
+//
+// 缓存的行数或估算的数据大小达到单批上限时立即写入，同时每隔一段时间将缓存中的行写入。
+// 同一个表中主键相同的行会写入不同的BatchWriteRow，按添加的顺序依次写入。
+// 单行失败时，使用OTSClient 的RetryPolicy 判断是否可以重试，只重新提交可以重试的行；
+// 最终失败的行通过OnFailure 回调报告，未设置回调时可以通过Failures 获取。
+type OTSBulkWriter struct {
+	// 最终写入失败时的回调，需要在添加行之前设置，可能在后台定时写入的goroutine 中调用
+	OnFailure func(failure *OTSBulkWriteFailure)
+
+	client         *OTSClient
+	max_batch_rows int
+
+	lock     sync.Mutex // protect rows, size, failures, consumed and closed
+	rows     []bulk_write_row
+	size     int // the estimated size of rows
+	failures []*OTSBulkWriteFailure
+	consumed OTSCapacityUnit
+	closed   bool
+
+	flush_lock sync.Mutex // only one flush at a time
+	stop       chan struct{}
+	stopped    chan struct{}
+}
+
+// 说明：创建一个批量写入器。
+//
+// 		``max_batch_rows``表示每次BatchWriteRow 最多写入的行数；如果为0，则使用BATCH_WRITE_ROW_MAX_ROWS。
+// 		``flush_interval``表示定时写入的间隔；如果为0，则使用DEFAULT_BULK_FLUSH_INTERVAL；如果小于0，则不定时写入。
+//
+// 		返回：批量写入器，使用完毕后需要调用Close。
+//
+// 		示例：
+//
+// 		bulk_writer := ots_client.NewBulkWriter(0, 0)
+// 		bulk_writer.OnFailure = func(failure *OTSBulkWriteFailure) {
+// 			fmt.Println("write failed:", failure.TableName, failure.ErrorCode, failure.ErrorMessage)
+// 		}
+// 		for i := 0; i < 1000; i++ {
+// 			bulk_writer.Put("myTable", OTSPutRowItem{
+// 				Condition:        OTSCondition_IGNORE,
+// 				PrimaryKey:       OTSPrimaryKey{"gid": i, "uid": i},
+// 				AttributeColumns: OTSAttribute{"name": "张三"},
+// 			})
+// 		}
+// 		ots_err := bulk_writer.Close()
+//
+func (o *OTSClient) NewBulkWriter(max_batch_rows int, flush_interval time.Duration) *OTSBulkWriter {
+	if max_batch_rows <= 0 || max_batch_rows > BATCH_WRITE_ROW_MAX_ROWS {
+		max_batch_rows = BATCH_WRITE_ROW_MAX_ROWS
+	}
+	if flush_interval == 0 {
+		flush_interval = DEFAULT_BULK_FLUSH_INTERVAL
+	}
+
+	w := &OTSBulkWriter{
+		client:         o,
+		max_batch_rows: max_batch_rows,
+		stop:           make(chan struct{}),
+		stopped:        make(chan struct{}),
+	}
+	if flush_interval > 0 {
+		go w._flush_loop(flush_interval)
+	} else {
+		close(w.stopped)
+	}
+
+	return w
+}
+
+// 添加一行PutRow 操作
+func (w *OTSBulkWriter) Put(table_name string, item OTSPutRowItem) *OTSError {
+	if item.Condition == "" {
+		return new(OTSError).SetClientMessage("[BulkWriter] condition should not be empty")
+	}
+	return w._add(bulk_write_row{table_name, OTSBulkOperation_PUT, item})
+}
+
+// 添加一行UpdateRow 操作
+func (w *OTSBulkWriter) Update(table_name string, item OTSUpdateRowItem) *OTSError {
+	if item.Condition == "" {
+		return new(OTSError).SetClientMessage("[BulkWriter] condition should not be empty")
+	}
+	return w._add(bulk_write_row{table_name, OTSBulkOperation_UPDATE, item})
+}
+
+// 添加一行DeleteRow 操作
+func (w *OTSBulkWriter) Delete(table_name string, item OTSDeleteRowItem) *OTSError {
+	if item.Condition == "" {
+		return new(OTSError).SetClientMessage("[BulkWriter] condition should not be empty")
+	}
+	return w._add(bulk_write_row{table_name, OTSBulkOperation_DELETE, item})
+}
+
+func (w *OTSBulkWriter) _add(row bulk_write_row) *OTSError {
+	if row.table_name == "" {
+		return new(OTSError).SetClientMessage("[BulkWriter] table_name should not be empty")
+	}
+
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return new(OTSError).SetClientMessage("[BulkWriter] writer is closed")
+	}
+	w.rows = append(w.rows, row)
+	w.size += _estimate_bulk_row_size(row)
+	full := len(w.rows) >= w.max_batch_rows || w.size >= BATCH_WRITE_ROW_MAX_SIZE
+	w.lock.Unlock()
+
+	if full {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// 说明：立即写入缓存中的所有行，等待写入（包括重试）结束后返回。
+//
+// 		返回：有行最终写入失败时返回错误信息，失败的行通过OnFailure 或Failures 获取。
+func (w *OTSBulkWriter) Flush() *OTSError {
+	return w.FlushWithContext(context.Background())
+}
+
+// 说明：同Flush，ctx用于控制写入（包括重试等待）的超时和取消，取消后未写入的行作为失败报告。
+func (w *OTSBulkWriter) FlushWithContext(ctx context.Context) *OTSError {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	w.flush_lock.Lock()
+	defer w.flush_lock.Unlock()
+
+	w.lock.Lock()
+	rows := w.rows
+	w.rows = nil
+	w.size = 0
+	w.lock.Unlock()
+
+	failed := 0
+	for len(rows) > 0 {
+		n := _get_bulk_batch_rows(rows, w.max_batch_rows)
+		failed += w._write_batch(ctx, rows[:n])
+		rows = rows[n:]
+	}
+
+	if failed > 0 {
+		return new(OTSError).SetClientMessage("[BulkWriter] %d rows failed to write", failed)
+	}
+
+	return nil
+}
+
+// 说明：写入缓存中的所有行并停止定时写入，之后不能再添加新的行。
+func (w *OTSBulkWriter) Close() *OTSError {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.lock.Unlock()
+
+	close(w.stop)
+	<-w.stopped
+
+	return w.Flush()
+}
+
+// 返回并清空尚未通过OnFailure 报告的失败行
+func (w *OTSBulkWriter) Failures() []*OTSBulkWriteFailure {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	failures := w.failures
+	w.failures = nil
+	return failures
+}
+
+// 返回所有写入合计消耗的CapacityUnit
+func (w *OTSBulkWriter) Consumed() *OTSCapacityUnit {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	consumed := w.consumed
+	return &consumed
+}
+
+func (w *OTSBulkWriter) _flush_loop(flush_interval time.Duration) {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(flush_interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.Flush()
+		}
+	}
+}
+
+// write one legal batch, re-submit the rows failed with retriable codes,
+// return the number of rows failed finally
+func (w *OTSBulkWriter) _write_batch(ctx context.Context, rows []bulk_write_row) int {
+	retry_policy := w.client.RetryPolicy
+	if retry_policy == nil {
+		retry_policy = OTSNoRetryPolicy
+	}
+
+	failed := 0
+	retry_times := 0
+	for len(rows) > 0 {
+		batch_list, positions := _make_bulk_write_request(rows)
+		response, ots_err := w.client.BatchWriteRowWithContext(ctx, batch_list)
+		if ots_err != nil {
+			// the whole request failed, the retry of request has been done by client
+			for _, row := range rows {
+				w._report_failure(&OTSBulkWriteFailure{
+					TableName: row.table_name,
+					Operation: row.operation,
+					Item:      row.item,
+					Err:       ots_err,
+				})
+			}
+			return failed + len(rows)
+		}
+
+		retry_rows := []bulk_write_row{}
+		var retry_err *OTSServiceError
+		for i, row := range rows {
+			result := _get_bulk_write_result(response, positions[i])
+			if result == nil {
+				w._report_failure(&OTSBulkWriteFailure{
+					TableName:    row.table_name,
+					Operation:    row.operation,
+					Item:         row.item,
+					ErrorMessage: "row result not found in response",
+				})
+				failed += 1
+				continue
+			}
+
+			if result.Consumed != nil {
+				w.lock.Lock()
+				w.consumed.Read += result.Consumed.GetRead()
+				w.consumed.Write += result.Consumed.GetWrite()
+				w.lock.Unlock()
+			}
+			if result.IsOk {
+				continue
+			}
+
+			row_err := new(OTSServiceError).SetErrorCode(result.GetErrorCode()).SetErrorMessage("%s", result.GetErrorMessage())
+			if retry_policy.ShouldRetry(retry_times, row_err, "BatchWriteRow") {
+				retry_rows = append(retry_rows, row)
+				retry_err = row_err
+				continue
+			}
+			w._report_failure(&OTSBulkWriteFailure{
+				TableName:    row.table_name,
+				Operation:    row.operation,
+				Item:         row.item,
+				ErrorCode:    result.GetErrorCode(),
+				ErrorMessage: result.GetErrorMessage(),
+			})
+			failed += 1
+		}
+
+		if len(retry_rows) == 0 {
+			break
+		}
+		retry_delay := retry_policy.GetRetryDelay(retry_times, retry_err, "BatchWriteRow")
+		if ctx_err := _retry_sleep(ctx, retry_delay); ctx_err != nil {
+			for _, row := range retry_rows {
+				w._report_failure(&OTSBulkWriteFailure{
+					TableName:    row.table_name,
+					Operation:    row.operation,
+					Item:         row.item,
+					ErrorCode:    retry_err.GetErrorCode(),
+					ErrorMessage: retry_err.GetErrorMessage(),
+					Err:          new(OTSError).SetClientMessage("[BulkWriter] %s", ctx_err),
+				})
+			}
+			return failed + len(retry_rows)
+		}
+		retry_times += 1
+		rows = retry_rows
+	}
+
+	return failed
+}
+
+func (w *OTSBulkWriter) _report_failure(failure *OTSBulkWriteFailure) {
+	if w.OnFailure != nil {
+		w.OnFailure(failure)
+		return
+	}
+
+	w.lock.Lock()
+	w.failures = append(w.failures, failure)
+	w.lock.Unlock()
+}
+
+// the position of a row in BatchWriteRow response
+type bulk_write_position struct {
+	table     int
+	operation string
+	row       int
+}
+
+// the number of rows at the head of rows in one BatchWriteRow, the batch has at most max_batch_rows rows
+// and BATCH_WRITE_ROW_MAX_SIZE bytes estimated, and no primary key is repeated in a table
+func _get_bulk_batch_rows(rows []bulk_write_row, max_batch_rows int) int {
+	size := 0
+	keys := map[string]bool{}
+	for i, row := range rows {
+		if i >= max_batch_rows {
+			return i
+		}
+		row_size := _estimate_bulk_row_size(row)
+		if i > 0 && size+row_size > BATCH_WRITE_ROW_MAX_SIZE {
+			return i
+		}
+		key := _get_bulk_row_key(row)
+		if keys[key] {
+			return i
+		}
+		keys[key] = true
+		size += row_size
+	}
+
+	return len(rows)
+}
+
+// the table name and primary key of row
+func _get_bulk_row_key(row bulk_write_row) string {
+	columns := []string{}
+	for _, column := range GetPrimaryKeyList(_get_bulk_row_primary_key(row)) {
+		switch v := column.V.(type) {
+		case string:
+			columns = append(columns, fmt.Sprintf("%s=s:%s", column.K, v))
+		case []byte:
+			columns = append(columns, fmt.Sprintf("%s=b:%s", column.K, v))
+		default:
+			// the integers of any type
+			columns = append(columns, fmt.Sprintf("%s=i:%v", column.K, v))
+		}
+	}
+	sort.Strings(columns)
+
+	return row.table_name + "\x00" + strings.Join(columns, "\x00")
+}
+
+func _get_bulk_row_primary_key(row bulk_write_row) OTSPrimaryKeyInterface {
+	switch item := row.item.(type) {
+	case OTSPutRowItem:
+		return item.PrimaryKey
+	case OTSUpdateRowItem:
+		return item.PrimaryKey
+	case OTSDeleteRowItem:
+		return item.PrimaryKey
+	}
+
+	return nil
+}
+
+// the estimated encoded size of row in BatchWriteRow request
+func _estimate_bulk_row_size(row bulk_write_row) int {
+	size := bulk_row_overhead
+	for _, column := range GetPrimaryKeyList(_get_bulk_row_primary_key(row)) {
+		size += _estimate_bulk_column_size(column.K, column.V)
+	}
+
+	switch item := row.item.(type) {
+	case OTSPutRowItem:
+		for name, value := range item.AttributeColumns {
+			size += _estimate_bulk_column_size(name, value)
+		}
+	case OTSUpdateRowItem:
+		for _, columns := range item.UpdateOfAttributeColumns {
+			switch v := columns.(type) {
+			case OTSColumnsToPut:
+				for name, value := range v {
+					size += _estimate_bulk_column_size(name, value)
+				}
+			case DictString:
+				for name, value := range v {
+					size += _estimate_bulk_column_size(name, value)
+				}
+			case OTSColumnsToDelete:
+				for _, name := range v {
+					size += len(name) + bulk_column_overhead
+				}
+			case []string:
+				for _, name := range v {
+					size += len(name) + bulk_column_overhead
+				}
+			}
+		}
+	}
+
+	return size
+}
+
+func _estimate_bulk_column_size(name string, value interface{}) int {
+	size := len(name) + bulk_column_overhead
+	switch v := value.(type) {
+	case string:
+		size += len(v)
+	case []byte:
+		size += len(v)
+	case OTSColumnValue:
+		if s, err := v.AsString(); err == nil {
+			size += len(s)
+		} else if b, err := v.AsBinary(); err == nil {
+			size += len(b)
+		} else {
+			size += 8
+		}
+	case bool:
+		size += 1
+	default:
+		size += 8
+	}
+
+	return size
+}
+
+// group rows by table in the order of their first appearance
+func _make_bulk_write_request(rows []bulk_write_row) (*OTSBatchWriteRowRequest, []bulk_write_position) {
+	batch_list := OTSBatchWriteRowRequest{}
+	positions := make([]bulk_write_position, len(rows))
+	tables := map[string]int{}
+
+	for i, row := range rows {
+		t, ok := tables[row.table_name]
+		if !ok {
+			t = len(batch_list)
+			tables[row.table_name] = t
+			batch_list = append(batch_list, OTSTableInBatchWriteRowRequestItem{TableName: row.table_name})
+		}
+
+		table_item := &batch_list[t]
+		switch row.operation {
+		case OTSBulkOperation_PUT:
+			positions[i] = bulk_write_position{t, row.operation, len(table_item.PutRows)}
+			table_item.PutRows = append(table_item.PutRows, row.item.(OTSPutRowItem))
+		case OTSBulkOperation_UPDATE:
+			positions[i] = bulk_write_position{t, row.operation, len(table_item.UpdateRows)}
+			table_item.UpdateRows = append(table_item.UpdateRows, row.item.(OTSUpdateRowItem))
+		case OTSBulkOperation_DELETE:
+			positions[i] = bulk_write_position{t, row.operation, len(table_item.DeleteRows)}
+			table_item.DeleteRows = append(table_item.DeleteRows, row.item.(OTSDeleteRowItem))
+		}
+	}
+
+	return &batch_list, positions
+}
+
+func _get_bulk_write_result(response *OTSBatchWriteRowResponse, position bulk_write_position) *OTSRowInBatchWriteRowResponseItem {
+	if response == nil || position.table >= len(response.Tables) || response.Tables[position.table] == nil {
+		return nil
+	}
+
+	var results []*OTSRowInBatchWriteRowResponseItem
+	switch position.operation {
+	case OTSBulkOperation_PUT:
+		results = response.Tables[position.table].PutRows
+	case OTSBulkOperation_UPDATE:
+		results = response.Tables[position.table].UpdateRows
+	case OTSBulkOperation_DELETE:
+		results = response.Tables[position.table].DeleteRows
+	}
+	if position.row >= len(results) {
+		return nil
+	}
+
+	return results[position.row]
+}
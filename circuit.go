@@ -0,0 +1,314 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Circuit breaker for ots2
+package goots
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// 熔断器的状态
+const (
+	OTSCircuitState_CLOSED    = "CLOSED"
+	OTSCircuitState_OPEN      = "OPEN"
+	OTSCircuitState_HALF_OPEN = "HALF_OPEN"
+)
+
+const (
+	DEFAULT_CIRCUIT_FAILURE_RATE      = 0.5
+	DEFAULT_CIRCUIT_MIN_REQUESTS      = 20
+	DEFAULT_CIRCUIT_WINDOW            = 10 * time.Second
+	DEFAULT_CIRCUIT_OPEN_TIMEOUT      = 30 * time.Second
+	DEFAULT_CIRCUIT_HALF_OPEN_REQUEST = 1
+)
+
+// 按EndPoint（可选按表）统计请求的熔断器，通过OTSClient.CircuitBreaker 设置，可以被多个OTSClient 共用
+//
+// 		每次HTTP 请求（包括重试）都会被统计，Window 内请求数不少于MinRequests 且可重试错误
+// 		（OTSServerBusy、OTSPartitionUnavailable、5xx 和网络错误）的比例不低于FailureRate 时打开熔断器。
+// 		打开期间请求直接返回ErrCircuitOpen，不会发送也不会重试；OpenTimeout 后进入半开状态，
+// 		允许HalfOpenRequests 个探测请求，全部成功后关闭，任意一个失败则重新打开；
+// 		半开状态只统计探测请求的结果，进入半开前发出的请求完成时不计入。
+//
+// 		示例：
+//
+// 		ots_client.CircuitBreaker = NewCircuitBreaker()
+// 		_, ots_err := ots_client.GetRow("myTable", primary_key, nil)
+// 		if errors.Is(ots_err, ots2.ErrCircuitOpen) {
+// 			// 实例不可用，降级处理
+// 		}
+//
+type OTSCircuitBreaker struct {
+	// 打开熔断器的失败比例，默认为DEFAULT_CIRCUIT_FAILURE_RATE
+	FailureRate float64
+	// 统计窗口内打开熔断器的最少请求数，默认为DEFAULT_CIRCUIT_MIN_REQUESTS
+	MinRequests int
+	// 统计窗口，默认为DEFAULT_CIRCUIT_WINDOW
+	Window time.Duration
+	// 打开后进入半开状态的时间，默认为DEFAULT_CIRCUIT_OPEN_TIMEOUT
+	OpenTimeout time.Duration
+	// 半开状态允许的探测请求数，默认为DEFAULT_CIRCUIT_HALF_OPEN_REQUEST
+	HalfOpenRequests int
+	// 是否按表分别统计，BatchGetRow 和BatchWriteRow 的多个表以逗号连接作为一个表
+	PerTable bool
+	// 可选，判断错误是否计为失败，为nil 时使用默认规则
+	IsFailure func(ots_service_error *OTSServiceError) bool
+
+	lock       sync.Mutex
+	circuits   map[string]*circuit
+	half_opens uint64 // the number of times any circuit moved to half-open state
+}
+
+type circuit struct {
+	state        string
+	window_start time.Time
+	requests     int
+	failures     int
+	opened_at    time.Time
+	half_open_id uint64 // identify the current half-open state, the probes sent in it carry the same id
+	probes       int    // the probes sent in half-open state
+	successes    int    // the succeeded probes in half-open state
+}
+
+func NewCircuitBreaker() *OTSCircuitBreaker {
+	return &OTSCircuitBreaker{
+		FailureRate:      DEFAULT_CIRCUIT_FAILURE_RATE,
+		MinRequests:      DEFAULT_CIRCUIT_MIN_REQUESTS,
+		Window:           DEFAULT_CIRCUIT_WINDOW,
+		OpenTimeout:      DEFAULT_CIRCUIT_OPEN_TIMEOUT,
+		HalfOpenRequests: DEFAULT_CIRCUIT_HALF_OPEN_REQUEST,
+		circuits:         map[string]*circuit{},
+	}
+}
+
+// 说明：返回熔断器的状态，key 为EndPoint，按表统计时为 EndPoint + "/" + 表名。
+//
+// 		返回：OTSCircuitState_CLOSED、OTSCircuitState_OPEN 或OTSCircuitState_HALF_OPEN。
+func (o *OTSCircuitBreaker) State(key string) string {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	c, ok := o.circuits[key]
+	if !ok {
+		return OTSCircuitState_CLOSED
+	}
+	o._refresh(c, time.Now())
+
+	return c.state
+}
+
+// 说明：关闭所有熔断器并清空统计。
+func (o *OTSCircuitBreaker) Reset() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.circuits = map[string]*circuit{}
+}
+
+func (o *OTSCircuitBreaker) _get_key(end_point string, call *OTSCall) string {
+	if o.PerTable {
+		if table_name := call._get_table_name(); table_name != "" {
+			return end_point + "/" + table_name
+		}
+	}
+
+	return end_point
+}
+
+// check whether a request can be sent, a probe is taken in half-open state
+// and its half-open id is returned, the id is 0 if the request is not a probe
+func (o *OTSCircuitBreaker) _allow(key string) (bool, uint64) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	c, ok := o.circuits[key]
+	if !ok {
+		return true, 0
+	}
+	o._refresh(c, time.Now())
+
+	switch c.state {
+	case OTSCircuitState_OPEN:
+		return false, 0
+	case OTSCircuitState_HALF_OPEN:
+		if c.probes >= o._get_half_open_requests() {
+			return false, 0
+		}
+		c.probes += 1
+		return true, c.half_open_id
+	}
+
+	return true, 0
+}
+
+// record the result of a request, probe is the id returned by _allow
+func (o *OTSCircuitBreaker) _record(key string, probe uint64, ots_service_error *OTSServiceError) {
+	failed := o._is_failure(ots_service_error)
+	canceled := ots_service_error != nil && (errors.Is(ots_service_error.Err, context.Canceled) || errors.Is(ots_service_error.Err, context.DeadlineExceeded))
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	now := time.Now()
+	c, ok := o.circuits[key]
+	if !ok {
+		c = &circuit{state: OTSCircuitState_CLOSED, window_start: now}
+		o.circuits[key] = c
+	}
+	o._refresh(c, now)
+
+	if c.state == OTSCircuitState_HALF_OPEN && probe != c.half_open_id {
+		// sent before the current half-open state, only the probes are counted
+		return
+	}
+	if canceled && !failed {
+		// the canceled requests tell nothing about the endpoint, give back the probe
+		if c.state == OTSCircuitState_HALF_OPEN && c.probes > 0 {
+			c.probes -= 1
+		}
+		return
+	}
+
+	switch c.state {
+	case OTSCircuitState_CLOSED:
+		c.requests += 1
+		if failed {
+			c.failures += 1
+		}
+		if c.requests >= o._get_min_requests() && float64(c.failures) >= float64(c.requests)*o._get_failure_rate() {
+			c.state, c.opened_at = OTSCircuitState_OPEN, now
+		}
+	case OTSCircuitState_HALF_OPEN:
+		if failed {
+			c.state, c.opened_at = OTSCircuitState_OPEN, now
+			return
+		}
+		c.successes += 1
+		if c.successes >= o._get_half_open_requests() {
+			*c = circuit{state: OTSCircuitState_CLOSED, window_start: now}
+		}
+	}
+}
+
+// move to half-open after OpenTimeout, and start a new window after Window
+func (o *OTSCircuitBreaker) _refresh(c *circuit, now time.Time) {
+	switch c.state {
+	case OTSCircuitState_OPEN:
+		if now.Sub(c.opened_at) >= o._get_open_timeout() {
+			o.half_opens += 1
+			c.state, c.half_open_id, c.probes, c.successes = OTSCircuitState_HALF_OPEN, o.half_opens, 0, 0
+		}
+	case OTSCircuitState_CLOSED:
+		if now.Sub(c.window_start) >= o._get_window() {
+			c.window_start, c.requests, c.failures = now, 0, 0
+		}
+	}
+}
+
+func (o *OTSCircuitBreaker) _is_failure(ots_service_error *OTSServiceError) bool {
+	if ots_service_error == nil {
+		return false
+	}
+	if o.IsFailure != nil {
+		return o.IsFailure(ots_service_error)
+	}
+
+	return is_circuit_failure(ots_service_error)
+}
+
+func (o *OTSCircuitBreaker) _get_failure_rate() float64 {
+	if o.FailureRate <= 0 {
+		return DEFAULT_CIRCUIT_FAILURE_RATE
+	}
+	return o.FailureRate
+}
+
+func (o *OTSCircuitBreaker) _get_min_requests() int {
+	if o.MinRequests <= 0 {
+		return DEFAULT_CIRCUIT_MIN_REQUESTS
+	}
+	return o.MinRequests
+}
+
+func (o *OTSCircuitBreaker) _get_window() time.Duration {
+	if o.Window <= 0 {
+		return DEFAULT_CIRCUIT_WINDOW
+	}
+	return o.Window
+}
+
+func (o *OTSCircuitBreaker) _get_open_timeout() time.Duration {
+	if o.OpenTimeout <= 0 {
+		return DEFAULT_CIRCUIT_OPEN_TIMEOUT
+	}
+	return o.OpenTimeout
+}
+
+func (o *OTSCircuitBreaker) _get_half_open_requests() int {
+	if o.HalfOpenRequests <= 0 {
+		return DEFAULT_CIRCUIT_HALF_OPEN_REQUEST
+	}
+	return o.HalfOpenRequests
+}
+
+// the errors showing the endpoint is degraded, the canceled requests are not counted
+func is_circuit_failure(exception *OTSServiceError) bool {
+	if exception == nil {
+		return false
+	}
+	if errors.Is(exception.Err, context.Canceled) || errors.Is(exception.Err, context.DeadlineExceeded) {
+		return false
+	}
+	if exception.Err != nil {
+		if _, ok := exception.Err.(net.Error); ok {
+			return true
+		} else if exception.Err == ErrNonResponseBody || exception.Err == ErrReadResponse {
+			return true
+		}
+	}
+
+	if exception.Code == "OTSServerBusy" || exception.Code == "OTSPartitionUnavailable" {
+		return true
+	}
+
+	return exception.HttpStatus >= 500
+}
+
+// return ErrCircuitOpen if the circuit of call is open, last_error is the error of the previous attempt,
+// the returned probe should be passed to _circuit_record with the result of the attempt
+func (o *OTSClient) _circuit_allow(call *OTSCall, last_error *OTSServiceError) (uint64, *OTSServiceError) {
+	if o.CircuitBreaker == nil {
+		return 0, nil
+	}
+
+	key := o.CircuitBreaker._get_key(o.EndPoint, call)
+	allowed, probe := o.CircuitBreaker._allow(key)
+	if allowed {
+		return probe, nil
+	}
+
+	ots_service_error := new(OTSServiceError).SetErrorCode(ErrCircuitOpen.Code)
+	if last_error != nil {
+		e := *last_error
+		ots_service_error.SetErrorMessage("circuit breaker is open for %s, last error: %s", key, last_error.Message)
+		ots_service_error.Err = &e
+	} else {
+		ots_service_error.SetErrorMessage("circuit breaker is open for %s", key)
+	}
+
+	return 0, ots_service_error
+}
+
+func (o *OTSClient) _circuit_record(call *OTSCall, probe uint64, ots_service_error *OTSServiceError) {
+	if o.CircuitBreaker == nil {
+		return
+	}
+
+	o.CircuitBreaker._record(o.CircuitBreaker._get_key(o.EndPoint, call), probe, ots_service_error)
+}
@@ -0,0 +1,156 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test circuit breaker for ots2
+package goots
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_circuit_breaker(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+
+	circuit_breaker := NewCircuitBreaker()
+	circuit_breaker.MinRequests = 2
+	circuit_breaker.OpenTimeout = 100 * time.Millisecond
+	client.CircuitBreaker = circuit_breaker
+
+	// the errors of client are not counted
+	for i := 0; i < 3; i++ {
+		if _, ots_err := client.DescribeTable("notExistTable"); !errors.Is(ots_err, ErrObjectNotExist) {
+			t.Fatalf("error should be ErrObjectNotExist, not %v", ots_err)
+		}
+	}
+	if state := circuit_breaker.State(client.EndPoint); state != OTSCircuitState_CLOSED {
+		t.Fatalf("unexpected state: %s", state)
+	}
+
+	// open after 2 failures, and stop retrying
+	attempts := 0
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		attempts += 1
+		return 503, "OTSServerBusy", "Server is busy."
+	}
+	circuit_breaker.Reset()
+	_, ots_err := client.ListTable()
+	if !errors.Is(ots_err, ErrCircuitOpen) || !errors.Is(ots_err, ErrServerBusy) || attempts != 2 {
+		t.Fatalf("error should be ErrCircuitOpen after 2 attempts, not %v, %d", ots_err, attempts)
+	}
+	if state := circuit_breaker.State(client.EndPoint); state != OTSCircuitState_OPEN {
+		t.Fatalf("unexpected state: %s", state)
+	}
+
+	// fail fast while open
+	attempts = 0
+	if _, ots_err = client.ListTable(); !errors.Is(ots_err, ErrCircuitOpen) || errors.Is(ots_err, ErrServerBusy) || attempts != 0 {
+		t.Fatalf("error should be ErrCircuitOpen without any request, not %v, %d", ots_err, attempts)
+	}
+
+	// a failed probe opens it again
+	time.Sleep(150 * time.Millisecond)
+	if state := circuit_breaker.State(client.EndPoint); state != OTSCircuitState_HALF_OPEN {
+		t.Fatalf("unexpected state: %s", state)
+	}
+	if _, ots_err = client.ListTable(); !errors.Is(ots_err, ErrCircuitOpen) || attempts != 1 {
+		t.Fatalf("error should be ErrCircuitOpen after the probe, not %v, %d", ots_err, attempts)
+	}
+	if state := circuit_breaker.State(client.EndPoint); state != OTSCircuitState_OPEN {
+		t.Fatalf("unexpected state: %s", state)
+	}
+
+	// a succeeded probe closes it
+	time.Sleep(150 * time.Millisecond)
+	ots_emulator.InjectError = nil
+	if _, ots_err = client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if state := circuit_breaker.State(client.EndPoint); state != OTSCircuitState_CLOSED {
+		t.Fatalf("unexpected state: %s", state)
+	}
+
+	// per table
+	circuit_breaker.PerTable = true
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		if api_name == "GetRow" {
+			return 500, "OTSInternalServerError", "Internal server error."
+		}
+		return 0, "", ""
+	}
+	primary_key := &OTSPrimaryKey{"gid": 1, "uid": 101}
+	for i := 0; i < 2; i++ {
+		client.GetRow("myTable", primary_key, nil)
+	}
+	if state := circuit_breaker.State(client.EndPoint + "/myTable"); state != OTSCircuitState_OPEN {
+		t.Fatalf("unexpected state: %s", state)
+	}
+	if state := circuit_breaker.State(client.EndPoint); state != OTSCircuitState_CLOSED {
+		t.Fatalf("unexpected state: %s", state)
+	}
+	if _, ots_err = client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+}
+
+func Test_circuit_breaker_half_open_probes(t *testing.T) {
+	circuit_breaker := NewCircuitBreaker()
+	circuit_breaker.MinRequests = 2
+	circuit_breaker.OpenTimeout = 50 * time.Millisecond
+	key := "http://127.0.0.1"
+	busy := new(OTSServiceError).SetErrorCode("OTSServerBusy").SetHttpStatus(503)
+
+	// a slow request sent while closed
+	allowed, slow_request := circuit_breaker._allow(key)
+	if !allowed || slow_request != 0 {
+		t.Fatalf("request should be allowed without a probe: %v, %d", allowed, slow_request)
+	}
+	for i := 0; i < 2; i++ {
+		circuit_breaker._record(key, 0, busy)
+	}
+	time.Sleep(100 * time.Millisecond)
+	allowed, probe := circuit_breaker._allow(key)
+	if !allowed || probe == 0 {
+		t.Fatalf("the probe should be allowed: %v, %d", allowed, probe)
+	}
+
+	// the slow request completed in half-open state is not a probe
+	circuit_breaker._record(key, slow_request, nil)
+	if state := circuit_breaker.State(key); state != OTSCircuitState_HALF_OPEN {
+		t.Fatalf("unexpected state: %s", state)
+	}
+	if allowed, _ := circuit_breaker._allow(key); allowed {
+		t.Fatal("only one probe should be allowed")
+	}
+	circuit_breaker._record(key, probe, nil)
+	if state := circuit_breaker.State(key); state != OTSCircuitState_CLOSED {
+		t.Fatalf("unexpected state: %s", state)
+	}
+
+	// the probe of a previous half-open state is not counted
+	for i := 0; i < 2; i++ {
+		circuit_breaker._record(key, 0, busy)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_, old_probe := circuit_breaker._allow(key)
+	circuit_breaker._record(key, old_probe, busy)
+	time.Sleep(100 * time.Millisecond)
+	_, probe = circuit_breaker._allow(key)
+	if probe == 0 || probe == old_probe {
+		t.Fatalf("unexpected probe: %d, %d", probe, old_probe)
+	}
+	circuit_breaker._record(key, old_probe, nil)
+	if state := circuit_breaker.State(key); state != OTSCircuitState_HALF_OPEN {
+		t.Fatalf("unexpected state: %s", state)
+	}
+	circuit_breaker._record(key, probe, nil)
+	if state := circuit_breaker.State(key); state != OTSCircuitState_CLOSED {
+		t.Fatalf("unexpected state: %s", state)
+	}
+}
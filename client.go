@@ -6,10 +6,12 @@
 package goots
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -43,6 +45,15 @@ var defaultOTSSetting = OTSClient{
 	DEFAULT_ENCODING,        // Encoding
 	&defaultProtocol,        // default protocol
 	OTSDefaultRetryPolicy,   // default retry policy
+	nil,                     // Logger
+	nil,                     // CredentialsProvider
+	nil,                     // Interceptors
+	nil,                     // Tracer
+	nil,                     // CircuitBreaker
+	nil,                     // Validator
+	nil,                     // TLSConfig
+	nil,                     // transport
+	nil,                     // schemas
 }
 var settingMutex sync.Mutex
 
@@ -64,16 +75,31 @@ const (
 	// NOTE: TableStore server has a keepalive timeout of 30s, the
 	// idle timeout on client side must be less than this value.
 	defaultIdleTimeout = 20 * time.Second
+	defaultKeepAlive   = 30 * time.Second
 )
 
-func getTransport(connTimeout time.Duration) *http.Transport {
+// maxConnection limits both the idle and active connections per host, the
+// transport is configured here once and shared by the requests of client
+func getTransport(connTimeout time.Duration, maxConnection int, tlsConfig *tls.Config) *http.Transport {
 	if connTimeout == 0 {
 		connTimeout = defaultConnTimeout
 	}
+	if maxConnection <= 0 {
+		maxConnection = DEFAULT_MAX_CONNECTION
+	}
 
+	dialer := &net.Dialer{
+		Timeout:   connTimeout,
+		KeepAlive: defaultKeepAlive,
+	}
 	return &http.Transport{
-		Dial:            urllib.TimeoutDialer(connTimeout),
-		IdleConnTimeout: defaultIdleTimeout,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: connTimeout,
+		MaxIdleConns:        maxConnection,
+		MaxIdleConnsPerHost: maxConnection,
+		MaxConnsPerHost:     maxConnection,
+		IdleConnTimeout:     defaultIdleTimeout,
 	}
 }
 
@@ -95,6 +121,7 @@ func New(end_point, accessid, accesskey, instance_name string, kwargs ...interfa
 	o.LoggerName = defaultOTSSetting.LoggerName
 	o.Encoding = defaultOTSSetting.Encoding
 	o.protocol = defaultOTSSetting.protocol
+	o.schemas = new(ots_schema_cache)
 	// initialize the retry policy
 	if o.RetryPolicy == nil {
 		o.RetryPolicy = OTSDefaultRetryPolicy
@@ -129,13 +156,20 @@ func New(end_point, accessid, accesskey, instance_name string, kwargs ...interfa
 			} else {
 				return nil, (OTSClientError{}.Set("OTSClient.Encoding should be string type, not %v", reflect.TypeOf(v)))
 			}
+
+		case 4: // TLSConfig --> *tls.Config
+			if _, ok := v.(*tls.Config); ok {
+				o.TLSConfig = v.(*tls.Config)
+			} else {
+				return nil, (OTSClientError{}.Set("OTSClient.TLSConfig should be *tls.Config type, not %v", reflect.TypeOf(v)))
+			}
 		}
 	}
 
 	// parse end point
 	end_point_url, err := url.Parse(end_point)
 	if err != nil {
-		return nil, (OTSClientError{}.Set("url parse error", err))
+		return nil, (OTSClientError{}.Set("url parse error: %s", err))
 	}
 	if end_point_url.Scheme != "http" && end_point_url.Scheme != "https" {
 		return nil, (OTSClientError{}.Set("protocol of end_point must be 'http' or 'https', e.g. http://ots.aliyuncs.com:80."))
@@ -145,31 +179,7 @@ func New(end_point, accessid, accesskey, instance_name string, kwargs ...interfa
 		return nil, (OTSClientError{}.Set("host of end_point should be specified, e.g. http://ots.aliyuncs.com:80."))
 	}
 
-	// set default setting for urllib
-	url_setting := urllib.HttpSettings{
-		false,            // ShowDebug
-		"GiterLab",       // UserAgent
-		60 * time.Second, // ConnectTimeout
-		60 * time.Second, // ReadWriteTimeout
-		nil,              // TlsClientConfig
-		nil,              // Proxy
-		nil,              // Transport
-		false,            // EnableCookie
-		true,             // Gzip
-		true,             // DumpBody
-	}
-	if o.SocketTimeout != 0 {
-		url_setting.ConnectTimeout = time.Duration(o.SocketTimeout) * time.Second
-		url_setting.ReadWriteTimeout = time.Duration(o.SocketTimeout) * time.Second
-	}
-	url_setting.Transport = getTransport(url_setting.ConnectTimeout)
-
-	if OTSHttpDebugEnable {
-		url_setting.ShowDebug = true
-	} else {
-		url_setting.ShowDebug = false
-	}
-	urllib.SetDefaultSetting(url_setting)
+	o._init_transport()
 
 	protocol := &ots_protocol{}
 	o.protocol = newProtocol(protocol)
@@ -196,6 +206,7 @@ func NewWithRetryPolicy(end_point, accessid, accesskey, instance_name string, re
 	o.LoggerName = defaultOTSSetting.LoggerName
 	o.Encoding = defaultOTSSetting.Encoding
 	o.protocol = defaultOTSSetting.protocol
+	o.schemas = new(ots_schema_cache)
 	// initialize the retry policy
 	if retry_policy != nil {
 		o.RetryPolicy = retry_policy
@@ -232,13 +243,20 @@ func NewWithRetryPolicy(end_point, accessid, accesskey, instance_name string, re
 			} else {
 				return nil, (OTSClientError{}.Set("OTSClient.Encoding should be string type, not %v", reflect.TypeOf(v)))
 			}
+
+		case 4: // TLSConfig --> *tls.Config
+			if _, ok := v.(*tls.Config); ok {
+				o.TLSConfig = v.(*tls.Config)
+			} else {
+				return nil, (OTSClientError{}.Set("OTSClient.TLSConfig should be *tls.Config type, not %v", reflect.TypeOf(v)))
+			}
 		}
 	}
 
 	// parse end point
 	end_point_url, err := url.Parse(end_point)
 	if err != nil {
-		return nil, (OTSClientError{}.Set("url parse error", err))
+		return nil, (OTSClientError{}.Set("url parse error: %s", err))
 	}
 	if end_point_url.Scheme != "http" && end_point_url.Scheme != "https" {
 		return nil, (OTSClientError{}.Set("protocol of end_point must be 'http' or 'https', e.g. http://ots.aliyuncs.com:80."))
@@ -248,31 +266,7 @@ func NewWithRetryPolicy(end_point, accessid, accesskey, instance_name string, re
 		return nil, (OTSClientError{}.Set("host of end_point should be specified, e.g. http://ots.aliyuncs.com:80."))
 	}
 
-	// set default setting for urllib
-	url_setting := urllib.HttpSettings{
-		false,            // ShowDebug
-		"GiterLab",       // UserAgent
-		60 * time.Second, // ConnectTimeout
-		60 * time.Second, // ReadWriteTimeout
-		nil,              // TlsClientConfig
-		nil,              // Proxy
-		nil,              // Transport
-		false,            // EnableCookie
-		true,             // Gzip
-		true,             // DumpBody
-	}
-	if o.SocketTimeout != 0 {
-		url_setting.ConnectTimeout = time.Duration(o.SocketTimeout) * time.Second
-		url_setting.ReadWriteTimeout = time.Duration(o.SocketTimeout) * time.Second
-	}
-	url_setting.Transport = getTransport(url_setting.ConnectTimeout)
-
-	if OTSHttpDebugEnable {
-		url_setting.ShowDebug = true
-	} else {
-		url_setting.ShowDebug = false
-	}
-	urllib.SetDefaultSetting(url_setting)
+	o._init_transport()
 
 	protocol := &ots_protocol{}
 	o.protocol = newProtocol(protocol)
@@ -292,10 +286,9 @@ type OTSClient struct {
 	// 访问的实例名，通过官方网站控制台创建或通过管理员获取
 	InstanceName string
 
-	// 连接池中每个连接的Socket超时，单位为秒，可以为int或float。默认值为50
+	// 连接池中每个连接的Socket超时，单位为秒，同时作为建立连接的超时和每个请求的读写超时。默认值为50
 	SocketTimeout int
-	// 连接池的最大连接数。默认为50
-	// golang http自带连接池管理，此参数留作以后扩展用
+	// 连接池中每个host 的最大连接数，空闲连接数也不超过此值。默认为50
 	MaxConnection int
 
 	// 用来在请求中打DEBUG日志，或者在出错时打ERROR日志
@@ -309,7 +302,80 @@ type OTSClient struct {
 
 	// 定义了重试策略，默认的重试策略为 DefaultRetryPolicy。
 	// 你可以继承 RetryPolicy 来实现自己的重试策略，请参考 DefaultRetryPolicy 的代码。
+	// 实现了 RetryPolicyWithInfoInterface 的重试策略（如 BackoffRetryPolicy）可以获取幂等性、截止时间等信息。
 	RetryPolicy RetryPolicyInterface
+
+	// 结构化日志，为nil 时根据OTSDebugEnable 和OTSLoggerEnable 使用默认日志，见LoggerInit
+	// 可以使用 NewSlogLogger 适配log/slog（需要Go 1.21 及以上），实现了OTSContextLogger 时日志带上API 调用的ctx
+	Logger OTSLogger
+
+	// 访问凭证提供者，每次请求前调用，为nil 时使用AccessId 和AccessKey
+	// 内置NewStaticCredentialsProvider、NewEnvCredentialsProvider、NewProfileCredentialsProvider 和NewSTSCredentialsProvider
+	CredentialsProvider OTSCredentialsProvider
+
+	// 包裹每次API 调用的拦截器，先注册的在外层，见 Use
+	Interceptors []OTSInterceptor
+
+	// 链路追踪，为nil 时不追踪，见 OTSTracer
+	Tracer OTSTracer
+
+	// 熔断器，为nil 时不熔断，见 OTSCircuitBreaker
+	CircuitBreaker *OTSCircuitBreaker
+
+	// 请求的客户端校验，为nil 时不校验，见 OTSValidator
+	Validator *OTSValidator
+
+	// 访问https 地址时使用的TLS 配置，为nil 时使用系统根证书校验服务端证书，最低版本为TLS 1.2
+	// 可以设置自定义的根证书(RootCAs)、客户端证书(Certificates)和最低版本(MinVersion)，见 NewTLSConfig
+	// 测试环境需要跳过证书校验时，必须显式设置InsecureSkipVerify 为true
+	// 创建OTSClient 后修改需要通过Set 设置，以便重建连接池
+	TLSConfig *tls.Config
+
+	// 每个OTSClient 独享的连接池，根据SocketTimeout、MaxConnection 和TLSConfig 创建
+	transport *http.Transport
+
+	// 没有设置Validator 时缓存的表结构，用于主键列的排序
+	schemas *ots_schema_cache
+}
+
+// the socket timeout of client, used as the connect and read/write timeout
+func (o *OTSClient) _get_socket_timeout() time.Duration {
+	if o.SocketTimeout <= 0 {
+		return defaultConnTimeout
+	}
+
+	return time.Duration(o.SocketTimeout) * time.Second
+}
+
+// the tls config of client, verify the server certificate by default
+func (o *OTSClient) _get_tls_config() *tls.Config {
+	var tls_config *tls.Config
+	if o.TLSConfig != nil {
+		tls_config = o.TLSConfig.Clone()
+	} else {
+		tls_config = &tls.Config{}
+	}
+	if tls_config.MinVersion == 0 {
+		tls_config.MinVersion = tls.VersionTLS12
+	}
+
+	return tls_config
+}
+
+// create the transport of client, the idle connections of the old one are closed
+func (o *OTSClient) _init_transport() {
+	tls_config := o._get_tls_config()
+	if o.transport != nil {
+		o.transport.CloseIdleConnections()
+	}
+	o.transport = getTransport(o._get_socket_timeout(), o.MaxConnection, tls_config)
+}
+
+// 		关闭连接池中的空闲连接，不再使用OTSClient 时可以调用此函数释放连接
+func (o *OTSClient) CloseIdleConnections() {
+	if o.transport != nil {
+		o.transport.CloseIdleConnections()
+	}
 }
 
 func (o *OTSClient) String() string {
@@ -342,8 +408,29 @@ func (o *OTSClient) String() string {
 // 		MaxConnection --> int
 // 		LoggerName --> string
 // 		Encoding --> string
+// 		Logger --> OTSLogger
+// 		CredentialsProvider --> OTSCredentialsProvider
+// 		TLSConfig --> *tls.Config
 // 		注：具体参数意义请查看OTSClinet定义处的注释
+// 		参数错误时，OTSErrorPanicMode 为true 则panic，否则记录错误日志，OTSClient 的参数不会被修改
+//
+// Deprecated: 使用SetWithError，参数错误时返回错误。
 func (o *OTSClient) Set(kwargs DictString) *OTSClient {
+	if err := o.SetWithError(kwargs); err != nil {
+		if OTSErrorPanicMode {
+			panic(err)
+		}
+		logger, base := o._get_logger()
+		_log(context.Background(), logger, log_level_error, "OTSClient set failed", _log_fields(base, "error", err)...)
+	}
+
+	return o
+}
+
+// 		同Set，参数错误时返回错误而不是panic，出错时OTSClient 的参数不会被修改
+func (o *OTSClient) SetWithError(kwargs DictString) error {
+	c := *o
+	reset_transport := false
 	if len(kwargs) != 0 {
 		for k, v := range kwargs {
 			switch k {
@@ -352,86 +439,144 @@ func (o *OTSClient) Set(kwargs DictString) *OTSClient {
 					setting := urllib.GetDefaultSetting()
 					setting.ShowDebug = v1
 				} else {
-					panic(OTSClientError{}.Set("Debug should be bool, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("Debug should be bool, not %v", reflect.TypeOf(v))
 				}
 			case "EndPoint":
 				if v1, ok := v.(string); ok {
-					o.EndPoint = v1
+					c.EndPoint = v1
 				} else {
-					panic(OTSClientError{}.Set("EndPoint should be string, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("EndPoint should be string, not %v", reflect.TypeOf(v))
 				}
 				// parse end point
 				end_point_url, err := url.Parse(v.(string))
 				if err != nil {
-					panic(OTSClientError{}.Set("url parse error", err))
+					return new(OTSClientError).SetErrorMessage("url parse error: %s", err)
 				}
 				if end_point_url.Scheme != "http" && end_point_url.Scheme != "https" {
-					panic(OTSClientError{}.Set("protocol of end_point must be 'http' or 'https', e.g. http://ots.aliyuncs.com:80."))
+					return new(OTSClientError).SetErrorMessage("protocol of end_point must be 'http' or 'https', e.g. http://ots.aliyuncs.com:80.")
 				}
 
 				if end_point_url.Host == "" {
-					panic(OTSClientError{}.Set("host of end_point should be specified, e.g. http://ots.aliyuncs.com:80."))
+					return new(OTSClientError).SetErrorMessage("host of end_point should be specified, e.g. http://ots.aliyuncs.com:80.")
 				}
+				reset_transport = true
 
 			case "AccessId":
 				if v1, ok := v.(string); ok {
-					o.AccessId = v1
+					c.AccessId = v1
 				} else {
-					panic(OTSClientError{}.Set("AccessId should be string, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("AccessId should be string, not %v", reflect.TypeOf(v))
 				}
 
 			case "AccessKey":
 				if v1, ok := v.(string); ok {
-					o.AccessKey = v1
+					c.AccessKey = v1
 				} else {
-					panic(OTSClientError{}.Set("AccessKey should be string, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("AccessKey should be string, not %v", reflect.TypeOf(v))
 				}
 
 			case "InstanceName":
 				if v1, ok := v.(string); ok {
-					o.InstanceName = v1
+					c.InstanceName = v1
 				} else {
-					panic(OTSClientError{}.Set("InstanceName should be string, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("InstanceName should be string, not %v", reflect.TypeOf(v))
 				}
 
 			case "SocketTimeout":
 				if v1, ok := v.(int); ok {
-					o.SocketTimeout = v1
+					c.SocketTimeout = v1
+					reset_transport = true
 				} else {
-					panic(OTSClientError{}.Set("SocketTimeout should be int, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("SocketTimeout should be int, not %v", reflect.TypeOf(v))
 				}
 
 			case "MaxConnection":
 				if v1, ok := v.(int); ok {
-					o.MaxConnection = v1
+					c.MaxConnection = v1
+					reset_transport = true
 				} else {
-					panic(OTSClientError{}.Set("MaxConnection should be int, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("MaxConnection should be int, not %v", reflect.TypeOf(v))
 				}
 
 			case "LoggerName":
 				if v1, ok := v.(string); ok {
-					o.LoggerName = v1
+					c.LoggerName = v1
 				} else {
-					panic(OTSClientError{}.Set("LoggerName should be string, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("LoggerName should be string, not %v", reflect.TypeOf(v))
 				}
 
 			case "Encoding":
 				if v1, ok := v.(string); ok {
-					o.Encoding = v1
+					c.Encoding = v1
 				} else {
-					panic(OTSClientError{}.Set("Encoding should be string, not %v", reflect.TypeOf(v)))
+					return new(OTSClientError).SetErrorMessage("Encoding should be string, not %v", reflect.TypeOf(v))
+				}
+
+			case "Logger":
+				if v1, ok := v.(OTSLogger); ok {
+					c.Logger = v1
+				} else {
+					return new(OTSClientError).SetErrorMessage("Logger should be OTSLogger, not %v", reflect.TypeOf(v))
+				}
+
+			case "CredentialsProvider":
+				if v1, ok := v.(OTSCredentialsProvider); ok {
+					c.CredentialsProvider = v1
+				} else {
+					return new(OTSClientError).SetErrorMessage("CredentialsProvider should be OTSCredentialsProvider, not %v", reflect.TypeOf(v))
+				}
+
+			case "TLSConfig":
+				if v1, ok := v.(*tls.Config); ok {
+					c.TLSConfig = v1
+					reset_transport = true
+				} else {
+					return new(OTSClientError).SetErrorMessage("TLSConfig should be *tls.Config, not %v", reflect.TypeOf(v))
 				}
 
 			default:
-				panic(OTSClientError{}.Set("Unknown param %s", k))
+				return new(OTSClientError).SetErrorMessage("Unknown param %s", k)
 			}
 		}
 	}
+	if reset_transport {
+		c._init_transport()
+	}
+	*o = c
 
-	return o
+	return nil
 }
 
 func (o *OTSClient) _request_helper(api_name string, args ...interface{}) (resp []reflect.Value, ots_service_error *OTSServiceError) {
+	return o._request_helper_with_context(context.Background(), api_name, args...)
+}
+
+func (o *OTSClient) _request_helper_with_context(ctx context.Context, api_name string, args ...interface{}) (resp []reflect.Value, ots_service_error *OTSServiceError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// go through the interceptors
+	call := &OTSCall{Api: api_name, Args: args}
+	if ots_service_error = o._validate(ctx, call); ots_service_error != nil {
+		return nil, ots_service_error
+	}
+	ctx, span := o._start_span(ctx, "OTS "+api_name)
+	response, ots_service_error := o._get_invoker()(ctx, call)
+	o._end_call_span(span, call, response, ots_service_error)
+	o._schema_observe(call, response, ots_service_error)
+	if ots_service_error != nil {
+		return nil, ots_service_error
+	}
+	if response == nil {
+		return []reflect.Value{reflect.Zero(error_type)}, nil
+	}
+
+	return []reflect.Value{reflect.ValueOf(response), reflect.Zero(error_type)}, nil
+}
+
+// send the request of call with retries, every attempt is recorded in call
+func (o *OTSClient) _send_request(ctx context.Context, call *OTSCall) (resp []reflect.Value, ots_service_error *OTSServiceError) {
 	var reason string
 	var status int
 	var resheaders = DictString{}
@@ -439,28 +584,62 @@ func (o *OTSClient) _request_helper(api_name string, args ...interface{}) (resp
 	var req *urllib.HttpRequest
 
 	ots_service_error = new(OTSServiceError)
+	start_time := time.Now()
+	api_name := call.Api
+	args := o._order_primary_keys(api_name, call.Args)
 
 	// 1. make_request
-	query, reqheaders, reqbody, err := o.protocol.make_request(api_name, args...)
+	credentials, err := o._get_credentials(ctx)
 	if err != nil {
-		return nil, ots_service_error.SetErrorMessage("%s", err)
+		ots_service_error.SetErrorMessage("get credentials error: %s", err)
+		ots_service_error.Err = err
+		return nil, o._log_failure(ctx, api_name, 0, start_time, ots_service_error)
+	}
+	protocol := o.protocol._with_credentials(credentials)
+	protocol.ots_logger, protocol.log_fields = o._get_logger()
+	protocol.log_ctx = ctx
+	query, reqheaders, reqbody, err := protocol.make_request(api_name, args...)
+	if err != nil {
+		return nil, o._log_failure(ctx, api_name, 0, start_time, ots_service_error.SetErrorMessage("%s", err))
+	}
+
+	if ctx_err := ctx.Err(); ctx_err != nil {
+		return nil, o._log_failure(ctx, api_name, 0, start_time, _set_context_error(ots_service_error, ctx_err))
 	}
 
 	retry_times := 0
+	retry := o._new_retry_state(ctx, call, start_time)
+	defer func() { retry.done(retry_times, ots_service_error) }()
 
 	for {
+		var last_error *OTSServiceError
+		if retry_times > 0 {
+			last_error = ots_service_error
+		}
+		circuit_probe, circuit_error := o._circuit_allow(call, last_error)
+		if circuit_error != nil {
+			return nil, o._log_failure(ctx, api_name, retry_times, start_time, circuit_error)
+		}
+
+		attempt_time := time.Now()
+		attempt_ctx, attempt_span := o._start_span(ctx, "OTS "+api_name+" attempt")
+		o._log_debug(ctx, "OTS request", "api", api_name, "retry_times", retry_times, "body_size", len(reqbody))
+
 		// 2. http send_receive
-		end_point_url, _ := url.Parse(o.EndPoint)
-		if end_point_url.Scheme == "https" {
-			req = urllib.Post(o.EndPoint + query).SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+		req = urllib.Post(o.EndPoint + query)
+		if o.transport != nil {
+			req.SetTransport(o.transport)
 		} else {
-			req = urllib.Post(o.EndPoint + query)
+			// OTSClient not created by New
+			req.SetTLSClientConfig(o._get_tls_config())
 		}
+		req.SetTimeout(o._get_socket_timeout(), o._get_socket_timeout())
 		if OTSHttpDebugEnable {
 			req.Debug(true)
 		} else {
 			req.Debug(false)
 		}
+		req.SetContext(attempt_ctx)
 		req.Body(reqbody)
 		if reqheaders != nil {
 			for k, v := range reqheaders {
@@ -471,13 +650,18 @@ func (o *OTSClient) _request_helper(api_name string, args ...interface{}) (resp
 		if err != nil {
 			ots_service_error.SetErrorMessage("%s", err)
 			ots_service_error.Err = err
-			if o.RetryPolicy.ShouldRetry(retry_times, ots_service_error, api_name) {
-				retry_delay := o.RetryPolicy.GetRetryDelay(retry_times, ots_service_error, api_name)
-				time.Sleep(time.Duration(retry_delay*1000) * time.Millisecond)
+			o._circuit_record(call, circuit_probe, ots_service_error)
+			o._end_attempt(call, attempt_span, retry_times, attempt_time, 0, nil, ots_service_error)
+			if ctx.Err() == nil && retry.should_retry(retry_times, ots_service_error) {
+				retry_delay := retry.get_retry_delay(retry_times, ots_service_error)
+				o._log_retry(ctx, api_name, retry_times, retry_delay, ots_service_error)
+				if ctx_err := _retry_sleep(ctx, retry_delay); ctx_err != nil {
+					return nil, o._log_failure(ctx, api_name, retry_times, start_time, _set_context_error(ots_service_error, ctx_err))
+				}
 				retry_times += 1
 				continue
 			} else {
-				return nil, ots_service_error
+				return nil, o._log_failure(ctx, api_name, retry_times, start_time, ots_service_error)
 			}
 		}
 		status = response.StatusCode // e.g. 200
@@ -491,13 +675,18 @@ func (o *OTSClient) _request_helper(api_name string, args ...interface{}) (resp
 		if response.Body == nil {
 			ots_service_error.SetErrorMessage("Http body is empty")
 			ots_service_error.Err = ErrNonResponseBody
-			if o.RetryPolicy.ShouldRetry(retry_times, ots_service_error, api_name) {
-				retry_delay := o.RetryPolicy.GetRetryDelay(retry_times, ots_service_error, api_name)
-				time.Sleep(time.Duration(retry_delay*1000) * time.Millisecond)
+			o._circuit_record(call, circuit_probe, ots_service_error)
+			o._end_attempt(call, attempt_span, retry_times, attempt_time, status, resheaders, ots_service_error)
+			if ctx.Err() == nil && retry.should_retry(retry_times, ots_service_error) {
+				retry_delay := retry.get_retry_delay(retry_times, ots_service_error)
+				o._log_retry(ctx, api_name, retry_times, retry_delay, ots_service_error)
+				if ctx_err := _retry_sleep(ctx, retry_delay); ctx_err != nil {
+					return nil, o._log_failure(ctx, api_name, retry_times, start_time, _set_context_error(ots_service_error, ctx_err))
+				}
 				retry_times += 1
 				continue
 			} else {
-				return nil, ots_service_error
+				return nil, o._log_failure(ctx, api_name, retry_times, start_time, ots_service_error)
 			}
 		}
 		defer response.Body.Close()
@@ -505,48 +694,39 @@ func (o *OTSClient) _request_helper(api_name string, args ...interface{}) (resp
 		if err != nil {
 			ots_service_error.SetErrorMessage("%s", err)
 			ots_service_error.Err = ErrReadResponse
-			if o.RetryPolicy.ShouldRetry(retry_times, ots_service_error, api_name) {
-				retry_delay := o.RetryPolicy.GetRetryDelay(retry_times, ots_service_error, api_name)
-				time.Sleep(time.Duration(retry_delay*1000) * time.Millisecond)
+			o._circuit_record(call, circuit_probe, ots_service_error)
+			o._end_attempt(call, attempt_span, retry_times, attempt_time, status, resheaders, ots_service_error)
+			if ctx.Err() == nil && retry.should_retry(retry_times, ots_service_error) {
+				retry_delay := retry.get_retry_delay(retry_times, ots_service_error)
+				o._log_retry(ctx, api_name, retry_times, retry_delay, ots_service_error)
+				if ctx_err := _retry_sleep(ctx, retry_delay); ctx_err != nil {
+					return nil, o._log_failure(ctx, api_name, retry_times, start_time, _set_context_error(ots_service_error, ctx_err))
+				}
 				retry_times += 1
 				continue
 			} else {
-				return nil, ots_service_error
+				return nil, o._log_failure(ctx, api_name, retry_times, start_time, ots_service_error)
 			}
 		}
 
-		// for debug
-		if OTSDebugEnable {
-			fmt.Println("==== Aliyun OTS Response ====")
-			fmt.Println("status:", status)
-			fmt.Println("reason:", reason)
-			fmt.Println("headers:", resheaders)
-			// if resbody != nil {
-			// 	if len(resbody) == 0 {
-			// 		fmt.Println("body-raw:", "None")
-			// 		fmt.Println("body-string:", "None")
-			// 	} else {
-			// 		fmt.Println("body-raw:", resbody)
-			// 		fmt.Println("body-string:", string(resbody))
-			// 	}
-			//
-			// } else {
-			// 	fmt.Println("body-raw:", "None")
-			// 	fmt.Println("body-string:", "None")
-			// }
-			// fmt.Println("-----------------------------")
-		}
+		o._log_debug(ctx, "OTS response", "api", api_name, "retry_times", retry_times, "http_status", status,
+			"request_id", resheaders.Get("x-ots-requestid"), "latency", time.Since(attempt_time), "body_size", len(resbody))
 
 		// 3. handle_error
-		ots_service_error = o.protocol.handle_error(api_name, query, reason, status, resheaders, resbody)
+		ots_service_error = protocol.handle_error(api_name, query, reason, status, resheaders, resbody)
+		o._circuit_record(call, circuit_probe, ots_service_error)
+		o._end_attempt(call, attempt_span, retry_times, attempt_time, status, resheaders, ots_service_error)
 		if ots_service_error != nil {
-			if o.RetryPolicy.ShouldRetry(retry_times, ots_service_error, api_name) {
-				retry_delay := o.RetryPolicy.GetRetryDelay(retry_times, ots_service_error, api_name)
-				time.Sleep(time.Duration(retry_delay*1000) * time.Millisecond)
+			if ctx.Err() == nil && retry.should_retry(retry_times, ots_service_error) {
+				retry_delay := retry.get_retry_delay(retry_times, ots_service_error)
+				o._log_retry(ctx, api_name, retry_times, retry_delay, ots_service_error)
+				if ctx_err := _retry_sleep(ctx, retry_delay); ctx_err != nil {
+					return nil, o._log_failure(ctx, api_name, retry_times, start_time, _set_context_error(ots_service_error, ctx_err))
+				}
 				retry_times += 1
 				continue
 			} else {
-				return nil, ots_service_error
+				return nil, o._log_failure(ctx, api_name, retry_times, start_time, ots_service_error)
 			}
 		} else {
 			break
@@ -554,14 +734,54 @@ func (o *OTSClient) _request_helper(api_name string, args ...interface{}) (resp
 	} // end for
 
 	// 4. parse_response
-	resp, ots_service_error = o.protocol.parse_response(api_name, reason, status, resheaders, resbody)
+	resp, ots_service_error = protocol.parse_response(api_name, reason, status, resheaders, resbody)
 	if ots_service_error != nil {
-		return nil, ots_service_error
+		return nil, o._log_failure(ctx, api_name, retry_times, start_time, ots_service_error)
 	}
+	o._log_success(ctx, api_name, retry_times, start_time, resheaders, resp)
 
 	return resp, nil
 }
 
+// wait for the retry delay, return the error of ctx if it is done before that
+func _retry_sleep(ctx context.Context, retry_delay float64) error {
+	timer := time.NewTimer(time.Duration(retry_delay*1000) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// the request is canceled or its deadline is exceeded
+func _set_context_error(ots_service_error *OTSServiceError, ctx_err error) *OTSServiceError {
+	if ots_service_error.Message != "" {
+		ots_service_error.SetErrorMessage("%s, last error: %s", ctx_err, ots_service_error.Message)
+	} else {
+		ots_service_error.SetErrorMessage("%s", ctx_err)
+	}
+	ots_service_error.Err = ctx_err
+
+	return ots_service_error
+}
+
+// nil or a nil *OTSPrimaryKey or *OTSOrderedPrimaryKey
+func _is_nil_primary_key(primary_key OTSPrimaryKeyInterface) bool {
+	return GetPrimaryKeyList(primary_key) == nil
+}
+
+// the optional filter of GetRow and GetRange
+func _get_filter(filter []OTSColumnCondition) OTSColumnCondition {
+	if len(filter) == 0 {
+		return nil
+	}
+
+	return filter[0]
+}
+
 func (o *OTSClient) _check_request_helper_error(resp []reflect.Value) (r interface{}, e error) {
 	// parse the following two cases
 	// 1. (err error)
@@ -594,8 +814,6 @@ func (o *OTSClient) _check_request_helper_error(resp []reflect.Value) (r interfa
 	default:
 		return nil, errors.New("Illegal data parameters")
 	}
-
-	return nil, errors.New("The program will not perform here")
 }
 
 // 说明：根据表信息创建表。
@@ -625,6 +843,11 @@ func (o *OTSClient) _check_request_helper_error(resp []reflect.Value) (r interfa
 // 		ots_err := ots_client.CreateTable(table_meta, reserved_throughput)
 //
 func (o *OTSClient) CreateTable(table_meta *OTSTableMeta, reserved_throughput *OTSReservedThroughput) (err *OTSError) {
+	return o.CreateTableWithContext(context.Background(), table_meta, reserved_throughput)
+}
+
+// 说明：同CreateTable，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) CreateTableWithContext(ctx context.Context, table_meta *OTSTableMeta, reserved_throughput *OTSReservedThroughput) (err *OTSError) {
 	err = new(OTSError)
 	if table_meta == nil {
 		return err.SetClientMessage("[CreateTable] table_meta should not be nil")
@@ -633,7 +856,7 @@ func (o *OTSClient) CreateTable(table_meta *OTSTableMeta, reserved_throughput *O
 		return err.SetClientMessage("[CreateTable] reserved_throughput should not be nil")
 	}
 
-	resp, service_err := o._request_helper("CreateTable", table_meta, reserved_throughput)
+	resp, service_err := o._request_helper_with_context(ctx, "CreateTable", table_meta, reserved_throughput)
 	if service_err != nil {
 		return err.SetServiceError(service_err)
 	}
@@ -658,12 +881,17 @@ func (o *OTSClient) CreateTable(table_meta *OTSTableMeta, reserved_throughput *O
 // 		ots_client.DeleteTable("myTable")
 //
 func (o *OTSClient) DeleteTable(table_name string) (err *OTSError) {
+	return o.DeleteTableWithContext(context.Background(), table_name)
+}
+
+// 说明：同DeleteTable，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) DeleteTableWithContext(ctx context.Context, table_name string) (err *OTSError) {
 	err = new(OTSError)
 	if table_name == "" {
 		return err.SetClientMessage("[DeleteTable] table_name should not be empty")
 	}
 
-	resp, service_err := o._request_helper("DeleteTable", table_name)
+	resp, service_err := o._request_helper_with_context(ctx, "DeleteTable", table_name)
 	if service_err != nil {
 		return err.SetServiceError(service_err)
 	}
@@ -688,8 +916,13 @@ func (o *OTSClient) DeleteTable(table_name string) (err *OTSError) {
 // 		table_list, ots_err := ots_client.ListTable()
 //
 func (o *OTSClient) ListTable() (table_list *OTSListTableResponse, err *OTSError) {
+	return o.ListTableWithContext(context.Background())
+}
+
+// 说明：同ListTable，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) ListTableWithContext(ctx context.Context) (table_list *OTSListTableResponse, err *OTSError) {
 	err = new(OTSError)
-	resp, service_err := o._request_helper("ListTable")
+	resp, service_err := o._request_helper_with_context(ctx, "ListTable")
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -700,7 +933,12 @@ func (o *OTSClient) ListTable() (table_list *OTSListTableResponse, err *OTSError
 		return nil, err.SetClientMessage("[ListTable] %s", e)
 	}
 
-	return r.(*OTSListTableResponse), nil
+	response, ok := r.(*OTSListTableResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[ListTable] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：更新表属性，目前只支持修改预留读写吞吐量。
@@ -723,6 +961,11 @@ func (o *OTSClient) ListTable() (table_list *OTSListTableResponse, err *OTSError
 // 		update_response, ots_err := ots_client.UpdateTable("myTable", reserved_throughput)
 //
 func (o *OTSClient) UpdateTable(table_name string, reserved_throughput *OTSReservedThroughput) (update_table_response *OTSUpdateTableResponse, err *OTSError) {
+	return o.UpdateTableWithContext(context.Background(), table_name, reserved_throughput)
+}
+
+// 说明：同UpdateTable，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) UpdateTableWithContext(ctx context.Context, table_name string, reserved_throughput *OTSReservedThroughput) (update_table_response *OTSUpdateTableResponse, err *OTSError) {
 	err = new(OTSError)
 	if table_name == "" {
 		return nil, err.SetClientMessage("[UpdateTable] table_name should not be empty")
@@ -731,7 +974,7 @@ func (o *OTSClient) UpdateTable(table_name string, reserved_throughput *OTSReser
 		return nil, err.SetClientMessage("[UpdateTable] reserved_throughput should not be nil")
 	}
 
-	resp, service_err := o._request_helper("UpdateTable", table_name, reserved_throughput)
+	resp, service_err := o._request_helper_with_context(ctx, "UpdateTable", table_name, reserved_throughput)
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -741,7 +984,12 @@ func (o *OTSClient) UpdateTable(table_name string, reserved_throughput *OTSReser
 		return nil, err.SetClientMessage("[UpdateTable] %s ", e)
 	}
 
-	return r.(*OTSUpdateTableResponse), nil
+	response, ok := r.(*OTSUpdateTableResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[UpdateTable] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：获取表的描述信息。
@@ -758,12 +1006,17 @@ func (o *OTSClient) UpdateTable(table_name string, reserved_throughput *OTSReser
 // 		describe_response, ots_err := ots_client.DescribeTable("myTable")
 //
 func (o *OTSClient) DescribeTable(table_name string) (describe_table_response *OTSDescribeTableResponse, err *OTSError) {
+	return o.DescribeTableWithContext(context.Background(), table_name)
+}
+
+// 说明：同DescribeTable，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) DescribeTableWithContext(ctx context.Context, table_name string) (describe_table_response *OTSDescribeTableResponse, err *OTSError) {
 	err = new(OTSError)
 	if table_name == "" {
 		return nil, err.SetClientMessage("[DescribeTable] table_name should not be empty")
 	}
 
-	resp, service_err := o._request_helper("DescribeTable", table_name)
+	resp, service_err := o._request_helper_with_context(ctx, "DescribeTable", table_name)
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -773,14 +1026,20 @@ func (o *OTSClient) DescribeTable(table_name string) (describe_table_response *O
 		return nil, err.SetClientMessage("[DescribeTable] %s", e)
 	}
 
-	return r.(*OTSDescribeTableResponse), nil
+	response, ok := r.(*OTSDescribeTableResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[DescribeTable] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：获取一行数据。
 //
 // 		``table_name``是对应的表名。
-// 		``primary_key``是主键，类型为``otstype.OTSPrimaryKey``。
+// 		``primary_key``是主键，类型为``*otstype.OTSPrimaryKey``或``*otstype.OTSOrderedPrimaryKey``，多列主键应使用后者。
 // 		``columns_to_get``是可选参数，表示要获取的列的名称列表，类型为``otstype.OTSColumnsToGet``；如果填nil，表示获取所有列。
+// 		``filter``是可选参数，表示服务端过滤条件，类型为``otstype.OTSColumnCondition``；不满足条件时返回的行为空。
 //
 // 		返回：本次操作消耗的CapacityUnit、行数据（包含主键列和属性列）。
 // 		      错误信息。
@@ -803,16 +1062,28 @@ func (o *OTSClient) DescribeTable(table_name string) (describe_table_response *O
 // 		// columns_to_get = nil // read all
 // 		get_row_response, ots_err := ots_client.GetRow("myTable", primary_key, columns_to_get)
 //
-func (o *OTSClient) GetRow(table_name string, primary_key *OTSPrimaryKey, columns_to_get *OTSColumnsToGet) (get_row_response *OTSGetRowResponse, err *OTSError) {
+// 		// 只有当age大于18时才返回该行
+// 		filter := NewRelationCondition("age", OTSComparatorType_GREATER_THAN, 18, false)
+// 		get_row_response, ots_err = ots_client.GetRow("myTable", primary_key, columns_to_get, filter)
+//
+func (o *OTSClient) GetRow(table_name string, primary_key OTSPrimaryKeyInterface, columns_to_get *OTSColumnsToGet, filter ...OTSColumnCondition) (get_row_response *OTSGetRowResponse, err *OTSError) {
+	return o.GetRowWithContext(context.Background(), table_name, primary_key, columns_to_get, filter...)
+}
+
+// 说明：同GetRow，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) GetRowWithContext(ctx context.Context, table_name string, primary_key OTSPrimaryKeyInterface, columns_to_get *OTSColumnsToGet, filter ...OTSColumnCondition) (get_row_response *OTSGetRowResponse, err *OTSError) {
 	err = new(OTSError)
 	if table_name == "" {
 		return nil, err.SetClientMessage("[GetRow] table_name should not be empty")
 	}
-	if primary_key == nil {
+	if _is_nil_primary_key(primary_key) {
 		return nil, err.SetClientMessage("[GetRow] primary_key should not be nil")
 	}
+	if len(filter) > 1 {
+		return nil, err.SetClientMessage("[GetRow] only one filter is allowed, use OTSCompositeCondition to combine them")
+	}
 
-	resp, service_err := o._request_helper("GetRow", table_name, primary_key, columns_to_get)
+	resp, service_err := o._request_helper_with_context(ctx, "GetRow", table_name, primary_key, columns_to_get, _get_filter(filter))
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -822,15 +1093,21 @@ func (o *OTSClient) GetRow(table_name string, primary_key *OTSPrimaryKey, column
 		return nil, err.SetClientMessage("[GetRow] %s", e)
 	}
 
-	return r.(*OTSGetRowResponse), nil
+	response, ok := r.(*OTSGetRowResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[GetRow] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：写入一行数据。返回本次操作消耗的CapacityUnit。
 //
 // 		``table_name``是对应的表名。
-// 		``condition``表示执行操作前做条件检查，满足条件才执行，是string的实例。
-// 		目前只支持对行的存在性进行检查，检查条件包括：'IGNORE'，'EXPECT_EXIST'和'EXPECT_NOT_EXIST'。
-// 		``primary_key``表示主键，类型为``otstype.OTSPrimaryKey``的实例。
+// 		``condition``表示执行操作前做条件检查，满足条件才执行，是string或``otstype.OTSCondition``的实例。
+// 		string表示对行的存在性进行检查，检查条件包括：'IGNORE'，'EXPECT_EXIST'和'EXPECT_NOT_EXIST'。
+// 		``otstype.OTSCondition``在行存在性检查的基础上还可以指定列条件，列条件满足时才执行，可用于实现乐观锁。
+// 		``primary_key``表示主键，类型为``*otstype.OTSPrimaryKey``或``*otstype.OTSOrderedPrimaryKey``，多列主键应使用后者。
 // 		``attribute_columns``表示属性列，类型为``otstype.OTSAttribute``的实例。
 //
 // 		返回：本次操作消耗的CapacityUnit。
@@ -854,7 +1131,12 @@ func (o *OTSClient) GetRow(table_name string, primary_key *OTSPrimaryKey, column
 // 		condition := OTSCondition_EXPECT_NOT_EXIST
 // 		put_row_response, ots_err := ots_client.PutRow("myTable", condition, primary_key, attribute_columns)
 //
-func (o *OTSClient) PutRow(table_name string, condition interface{}, primary_key *OTSPrimaryKey, attribute_columns *OTSAttribute) (put_row_response *OTSPutRowResponse, err *OTSError) {
+func (o *OTSClient) PutRow(table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface, attribute_columns *OTSAttribute) (put_row_response *OTSPutRowResponse, err *OTSError) {
+	return o.PutRowWithContext(context.Background(), table_name, condition, primary_key, attribute_columns)
+}
+
+// 说明：同PutRow，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) PutRowWithContext(ctx context.Context, table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface, attribute_columns *OTSAttribute) (put_row_response *OTSPutRowResponse, err *OTSError) {
 	err = new(OTSError)
 	if table_name == "" {
 		return nil, err.SetClientMessage("[PutRow] table_name should not be empty")
@@ -862,14 +1144,14 @@ func (o *OTSClient) PutRow(table_name string, condition interface{}, primary_key
 	if condition == nil {
 		return nil, err.SetClientMessage("[PutRow] condition should not be nil")
 	}
-	if primary_key == nil {
+	if _is_nil_primary_key(primary_key) {
 		return nil, err.SetClientMessage("[PutRow] primary_key should not be nil")
 	}
 	if attribute_columns == nil {
 		return nil, err.SetClientMessage("[PutRow] attribute_columns should not be nil")
 	}
 
-	resp, service_err := o._request_helper("PutRow", table_name, condition, primary_key, attribute_columns)
+	resp, service_err := o._request_helper_with_context(ctx, "PutRow", table_name, condition, primary_key, attribute_columns)
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -879,15 +1161,21 @@ func (o *OTSClient) PutRow(table_name string, condition interface{}, primary_key
 		return nil, err.SetClientMessage("[PutRow] %s", e)
 	}
 
-	return r.(*OTSPutRowResponse), nil
+	response, ok := r.(*OTSPutRowResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[PutRow] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：更新一行数据。
 //
 // 		``table_name``是对应的表名。
-// 		``condition``表示执行操作前做条件检查，满足条件才执行，是string的实例。
-// 		目前只支持对行的存在性进行检查，检查条件包括：'IGNORE'，'EXPECT_EXIST'和'EXPECT_NOT_EXIST'。
-// 		``primary_key``表示主键，类型为``otstype.OTSPrimaryKey``的实例。
+// 		``condition``表示执行操作前做条件检查，满足条件才执行，是string或``otstype.OTSCondition``的实例。
+// 		string表示对行的存在性进行检查，检查条件包括：'IGNORE'，'EXPECT_EXIST'和'EXPECT_NOT_EXIST'。
+// 		``otstype.OTSCondition``在行存在性检查的基础上还可以指定列条件，列条件满足时才执行，可用于实现乐观锁。
+// 		``primary_key``表示主键，类型为``*otstype.OTSPrimaryKey``或``*otstype.OTSOrderedPrimaryKey``，多列主键应使用后者。
 // 		``update_of_attribute_columns``表示属性列，类型为``otstype.OTSUpdateOfAttribute``的实例，可以包含put和delete操作。其中put是
 // 		``otstype.OTSColumnsToPut`` 表示属性列的写入；delete是``otstype.OTSColumnsToDelete``，表示要删除的属性列的列名，
 // 		见示例。
@@ -917,7 +1205,17 @@ func (o *OTSClient) PutRow(table_name string, condition interface{}, primary_key
 // 		condition := OTSCondition_EXPECT_EXIST
 // 		update_row_response, ots_err := ots_client.UpdateRow("myTable", condition, primary_key, update_of_attribute_columns)
 //
-func (o *OTSClient) UpdateRow(table_name string, condition interface{}, primary_key *OTSPrimaryKey, update_of_attribute_columns *OTSUpdateOfAttribute) (update_row_response *OTSUpdateRowResponse, err *OTSError) {
+// 		// 仅当行存在且version等于7时才更新
+// 		condition_with_version := NewCondition(OTSCondition_EXPECT_EXIST,
+// 			NewRelationCondition("version", OTSComparatorType_EQUAL, 7, false))
+// 		update_row_response, ots_err = ots_client.UpdateRow("myTable", condition_with_version, primary_key, update_of_attribute_columns)
+//
+func (o *OTSClient) UpdateRow(table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface, update_of_attribute_columns *OTSUpdateOfAttribute) (update_row_response *OTSUpdateRowResponse, err *OTSError) {
+	return o.UpdateRowWithContext(context.Background(), table_name, condition, primary_key, update_of_attribute_columns)
+}
+
+// 说明：同UpdateRow，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) UpdateRowWithContext(ctx context.Context, table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface, update_of_attribute_columns *OTSUpdateOfAttribute) (update_row_response *OTSUpdateRowResponse, err *OTSError) {
 	err = new(OTSError)
 	if table_name == "" {
 		return nil, err.SetClientMessage("[UpdateRow] table_name should not be empty")
@@ -925,14 +1223,14 @@ func (o *OTSClient) UpdateRow(table_name string, condition interface{}, primary_
 	if condition == nil {
 		return nil, err.SetClientMessage("[UpdateRow] condition should not be nil")
 	}
-	if primary_key == nil {
+	if _is_nil_primary_key(primary_key) {
 		return nil, err.SetClientMessage("[UpdateRow] primary_key should not be nil")
 	}
 	if update_of_attribute_columns == nil {
 		return nil, err.SetClientMessage("[UpdateRow] update_of_attribute_columns should not be nil")
 	}
 
-	resp, service_err := o._request_helper("UpdateRow", table_name, condition, primary_key, update_of_attribute_columns)
+	resp, service_err := o._request_helper_with_context(ctx, "UpdateRow", table_name, condition, primary_key, update_of_attribute_columns)
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -942,15 +1240,21 @@ func (o *OTSClient) UpdateRow(table_name string, condition interface{}, primary_
 		return nil, err.SetClientMessage("[UpdateRow] %s", e)
 	}
 
-	return r.(*OTSUpdateRowResponse), nil
+	response, ok := r.(*OTSUpdateRowResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[UpdateRow] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：删除一行数据。
 //
 // 		``table_name``是对应的表名。
-// 		``condition``表示执行操作前做条件检查，满足条件才执行，是string的实例。
-// 		目前只支持对行的存在性进行检查，检查条件包括：'IGNORE'，'EXPECT_EXIST'和'EXPECT_NOT_EXIST'。
-// 		``primary_key``表示主键，类型为``otstype.OTSPrimaryKey``的实例。
+// 		``condition``表示执行操作前做条件检查，满足条件才执行，是string或``otstype.OTSCondition``的实例。
+// 		string表示对行的存在性进行检查，检查条件包括：'IGNORE'，'EXPECT_EXIST'和'EXPECT_NOT_EXIST'。
+// 		``otstype.OTSCondition``在行存在性检查的基础上还可以指定列条件，列条件满足时才执行，可用于实现乐观锁。
+// 		``primary_key``表示主键，类型为``*otstype.OTSPrimaryKey``或``*otstype.OTSOrderedPrimaryKey``，多列主键应使用后者。
 //
 // 		返回：本次操作消耗的CapacityUnit。
 // 		      错误信息。
@@ -967,7 +1271,12 @@ func (o *OTSClient) UpdateRow(table_name string, condition interface{}, primary_
 // 		condition := OTSCondition_IGNORE
 // 		delete_row_response, ots_err := ots_client.DeleteRow("myTable", condition, primary_key)
 //
-func (o *OTSClient) DeleteRow(table_name string, condition interface{}, primary_key *OTSPrimaryKey) (delete_row_response *OTSDeleteRowResponse, err *OTSError) {
+func (o *OTSClient) DeleteRow(table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface) (delete_row_response *OTSDeleteRowResponse, err *OTSError) {
+	return o.DeleteRowWithContext(context.Background(), table_name, condition, primary_key)
+}
+
+// 说明：同DeleteRow，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) DeleteRowWithContext(ctx context.Context, table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface) (delete_row_response *OTSDeleteRowResponse, err *OTSError) {
 	err = new(OTSError)
 	if table_name == "" {
 		return nil, err.SetClientMessage("[DeleteRow] table_name should not be empty")
@@ -975,11 +1284,11 @@ func (o *OTSClient) DeleteRow(table_name string, condition interface{}, primary_
 	if condition == nil {
 		return nil, err.SetClientMessage("[DeleteRow] condition should not be nil")
 	}
-	if primary_key == nil {
+	if _is_nil_primary_key(primary_key) {
 		return nil, err.SetClientMessage("[DeleteRow] primary_key should not be nil")
 	}
 
-	resp, service_err := o._request_helper("DeleteRow", table_name, condition, primary_key)
+	resp, service_err := o._request_helper_with_context(ctx, "DeleteRow", table_name, condition, primary_key)
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -989,7 +1298,12 @@ func (o *OTSClient) DeleteRow(table_name string, condition interface{}, primary_
 		return nil, err.SetClientMessage("[DeleteRow] %s", e)
 	}
 
-	return r.(*OTSDeleteRowResponse), nil
+	response, ok := r.(*OTSDeleteRowResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[DeleteRow] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：批量获取多行数据。
@@ -1020,11 +1334,15 @@ func (o *OTSClient) DeleteRow(table_name string, condition interface{}, primary_
 // 				},
 // 				// ColumnsToGet
 // 				ColumnsToGet: OTSColumnsToGet{"name", "address", "mobile", "age"},
+// 				// Filter，可选
+// 				Filter: NewRelationCondition("age", OTSComparatorType_GREATER_THAN, 18, false),
 // 			},
 // 			...
 // 		}
 //
 // 		其中，Rows 为主键，类型为``otstype.OTSPrimaryKeyRows``。
+// 		Filter 为可选的服务端过滤条件，类型为``otstype.OTSColumnCondition``。
+// 		SchemaOfPrimaryKey 为可选的表的主键列描述，Rows 按此顺序发送，为nil 时按缓存的表结构或列名排序。
 //
 // 		返回：对应行的结果列表。
 // 		      错误信息
@@ -1080,12 +1398,17 @@ func (o *OTSClient) DeleteRow(table_name string, condition interface{}, primary_
 // 		batch_get_response, ots_err := ots_client.BatchGetRow(batch_list_get)
 //
 func (o *OTSClient) BatchGetRow(batch_list *OTSBatchGetRowRequest) (response_rows_list *OTSBatchGetRowResponse, err *OTSError) {
+	return o.BatchGetRowWithContext(context.Background(), batch_list)
+}
+
+// 说明：同BatchGetRow，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) BatchGetRowWithContext(ctx context.Context, batch_list *OTSBatchGetRowRequest) (response_rows_list *OTSBatchGetRowResponse, err *OTSError) {
 	err = new(OTSError)
 	if batch_list == nil {
 		return nil, err.SetClientMessage("[BatchGetRow] primary_key should not be nil")
 	}
 
-	resp, service_err := o._request_helper("BatchGetRow", batch_list)
+	resp, service_err := o._request_helper_with_context(ctx, "BatchGetRow", batch_list)
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -1095,7 +1418,12 @@ func (o *OTSClient) BatchGetRow(batch_list *OTSBatchGetRowRequest) (response_row
 		return nil, err.SetClientMessage("[BatchGetRow] %s", e)
 	}
 
-	return r.(*OTSBatchGetRowResponse), nil
+	response, ok := r.(*OTSBatchGetRowResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[BatchGetRow] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：批量修改多行数据。
@@ -1133,6 +1461,7 @@ func (o *OTSClient) BatchGetRow(batch_list *OTSBatchGetRowRequest) (response_row
 // 		其中，put_row_item, 是``otstype.OTSPutRows``类的实例；
 // 		      update_row_item, 是``otstype.OTSUpdateRows``类的实例；
 // 		      delete_row_item, 是``otstype.OTSDeleteRows``类的实例。
+// 		每一行的Condition表示行存在性条件，可选的ColumnCondition表示列条件，与PutRow、UpdateRow和DeleteRow使用的``otstype.OTSCondition``相同。
 //
 // 		返回：对应行的修改结果列表。
 // 		      错误信息。
@@ -1237,12 +1566,17 @@ func (o *OTSClient) BatchGetRow(batch_list *OTSBatchGetRowRequest) (response_row
 // 		batch_write_response, ots_err := ots_client.BatchWriteRow(batch_list)
 //
 func (o *OTSClient) BatchWriteRow(batch_list *OTSBatchWriteRowRequest) (response_item_list *OTSBatchWriteRowResponse, err *OTSError) {
+	return o.BatchWriteRowWithContext(context.Background(), batch_list)
+}
+
+// 说明：同BatchWriteRow，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) BatchWriteRowWithContext(ctx context.Context, batch_list *OTSBatchWriteRowRequest) (response_item_list *OTSBatchWriteRowResponse, err *OTSError) {
 	err = new(OTSError)
 	if batch_list == nil {
 		return nil, err.SetClientMessage("[BatchWriteRow] primary_key should not be nil")
 	}
 
-	resp, service_err := o._request_helper("BatchWriteRow", batch_list)
+	resp, service_err := o._request_helper_with_context(ctx, "BatchWriteRow", batch_list)
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -1252,7 +1586,12 @@ func (o *OTSClient) BatchWriteRow(batch_list *OTSBatchWriteRowRequest) (response
 		return nil, err.SetClientMessage("[BatchWriteRow] %s", e)
 	}
 
-	return r.(*OTSBatchWriteRowResponse), nil
+	response, ok := r.(*OTSBatchWriteRowResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[BatchWriteRow] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
 // 说明：根据范围条件获取多行数据。
@@ -1261,8 +1600,12 @@ func (o *OTSClient) BatchWriteRow(batch_list *OTSBatchWriteRowRequest) (response
 // 		``direction``表示范围的方向，字符串格式，取值包括'FORWARD'和'BACKWARD'。
 // 		``inclusive_start_primary_key``表示范围的起始主键（在范围内）。
 // 		``exclusive_end_primary_key``表示范围的结束主键（不在范围内）。
+// 		主键类型为``*otstype.OTSPrimaryKey``或``*otstype.OTSOrderedPrimaryKey``，多列主键应使用后者，下一页可以使用``NextStartOrderedPrimaryKey``。
+// 		FORWARD 时起始主键不能大于结束主键，BACKWARD 时不能小于，否则不发送请求直接返回错误；
+// 		多列的``*otstype.OTSPrimaryKey``按表的主键顺序检查，表结构来自缓存，没有缓存时调用DescribeTable 获取，获取失败时返回错误。
 // 		``columns_to_get``是可选参数，表示要获取的列的名称列表，类型为``otstype.OTSColumnsToGet``；如果为nil，表示获取所有列。
 // 		``limit``是可选参数，表示最多读取多少行；如果为0，则没有限制。
+// 		``filter``是可选参数，表示服务端过滤条件，类型为``otstype.OTSColumnCondition``；不满足条件的行不会返回。
 //
 // 		返回：符合条件的结果列表。
 // 		      错误信息。
@@ -1294,11 +1637,30 @@ func (o *OTSClient) BatchWriteRow(batch_list *OTSBatchWriteRowRequest) (response
 // 		response_row_list, ots_err := ots_client.GetRange("myTable", OTSDirection_FORWARD,
 // 			inclusive_start_primary_key, exclusive_end_primary_key, columns_to_get, 100)
 //
+// 		// 只返回 name 为 "张三" 或 age 小于 20 的行
+// 		filter := NewOrCondition(
+// 			NewRelationCondition("name", OTSComparatorType_EQUAL, "张三", false),
+// 			NewRelationCondition("age", OTSComparatorType_LESS_THAN, 20, false),
+// 		)
+// 		response_row_list, ots_err = ots_client.GetRange("myTable", OTSDirection_FORWARD,
+// 			inclusive_start_primary_key, exclusive_end_primary_key, columns_to_get, 100, filter)
+//
 func (o *OTSClient) GetRange(table_name string, direction string,
-	inclusive_start_primary_key *OTSPrimaryKey,
-	exclusive_end_primary_key *OTSPrimaryKey,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
 	columns_to_get *OTSColumnsToGet,
-	limit int32) (response_row_list *OTSGetRangeResponse, err *OTSError) {
+	limit int32,
+	filter ...OTSColumnCondition) (response_row_list *OTSGetRangeResponse, err *OTSError) {
+	return o.GetRangeWithContext(context.Background(), table_name, direction, inclusive_start_primary_key, exclusive_end_primary_key, columns_to_get, limit, filter...)
+}
+
+// 说明：同GetRange，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) GetRangeWithContext(ctx context.Context, table_name string, direction string,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
+	columns_to_get *OTSColumnsToGet,
+	limit int32,
+	filter ...OTSColumnCondition) (response_row_list *OTSGetRangeResponse, err *OTSError) {
 	err = new(OTSError)
 	if table_name == "" {
 		return nil, err.SetClientMessage("[GetRange] table_name should not be empty")
@@ -1306,11 +1668,20 @@ func (o *OTSClient) GetRange(table_name string, direction string,
 	if direction != OTSDirection_FORWARD && direction != OTSDirection_BACKWARD {
 		return nil, err.SetClientMessage("[GetRange] direction should be FORWARD or BACKWARD")
 	}
-	if exclusive_end_primary_key == nil {
+	if _is_nil_primary_key(inclusive_start_primary_key) {
+		return nil, err.SetClientMessage("[GetRange] inclusive_start_primary_key should not be nil")
+	}
+	if _is_nil_primary_key(exclusive_end_primary_key) {
 		return nil, err.SetClientMessage("[GetRange] exclusive_end_primary_key should not be nil")
 	}
+	if ots_err := o._validate_range(ctx, table_name, direction, inclusive_start_primary_key, exclusive_end_primary_key); ots_err != nil {
+		return nil, ots_err
+	}
+	if len(filter) > 1 {
+		return nil, err.SetClientMessage("[GetRange] only one filter is allowed, use OTSCompositeCondition to combine them")
+	}
 
-	resp, service_err := o._request_helper("GetRange", table_name, direction, inclusive_start_primary_key, exclusive_end_primary_key, columns_to_get, limit)
+	resp, service_err := o._request_helper_with_context(ctx, "GetRange", table_name, direction, inclusive_start_primary_key, exclusive_end_primary_key, columns_to_get, limit, _get_filter(filter))
 	if service_err != nil {
 		return nil, err.SetServiceError(service_err)
 	}
@@ -1320,12 +1691,50 @@ func (o *OTSClient) GetRange(table_name string, direction string,
 		return nil, err.SetClientMessage("[GetRange] %s", e)
 	}
 
-	return r.(*OTSGetRangeResponse), nil
+	response, ok := r.(*OTSGetRangeResponse)
+	if !ok || response == nil {
+		return nil, err.SetClientMessage("[GetRange] unexpected response %T", r)
+	}
+
+	return response, nil
 }
 
-// func (o *OTSClient) XGetRange() {
-//
-// }
+// check the range with the direction, OTSPrimaryKey and *OTSPrimaryKey with more than one column are
+// ordered by the schema of table, DescribeTable is called if the schema is not cached
+func (o *OTSClient) _validate_range(ctx context.Context, table_name string, direction string, inclusive_start_primary_key, exclusive_end_primary_key OTSPrimaryKeyInterface) *OTSError {
+	var schema_of_primary_key OTSSchemaOfPrimaryKey
+	primary_keys := []OTSPrimaryKeyInterface{inclusive_start_primary_key, exclusive_end_primary_key}
+	for i, primary_key := range primary_keys {
+		var pk OTSPrimaryKey
+		switch v := primary_key.(type) {
+		case *OTSPrimaryKey:
+			pk = *v
+		case OTSPrimaryKey:
+			pk = v
+		}
+		if len(pk) <= 1 {
+			// a single column has only one order
+			continue
+		}
+		if schema_of_primary_key == nil {
+			var ots_err *OTSError
+			if schema_of_primary_key, ots_err = o._get_schema_of_primary_key(ctx, "GetRange", table_name); ots_err != nil {
+				return ots_err
+			}
+		}
+		ordered, err := NewPrimaryKeyFromSchema(schema_of_primary_key, pk)
+		if err != nil {
+			return new(OTSError).SetClientMessage("[GetRange] %s", err)
+		}
+		primary_keys[i] = ordered
+	}
+
+	if err := ValidateRange(direction, primary_keys[0], primary_keys[1]); err != nil {
+		return new(OTSError).SetClientMessage("[GetRange] %s", err)
+	}
+
+	return nil
+}
 
 func (o *OTSClient) Version() string {
 	return "ots_golang_sdk_" + VERSION
@@ -8,6 +8,7 @@
 package goots
 
 import (
+	"context"
 	"fmt"
 	. "github.com/GiterLab/goots/otstype"
 	"github.com/GiterLab/goots/urllib"
@@ -15,6 +16,7 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 )
 
 func newWithRetry(tableName string) (*OTSClient, error) {
@@ -37,7 +39,7 @@ func newWithRetry(tableName string) (*OTSClient, error) {
 		},
 	}
 	reservedThroughput := &OTSReservedThroughput{
-		OTSCapacityUnit{0, 0},
+		CapacityUnit: OTSCapacityUnit{Read: 0, Write: 0},
 	}
 	if e := o.CreateTable(tableMeta, reservedThroughput); e != nil {
 		return o, e
@@ -224,6 +226,44 @@ func Test_NewWithRetry_5xxError(t *testing.T) {
 	}
 }
 
+func Test_ListTableWithContext_Deadline(t *testing.T) {
+	OTSErrorPanicMode = false
+	client, err := New("http://127.0.0.1:8800", "OTSMultiUser177_accessid", "OTSMultiUser177_accesskey", "TestInstance177")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urllib.MockResp.Reset()
+	urllib.MockResp.MockFunc = func(m *urllib.MockResponse, b *urllib.HttpRequest) (*http.Response, error) {
+		return m.Mock5xxError()
+	}
+	defer func() {
+		urllib.MockResp.MockFunc = nil
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, ots_err := client.ListTableWithContext(ctx)
+	if ots_err == (*OTSError)(nil) || ots_err.ServiceError == nil {
+		t.Fatal("list table should fail when deadline exceeded")
+	}
+	if ots_err.ServiceError.Err != context.DeadlineExceeded {
+		t.Errorf("error excpected %v but %v", context.DeadlineExceeded, ots_err.ServiceError.Err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retry should stop at the deadline, but took %s", elapsed)
+	}
+
+	// canceled context should not send any request
+	urllib.MockResp.Reset()
+	cancel()
+	_, ots_err = client.ListTableWithContext(ctx)
+	if ots_err == (*OTSError)(nil) || urllib.MockResp.Count != 0 {
+		t.Errorf("number of request excpected %d but %d", 0, urllib.MockResp.Count)
+	}
+}
+
 func Test_New(t *testing.T) {
 	o, err := New("http://127.0.0.1:8800", "OTSMultiUser177_accessid", "OTSMultiUser177_accesskey", "TestInstance177")
 	if err != nil {
@@ -0,0 +1,104 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test typed column value for ots2
+package goots
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_column_value(t *testing.T) {
+	if v, err := NewColumnValue(uint64(math.MaxInt64)); err != nil || v.Type() != OTSColumnType_INTEGER {
+		t.Fatalf("unexpected value: %v, %v", v, err)
+	}
+	if _, err := NewColumnValue(uint64(math.MaxInt64) + 1); err == nil {
+		t.Fatal("uint64 overflowing int64 should fail")
+	}
+	if _, err := NewColumnValue(complex(1, 1)); err == nil {
+		t.Fatal("complex128 should fail")
+	}
+	if _, err := NewColumnValue(OTSColumnValue{}); err == nil {
+		t.Fatal("column value without type should fail")
+	}
+
+	// bool is not an integer
+	v, err := NewColumnValue(true)
+	if err != nil || v.Type() != OTSColumnType_BOOLEAN {
+		t.Fatalf("unexpected value: %v, %v", v, err)
+	}
+	if _, err = v.AsInteger(); err == nil {
+		t.Fatal("BOOLEAN should not be converted to INTEGER")
+	}
+
+	v = NewIntegerValue(math.MaxInt32 + 1)
+	if _, err = v.AsInt32(); err == nil {
+		t.Fatal("int64 overflowing int32 should fail")
+	}
+	if _, err = NewIntegerValue(-1).AsUint64(); err == nil {
+		t.Fatal("negative integer should not be converted to uint64")
+	}
+	if v, err = NewColumnValue(OTSColumnType_INF_MIN); err != nil || !v.IsInfMin() || v.Interface() != OTSColumnType_INF_MIN {
+		t.Fatalf("unexpected value: %v, %v", v, err)
+	}
+}
+
+func Test_column_value_row(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	primary_key := &OTSPrimaryKey{
+		"gid": NewIntegerValue(1),
+		"uid": uint8(101),
+	}
+	attribute_columns := &OTSAttribute{
+		"name":    NewStringValue("张三"),
+		"mobile":  NewIntegerValue(111111111),
+		"married": NewBooleanValue(false),
+		"price":   NewDoubleValue(11.11),
+		"avatar":  NewBinaryValue([]byte{1, 2, 3}),
+	}
+	if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, primary_key, attribute_columns); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// the value is not truncated
+	if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, primary_key, &OTSAttribute{"age": uint64(math.MaxUint64)}); ots_err == nil || !strings.Contains(ots_err.Error(), "overflows int64") {
+		t.Fatalf("uint64 overflowing int64 should fail, not %v", ots_err)
+	}
+
+	get_row_response, ots_err := client.GetRow("myTable", &OTSPrimaryKey{"gid": 1, "uid": 101}, nil)
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	row := get_row_response.Row
+	if uid, err := row.GetPrimaryKeyValue("uid"); err != nil || uid.Interface() != int64(101) {
+		t.Fatalf("unexpected uid: %v, %v", uid, err)
+	}
+	if name, err := row.GetAttributeValue("name"); err != nil || name.String() != "张三" {
+		t.Fatalf("unexpected name: %v, %v", name, err)
+	}
+	if price, err := row.GetAttributeValue("price"); err != nil || price.Type() != OTSColumnType_DOUBLE {
+		t.Fatalf("unexpected price: %v, %v", price, err)
+	} else if v, err := price.AsDouble(); err != nil || v != 11.11 {
+		t.Fatalf("unexpected price: %v, %v", v, err)
+	}
+	avatar, _ := row.GetAttributeValue("avatar")
+	if v, err := avatar.AsBinary(); err != nil || !bytes.Equal(v, []byte{1, 2, 3}) {
+		t.Fatalf("unexpected avatar: %v, %v", v, err)
+	}
+	if _, err := row.GetAttributeValue("age"); err == nil {
+		t.Fatal("missing column should fail")
+	}
+
+	// the map keeps the Go values
+	if row.GetAttributeColumns()["married"] != false || row.GetAttributeColumns()["mobile"] != int64(111111111) {
+		t.Fatalf("unexpected attribute columns: %v", row.GetAttributeColumns())
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test primary key comparison for ots2
+package goots
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_compare_primary_key(t *testing.T) {
+	values := []OTSColumnValue{
+		NewInfMinValue(),
+		NewIntegerValue(-1),
+		NewIntegerValue(2),
+		NewStringValue(""),
+		NewStringValue("a"),
+		NewStringValue("ab"),
+		NewStringValue("b"),
+		NewBinaryValue([]byte{0}),
+		NewInfMaxValue(),
+	}
+	for i := range values {
+		for j := range values {
+			c := CompareColumnValue(values[i], values[j])
+			if (i < j && c != -1) || (i == j && c != 0) || (i > j && c != 1) {
+				t.Fatalf("unexpected %d comparing %v and %v", c, values[i], values[j])
+			}
+		}
+	}
+
+	a := &OTSOrderedPrimaryKey{{K: "uid", V: 1}, {K: "gid", V: OTSColumnType_INF_MAX}}
+	b := &OTSOrderedPrimaryKey{{K: "uid", V: NewIntegerValue(2)}, {K: "gid", V: 0}}
+	if c, err := ComparePrimaryKey(a, b); err != nil || c != -1 {
+		t.Fatalf("unexpected result: %d, %v", c, err)
+	}
+	if _, err := ComparePrimaryKey(a, &OTSOrderedPrimaryKey{{K: "gid", V: 1}, {K: "uid", V: 1}}); err == nil {
+		t.Fatal("different columns should fail")
+	}
+	if _, err := ComparePrimaryKey(a, &OTSOrderedPrimaryKey{{K: "uid", V: 1}}); err == nil {
+		t.Fatal("different number of columns should fail")
+	}
+	if c, err := ComparePrimaryKey(&OTSPrimaryKey{"gid": 1, "uid": "a"}, &OTSPrimaryKey{"uid": "a", "gid": int64(1)}); err != nil || c != 0 {
+		t.Fatalf("unexpected result: %d, %v", c, err)
+	}
+
+	if err := ValidateRange(OTSDirection_FORWARD, a, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateRange(OTSDirection_BACKWARD, a, b); err == nil {
+		t.Fatal("inverted range should fail")
+	}
+	if err := ValidateRange(OTSDirection_BACKWARD, b, a); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_get_range_inverted(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	attempts := 0
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		attempts += 1
+		return 0, "", ""
+	}
+
+	start := &OTSOrderedPrimaryKey{{K: "gid", V: 4}, {K: "uid", V: OTSColumnType_INF_MIN}}
+	end := &OTSOrderedPrimaryKey{{K: "gid", V: 1}, {K: "uid", V: OTSColumnType_INF_MAX}}
+	if _, ots_err := client.GetRange("myTable", OTSDirection_FORWARD, start, end, nil, 0); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("inverted range should fail on client, not %v", ots_err)
+	}
+	if attempts != 0 {
+		t.Fatalf("inverted range should not be sent, %d", attempts)
+	}
+	if _, ots_err := client.GetRange("myTable", OTSDirection_BACKWARD, start, end, nil, 0); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	map_start := &OTSPrimaryKey{"gid": 4, "uid": OTSColumnType_INF_MIN}
+	map_end := &OTSPrimaryKey{"gid": 1, "uid": OTSColumnType_INF_MAX}
+	if _, ots_err := client.ParallelScan("myTable", map_start, map_end, nil, 2, func(row *OTSRow) error { return nil }); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("inverted range should fail on client, not %v", ots_err)
+	}
+}
+
+func Test_get_range_schema_order(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	table_meta := &OTSTableMeta{
+		TableName: "uidTable",
+		SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{
+			{K: "uid", V: "INTEGER"},
+			{K: "gid", V: "INTEGER"},
+		},
+	}
+	if ots_err := client.CreateTable(table_meta, &OTSReservedThroughput{CapacityUnit: OTSCapacityUnit{Read: 100, Write: 100}}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	describe_error := ""
+	requests := map[string]int{}
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		requests[api_name] += 1
+		if api_name == "DescribeTable" && describe_error != "" {
+			return 403, describe_error, "DescribeTable is not allowed."
+		}
+		return 0, "", ""
+	}
+
+	// gid is greater in the order of names, but uid is the first column
+	start := &OTSPrimaryKey{"uid": 1, "gid": 5}
+	end := OTSPrimaryKey{"uid": 2, "gid": 3}
+
+	// without Validator, the schema is got by DescribeTable and cached
+	client.schemas.invalidate("uidTable")
+	if _, ots_err := client.GetRange("uidTable", OTSDirection_FORWARD, start, end, nil, 0); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err := client.GetRange("uidTable", OTSDirection_BACKWARD, start, end, nil, 0); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("inverted range should fail on client, not %v", ots_err)
+	}
+	if requests["DescribeTable"] != 1 || requests["GetRange"] != 1 {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+
+	// a single column is compared without the schema
+	single_meta := &OTSTableMeta{
+		TableName:          "singleTable",
+		SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{{K: "id", V: "INTEGER"}},
+	}
+	if ots_err := client.CreateTable(single_meta, &OTSReservedThroughput{CapacityUnit: OTSCapacityUnit{Read: 100, Write: 100}}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	client.schemas.invalidate("singleTable")
+	if _, ots_err := client.GetRange("singleTable", OTSDirection_FORWARD, &OTSPrimaryKey{"id": 5}, &OTSPrimaryKey{"id": 1}, nil, 0); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("inverted range should fail on client, not %v", ots_err)
+	}
+	if requests["DescribeTable"] != 1 {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+
+	// the schema is unknown if DescribeTable fails
+	describe_error = "OTSAuthFailed"
+	client.schemas.invalidate("uidTable")
+	if _, ots_err := client.GetRange("uidTable", OTSDirection_FORWARD, start, end, nil, 0); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("unknown schema should fail on client, not %v", ots_err)
+	}
+	if requests["GetRange"] != 1 {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+	describe_error = ""
+	if _, ots_err := client.GetRange("notExistTable", OTSDirection_FORWARD, start, end, nil, 0); !errors.Is(ots_err, ErrObjectNotExist) {
+		t.Fatalf("error should be ErrObjectNotExist, not %v", ots_err)
+	}
+
+	// map keys are ordered by the schema cached in Validator
+	client.Validator = NewValidator()
+	client.Validator.SetTableMeta(table_meta)
+	if _, ots_err := client.GetRange("uidTable", OTSDirection_FORWARD, start, end, nil, 0); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err := client.GetRange("uidTable", OTSDirection_BACKWARD, start, end, nil, 0); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("inverted range should fail on client, not %v", ots_err)
+	}
+	if _, ots_err := client.GetRange("uidTable", OTSDirection_FORWARD, end, start, nil, 0); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("inverted range should fail on client, not %v", ots_err)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Credentials provider for ots2
+package goots
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 从环境变量读取访问凭证时使用的默认变量名，与测试使用的变量名相同
+const (
+	DEFAULT_ENV_ACCESS_KEY_ID     = "OTS_TEST_ACCESS_KEY_ID"
+	DEFAULT_ENV_ACCESS_KEY_SECRET = "OTS_TEST_ACCESS_KEY_SECRET"
+	DEFAULT_ENV_SECURITY_TOKEN    = "OTS_TEST_SECURITY_TOKEN"
+	DEFAULT_PROFILE_NAME          = "default"
+	DEFAULT_STS_REFRESH_BEFORE    = 5 * time.Minute
+)
+
+// 访问凭证
+type OTSCredentials struct {
+	AccessId  string
+	AccessKey string
+	// STS 临时凭证的安全令牌，不为空时作为x-ots-ststoken 头参与签名
+	SecurityToken string
+	// 临时凭证的过期时间，为零值时表示不过期
+	Expiration time.Time
+}
+
+// 访问凭证提供者，OTSClient 在每次请求前调用GetCredentials 获取凭证，
+// 因此更换密钥不需要重新创建OTSClient。实现需要是并发安全的。
+type OTSCredentialsProvider interface {
+	GetCredentials(ctx context.Context) (*OTSCredentials, error)
+}
+
+// 使用固定的accessid 和accesskey
+type OTSStaticCredentialsProvider struct {
+	Credentials OTSCredentials
+}
+
+func NewStaticCredentialsProvider(access_id, access_key, security_token string) *OTSStaticCredentialsProvider {
+	return &OTSStaticCredentialsProvider{
+		Credentials: OTSCredentials{AccessId: access_id, AccessKey: access_key, SecurityToken: security_token},
+	}
+}
+
+func (o *OTSStaticCredentialsProvider) GetCredentials(ctx context.Context) (*OTSCredentials, error) {
+	credentials := o.Credentials
+	return &credentials, nil
+}
+
+// 每次请求时从环境变量读取凭证，变量名为空时使用DEFAULT_ENV_* 中的变量名
+type OTSEnvCredentialsProvider struct {
+	AccessIdEnv      string
+	AccessKeyEnv     string
+	SecurityTokenEnv string
+}
+
+func NewEnvCredentialsProvider() *OTSEnvCredentialsProvider {
+	return &OTSEnvCredentialsProvider{}
+}
+
+func (o *OTSEnvCredentialsProvider) GetCredentials(ctx context.Context) (*OTSCredentials, error) {
+	access_id_env := _default_string(o.AccessIdEnv, DEFAULT_ENV_ACCESS_KEY_ID)
+	access_key_env := _default_string(o.AccessKeyEnv, DEFAULT_ENV_ACCESS_KEY_SECRET)
+	security_token_env := _default_string(o.SecurityTokenEnv, DEFAULT_ENV_SECURITY_TOKEN)
+
+	credentials := &OTSCredentials{
+		AccessId:      os.Getenv(access_id_env),
+		AccessKey:     os.Getenv(access_key_env),
+		SecurityToken: os.Getenv(security_token_env),
+	}
+	if credentials.AccessId == "" || credentials.AccessKey == "" {
+		return nil, new(OTSClientError).SetErrorMessage("environment variable %s or %s is not set", access_id_env, access_key_env)
+	}
+
+	return credentials, nil
+}
+
+// 从本地的配置文件读取凭证，文件修改后会在下次请求时重新读取
+//
+// 		JSON 格式，可以是单个凭证或以profile 名为键的多个凭证：
+//
+// 		{"default": {"access_key_id": "xxx", "access_key_secret": "xxx", "security_token": ""}}
+//
+// 		INI 格式：
+//
+// 		[default]
+// 		access_key_id = xxx
+// 		access_key_secret = xxx
+// 		security_token =
+//
+type OTSProfileCredentialsProvider struct {
+	// 配置文件路径
+	Path string
+	// profile 名，为空时为DEFAULT_PROFILE_NAME
+	Profile string
+
+	lock        sync.Mutex
+	mod_time    time.Time
+	size        int64
+	credentials *OTSCredentials
+}
+
+func NewProfileCredentialsProvider(path, profile string) *OTSProfileCredentialsProvider {
+	return &OTSProfileCredentialsProvider{Path: path, Profile: profile}
+}
+
+func (o *OTSProfileCredentialsProvider) GetCredentials(ctx context.Context) (*OTSCredentials, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	info, err := os.Stat(o.Path)
+	if err != nil {
+		return nil, new(OTSClientError).SetErrorMessage("read profile error: %s", err)
+	}
+	if o.credentials != nil && info.ModTime().Equal(o.mod_time) && info.Size() == o.size {
+		credentials := *o.credentials
+		return &credentials, nil
+	}
+
+	content, err := os.ReadFile(o.Path)
+	if err != nil {
+		return nil, new(OTSClientError).SetErrorMessage("read profile error: %s", err)
+	}
+	profile := _default_string(o.Profile, DEFAULT_PROFILE_NAME)
+	var values map[string]string
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		values, err = _parse_json_profile(content, profile)
+	} else {
+		values, err = _parse_ini_profile(content, profile)
+	}
+	if err != nil {
+		return nil, new(OTSClientError).SetErrorMessage("parse profile %s error: %s", o.Path, err)
+	}
+
+	credentials := &OTSCredentials{
+		AccessId:      values["access_key_id"],
+		AccessKey:     values["access_key_secret"],
+		SecurityToken: values["security_token"],
+	}
+	if credentials.AccessId == "" || credentials.AccessKey == "" {
+		return nil, new(OTSClientError).SetErrorMessage("access_key_id or access_key_secret of profile [%s] is not found in %s", profile, o.Path)
+	}
+	o.credentials = credentials
+	o.mod_time = info.ModTime()
+	o.size = info.Size()
+
+	r := *credentials
+	return &r, nil
+}
+
+func _parse_json_profile(content []byte, profile string) (map[string]string, error) {
+	profiles := map[string]json.RawMessage{}
+	if err := json.Unmarshal(content, &profiles); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	if raw, ok := profiles[profile]; ok {
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+
+	// a single credentials
+	if err := json.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func _parse_ini_profile(content []byte, profile string) (map[string]string, error) {
+	values := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			values[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+// 可刷新的STS 临时凭证，缓存Refresh 返回的凭证，在过期前RefreshBefore 时重新获取
+//
+// 		示例：
+//
+// 		ots_client.CredentialsProvider = NewSTSCredentialsProvider(func(ctx context.Context) (*OTSCredentials, error) {
+// 			// 调用STS AssumeRole 获取临时凭证
+// 			return &OTSCredentials{AccessId: id, AccessKey: secret, SecurityToken: token, Expiration: expiration}, nil
+// 		})
+//
+type OTSSTSCredentialsProvider struct {
+	Refresh func(ctx context.Context) (*OTSCredentials, error)
+	// 在凭证过期前多久刷新，默认为DEFAULT_STS_REFRESH_BEFORE
+	RefreshBefore time.Duration
+
+	lock        sync.Mutex
+	credentials *OTSCredentials
+}
+
+func NewSTSCredentialsProvider(refresh func(ctx context.Context) (*OTSCredentials, error)) *OTSSTSCredentialsProvider {
+	return &OTSSTSCredentialsProvider{Refresh: refresh, RefreshBefore: DEFAULT_STS_REFRESH_BEFORE}
+}
+
+func (o *OTSSTSCredentialsProvider) GetCredentials(ctx context.Context) (*OTSCredentials, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	now := time.Now()
+	if o.credentials != nil && (o.credentials.Expiration.IsZero() || now.Add(o.RefreshBefore).Before(o.credentials.Expiration)) {
+		credentials := *o.credentials
+		return &credentials, nil
+	}
+
+	credentials, err := o.Refresh(ctx)
+	if err != nil || credentials == nil {
+		// keep using the old credentials until they expire
+		if o.credentials != nil && now.Before(o.credentials.Expiration) {
+			r := *o.credentials
+			return &r, nil
+		}
+		if err == nil {
+			err = new(OTSClientError).SetErrorMessage("STS credentials is nil")
+		}
+		return nil, err
+	}
+	o.credentials = credentials
+
+	r := *credentials
+	return &r, nil
+}
+
+// 使下次请求重新获取STS 临时凭证，如收到OTSAuthFailed 时
+func (o *OTSSTSCredentialsProvider) Expire() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.credentials = nil
+}
+
+// the credentials of request, use AccessId and AccessKey if no provider is set
+func (o *OTSClient) _get_credentials(ctx context.Context) (*OTSCredentials, error) {
+	if o.CredentialsProvider == nil {
+		return &OTSCredentials{AccessId: o.AccessId, AccessKey: o.AccessKey}, nil
+	}
+
+	credentials, err := o.CredentialsProvider.GetCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if credentials == nil {
+		return nil, new(OTSClientError).SetErrorMessage("credentials is nil")
+	}
+
+	return credentials, nil
+}
+
+func _default_string(s, default_value string) string {
+	if s == "" {
+		return default_value
+	}
+
+	return s
+}
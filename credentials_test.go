@@ -0,0 +1,142 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test credentials provider for ots2
+package goots
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_credentials_rotation(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+
+	// static keys of client
+	ots_emulator.SetCredentials("new_accessid", "new_accesskey", "")
+	if _, ots_err := client.ListTable(); ots_err == nil {
+		t.Fatal("old keys should be rejected")
+	}
+	client.Set(DictString{"AccessId": "new_accessid", "AccessKey": "new_accesskey"})
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// STS credentials
+	var refresh int32
+	sts := NewSTSCredentialsProvider(func(ctx context.Context) (*OTSCredentials, error) {
+		n := atomic.AddInt32(&refresh, 1)
+		return &OTSCredentials{
+			AccessId:      "sts_accessid",
+			AccessKey:     "sts_accesskey",
+			SecurityToken: "token" + string(rune('0'+n)),
+			Expiration:    time.Now().Add(time.Hour),
+		}, nil
+	})
+	client.Set(DictString{"CredentialsProvider": sts})
+	ots_emulator.SetCredentials("sts_accessid", "sts_accesskey", "token1")
+	for i := 0; i < 3; i++ {
+		if _, ots_err := client.ListTable(); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+	if refresh != 1 {
+		t.Fatalf("STS credentials should be cached, refreshed %d times", refresh)
+	}
+
+	// rotated token
+	ots_emulator.SetCredentials("sts_accessid", "sts_accesskey", "token2")
+	if _, ots_err := client.ListTable(); ots_err == nil {
+		t.Fatal("expired token should be rejected")
+	}
+	sts.Expire()
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// refresh before expiration
+	sts.Refresh = func(ctx context.Context) (*OTSCredentials, error) {
+		atomic.AddInt32(&refresh, 1)
+		return &OTSCredentials{AccessId: "sts_accessid", AccessKey: "sts_accesskey", SecurityToken: "token2", Expiration: time.Now().Add(time.Minute)}, nil
+	}
+	sts.Expire()
+	refresh = 0
+	for i := 0; i < 2; i++ {
+		if _, ots_err := client.ListTable(); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+	if refresh != 2 {
+		t.Fatalf("credentials expiring in RefreshBefore should be refreshed, refreshed %d times", refresh)
+	}
+}
+
+func Test_credentials_env(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	t.Setenv(DEFAULT_ENV_ACCESS_KEY_ID, "env_accessid")
+	t.Setenv(DEFAULT_ENV_ACCESS_KEY_SECRET, "env_accesskey")
+	t.Setenv(DEFAULT_ENV_SECURITY_TOKEN, "")
+	ots_emulator.SetCredentials("env_accessid", "env_accesskey", "")
+	client.CredentialsProvider = NewEnvCredentialsProvider()
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	os.Unsetenv(DEFAULT_ENV_ACCESS_KEY_ID)
+	if _, err := client.CredentialsProvider.GetCredentials(context.Background()); err == nil {
+		t.Fatal("missing environment variable should fail")
+	}
+}
+
+func Test_credentials_profile(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	// JSON
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(`{"other": {"access_key_id": "x", "access_key_secret": "x"}, "default": {"access_key_id": "json_accessid", "access_key_secret": "json_accesskey"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ots_emulator.SetCredentials("json_accessid", "json_accesskey", "")
+	client.CredentialsProvider = NewProfileCredentialsProvider(path, "")
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// INI, reloaded after the file is modified
+	path = filepath.Join(t.TempDir(), "credentials")
+	content := "# ots\n[default]\naccess_key_id = x\naccess_key_secret = x\n\n[prod]\naccess_key_id = ini_accessid\naccess_key_secret = ini_accesskey\nsecurity_token = ini_token\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ots_emulator.SetCredentials("ini_accessid", "ini_accesskey", "ini_token")
+	client.CredentialsProvider = NewProfileCredentialsProvider(path, "prod")
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	content = "[prod]\naccess_key_id = ini_accessid2\naccess_key_secret = ini_accesskey2\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ots_emulator.SetCredentials("ini_accessid2", "ini_accesskey2", "")
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	client.CredentialsProvider = NewProfileCredentialsProvider(path, "missing")
+	if _, ots_err := client.ListTable(); ots_err == nil {
+		t.Fatal("missing profile should fail")
+	}
+}
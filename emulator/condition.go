@@ -0,0 +1,124 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// condition and filter of emulator
+package emulator
+
+import (
+	"net/http"
+
+	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
+)
+
+// check the row existence and column condition before writing, r is nil if the row does not exist
+func _check_condition(condition *Condition, r *row) *ots_error {
+	switch condition.GetRowExistence() {
+	case RowExistenceExpectation_EXPECT_EXIST:
+		if r == nil {
+			return new_error(http.StatusForbidden, OTSConditionCheckFail, "Condition check failed.")
+		}
+	case RowExistenceExpectation_EXPECT_NOT_EXIST:
+		if r != nil {
+			return new_error(http.StatusForbidden, OTSConditionCheckFail, "Condition check failed.")
+		}
+	}
+
+	if condition.GetColumnCondition() == nil {
+		return nil
+	}
+	ok, ots_err := _evaluate_column_condition(condition.GetColumnCondition(), r)
+	if ots_err != nil {
+		return ots_err
+	}
+	if !ok {
+		return new_error(http.StatusForbidden, OTSConditionCheckFail, "Condition check failed.")
+	}
+
+	return nil
+}
+
+// evaluate the column condition against the attributes of row, r is nil if the row does not exist
+func _evaluate_column_condition(column_condition *ColumnCondition, r *row) (bool, *ots_error) {
+	switch column_condition.GetType() {
+	case ColumnConditionType_CCT_RELATION:
+		relation := &RelationCondition{}
+		if err := proto.Unmarshal(column_condition.GetCondition(), relation); err != nil {
+			return false, new_error(http.StatusBadRequest, OTSParameterInvalid, "Invalid relation condition: %s", err)
+		}
+		return _evaluate_relation_condition(relation, r), nil
+
+	case ColumnConditionType_CCT_COMPOSITE:
+		composite := &CompositeCondition{}
+		if err := proto.Unmarshal(column_condition.GetCondition(), composite); err != nil {
+			return false, new_error(http.StatusBadRequest, OTSParameterInvalid, "Invalid composite condition: %s", err)
+		}
+		return _evaluate_composite_condition(composite, r)
+	}
+
+	return false, new_error(http.StatusBadRequest, OTSParameterInvalid, "Invalid column condition type: %s", column_condition.GetType())
+}
+
+func _evaluate_relation_condition(relation *RelationCondition, r *row) bool {
+	var value *ColumnValue
+	if r != nil {
+		value = r.attributes[relation.GetColumnName()]
+	}
+	if value == nil {
+		return relation.GetPassIfMissing()
+	}
+	// values of different types are never matched
+	if value.GetType() != relation.GetColumnValue().GetType() {
+		return false
+	}
+
+	c := _compare_column_value(value, relation.GetColumnValue())
+	switch relation.GetComparator() {
+	case ComparatorType_CT_EQUAL:
+		return c == 0
+	case ComparatorType_CT_NOT_EQUAL:
+		return c != 0
+	case ComparatorType_CT_GREATER_THAN:
+		return c > 0
+	case ComparatorType_CT_GREATER_EQUAL:
+		return c >= 0
+	case ComparatorType_CT_LESS_THAN:
+		return c < 0
+	case ComparatorType_CT_LESS_EQUAL:
+		return c <= 0
+	}
+
+	return false
+}
+
+func _evaluate_composite_condition(composite *CompositeCondition, r *row) (bool, *ots_error) {
+	sub_conditions := composite.GetSubConditions()
+
+	switch composite.GetCombinator() {
+	case LogicalOperator_LO_NOT:
+		if len(sub_conditions) != 1 {
+			return false, new_error(http.StatusBadRequest, OTSParameterInvalid, "LO_NOT should have exactly one sub condition.")
+		}
+		ok, ots_err := _evaluate_column_condition(sub_conditions[0], r)
+		return !ok, ots_err
+
+	case LogicalOperator_LO_AND, LogicalOperator_LO_OR:
+		if len(sub_conditions) < 2 {
+			return false, new_error(http.StatusBadRequest, OTSParameterInvalid, "%s should have at least two sub conditions.", composite.GetCombinator())
+		}
+		is_and := composite.GetCombinator() == LogicalOperator_LO_AND
+		for _, sub_condition := range sub_conditions {
+			ok, ots_err := _evaluate_column_condition(sub_condition, r)
+			if ots_err != nil {
+				return false, ots_err
+			}
+			if ok != is_and {
+				return ok, nil
+			}
+		}
+		return is_and, nil
+	}
+
+	return false, new_error(http.StatusBadRequest, OTSParameterInvalid, "Invalid logical operator: %s", composite.GetCombinator())
+}
@@ -0,0 +1,330 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package emulator is an in-memory OTS server for tests, it speaks the same
+// protobuf-over-HTTP protocol as ots2.
+//
+// 示例：
+//
+// 	ots_emulator := emulator.New("your_accessid", "your_accesskey", "your_instance_name")
+// 	server := ots_emulator.NewServer()
+// 	defer server.Close()
+//
+// 	ots_client, err := ots2.New(server.URL, "your_accessid", "your_accesskey", "your_instance_name")
+package emulator
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
+)
+
+// OTS 的限制项
+const (
+	MAX_PRIMARY_KEY_NUM       = 4
+	MAX_BATCH_GET_ROW_ROWS    = 100
+	MAX_BATCH_WRITE_ROW_ROWS  = 200
+	DEFAULT_GET_RANGE_MAXROWS = 5000
+)
+
+// 错误号
+const (
+	OTSAuthFailed          = "OTSAuthFailed"
+	OTSParameterInvalid    = "OTSParameterInvalid"
+	OTSObjectNotExist      = "OTSObjectNotExist"
+	OTSObjectAlreadyExist  = "OTSObjectAlreadyExist"
+	OTSConditionCheckFail  = "OTSConditionCheckFail"
+	OTSInvalidPK           = "OTSInvalidPK"
+	OTSUnsupportOperation  = "OTSUnsupportOperation"
+	OTSInternalServerError = "OTSInternalServerError"
+)
+
+// OTS 模拟器，在内存中保存所有表和行，实现了ots2 使用的全部API，可以作为http.Handler 使用
+type OTSEmulator struct {
+	// 每次GetRange 最多返回的行数，超过时返回next_start_primary_key，默认为5000
+	GetRangeMaxRows int
+
+	// 为true 时主键列必须按建表时的顺序发送，否则返回OTSInvalidPK，默认按列名匹配
+	StrictPrimaryKeyOrder bool
+
+	// 可选，返回非空的错误号时整个请求失败，用于测试重试等逻辑
+	// 有请求正在处理时请使用SetInjectError 修改
+	InjectError func(api_name string) (http_status int, error_code string, error_message string)
+	// 可选，返回非空的错误号时BatchGetRow 或BatchWriteRow 中的该行失败
+	// 有请求正在处理时请使用SetInjectRowError 修改
+	InjectRowError func(api_name string, table_name string, primary_key []*Column) (error_code string, error_message string)
+
+	inject_lock sync.RWMutex
+
+	cred_lock     sync.RWMutex
+	cred          credentials
+	instance_name string
+
+	lock       sync.RWMutex
+	tables     map[string]*table
+	request_id uint64
+}
+
+// the credentials accepted by emulator, the response is signed with the same key
+type credentials struct {
+	access_id      string
+	access_key     string
+	security_token string
+}
+
+func (c credentials) _signature(signature_string string) string {
+	mac := hmac.New(sha1.New, []byte(c.access_key))
+	mac.Write([]byte(signature_string))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// an OTS error response
+type ots_error struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *ots_error) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.message)
+}
+
+func new_error(status int, code string, format string, a ...interface{}) *ots_error {
+	return &ots_error{status, code, fmt.Sprintf(format, a...)}
+}
+
+// 创建一个OTS 模拟器，只接受使用accessid、accesskey 签名且实例名为instance_name 的请求
+func New(access_id, access_key, instance_name string) *OTSEmulator {
+	return &OTSEmulator{
+		GetRangeMaxRows: DEFAULT_GET_RANGE_MAXROWS,
+		cred:            credentials{access_id: access_id, access_key: access_key},
+		instance_name:   instance_name,
+		tables:          map[string]*table{},
+	}
+}
+
+// 启动一个使用该模拟器的httptest.Server，使用完毕后需要调用Close
+func (e *OTSEmulator) NewServer() *httptest.Server {
+	return httptest.NewServer(e)
+}
+
+// 更换接受的accessid 和accesskey，用于测试密钥轮换
+// security_token 不为空时，请求必须带有相同的x-ots-ststoken，用于测试STS 临时凭证
+func (e *OTSEmulator) SetCredentials(access_id, access_key, security_token string) {
+	e.cred_lock.Lock()
+	defer e.cred_lock.Unlock()
+
+	e.cred = credentials{access_id: access_id, access_key: access_key, security_token: security_token}
+}
+
+// 修改InjectError，可以在有请求正在处理时调用
+func (e *OTSEmulator) SetInjectError(inject_error func(api_name string) (int, string, string)) {
+	e.inject_lock.Lock()
+	defer e.inject_lock.Unlock()
+
+	e.InjectError = inject_error
+}
+
+// 修改InjectRowError，可以在有请求正在处理时调用
+func (e *OTSEmulator) SetInjectRowError(inject_row_error func(api_name string, table_name string, primary_key []*Column) (string, string)) {
+	e.inject_lock.Lock()
+	defer e.inject_lock.Unlock()
+
+	e.InjectRowError = inject_row_error
+}
+
+// 清空所有表
+func (e *OTSEmulator) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.tables = map[string]*table{}
+}
+
+func (e *OTSEmulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api_name := strings.TrimPrefix(r.URL.Path, "/")
+	e.cred_lock.RLock()
+	cred := e.cred
+	e.cred_lock.RUnlock()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e._write_error(w, cred, r.URL.Path, new_error(http.StatusBadRequest, OTSParameterInvalid, "read request body failed: %s", err))
+		return
+	}
+	if r.Method != "POST" {
+		e._write_error(w, cred, r.URL.Path, new_error(http.StatusMethodNotAllowed, OTSUnsupportOperation, "method %s is not supported", r.Method))
+		return
+	}
+	if ots_err := e._check_request(r, cred, body); ots_err != nil {
+		e._write_error(w, cred, r.URL.Path, ots_err)
+		return
+	}
+	e.inject_lock.RLock()
+	inject_error := e.InjectError
+	e.inject_lock.RUnlock()
+	if inject_error != nil {
+		status, code, message := inject_error(api_name)
+		if code != "" {
+			if status == 0 {
+				status = http.StatusServiceUnavailable
+			}
+			e._write_error(w, cred, r.URL.Path, new_error(status, code, "%s", message))
+			return
+		}
+	}
+
+	response, ots_err := e._dispatch(api_name, body)
+	if ots_err != nil {
+		e._write_error(w, cred, r.URL.Path, ots_err)
+		return
+	}
+
+	response_body, err := proto.Marshal(response)
+	if err != nil {
+		e._write_error(w, cred, r.URL.Path, new_error(http.StatusInternalServerError, OTSInternalServerError, "%s", err))
+		return
+	}
+	e._write_response(w, cred, r.URL.Path, http.StatusOK, response_body)
+}
+
+func (e *OTSEmulator) _dispatch(api_name string, body []byte) (proto.Message, *ots_error) {
+	var request proto.Message
+	var handler func(request proto.Message) (proto.Message, *ots_error)
+
+	switch api_name {
+	case "CreateTable":
+		request, handler = &CreateTableRequest{}, e._create_table
+	case "ListTable":
+		request, handler = &ListTableRequest{}, e._list_table
+	case "DeleteTable":
+		request, handler = &DeleteTableRequest{}, e._delete_table
+	case "DescribeTable":
+		request, handler = &DescribeTableRequest{}, e._describe_table
+	case "UpdateTable":
+		request, handler = &UpdateTableRequest{}, e._update_table
+	case "GetRow":
+		request, handler = &GetRowRequest{}, e._get_row
+	case "PutRow":
+		request, handler = &PutRowRequest{}, e._put_row
+	case "UpdateRow":
+		request, handler = &UpdateRowRequest{}, e._update_row
+	case "DeleteRow":
+		request, handler = &DeleteRowRequest{}, e._delete_row
+	case "BatchGetRow":
+		request, handler = &BatchGetRowRequest{}, e._batch_get_row
+	case "BatchWriteRow":
+		request, handler = &BatchWriteRowRequest{}, e._batch_write_row
+	case "GetRange":
+		request, handler = &GetRangeRequest{}, e._get_range
+	default:
+		return nil, new_error(http.StatusNotFound, OTSUnsupportOperation, "API %s is not supported", api_name)
+	}
+
+	if err := proto.Unmarshal(body, request); err != nil {
+		return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "invalid request body: %s", err)
+	}
+
+	return handler(request)
+}
+
+// check the accessid, instance name, content md5 and signature of request
+func (e *OTSEmulator) _check_request(r *http.Request, cred credentials, body []byte) *ots_error {
+	if r.Header.Get("x-ots-accesskeyid") != cred.access_id {
+		return new_error(http.StatusForbidden, OTSAuthFailed, "The AccessKeyID does not exist.")
+	}
+	if r.Header.Get("x-ots-ststoken") != cred.security_token {
+		return new_error(http.StatusForbidden, OTSAuthFailed, "The security token is invalid.")
+	}
+	if r.Header.Get("x-ots-instancename") != e.instance_name {
+		return new_error(http.StatusForbidden, OTSAuthFailed, "The instance is not found.")
+	}
+	if r.Header.Get("x-ots-contentmd5") != _base64_md5(body) {
+		return new_error(http.StatusBadRequest, OTSParameterInvalid, "Content MD5 mismatch.")
+	}
+
+	signature_string := r.URL.Path + "\n" + "POST" + "\n" + _sorted_query(r.URL.Query()) + "\n" +
+		_make_headers_string(r.Header) + "\n"
+	if r.Header.Get("x-ots-signature") != cred._signature(signature_string) {
+		return new_error(http.StatusForbidden, OTSAuthFailed, "Signature mismatch.")
+	}
+
+	return nil
+}
+
+func (e *OTSEmulator) _write_error(w http.ResponseWriter, cred credentials, uri string, ots_err *ots_error) {
+	body, _ := proto.Marshal(&Error{
+		Code:    proto.String(ots_err.code),
+		Message: proto.String(ots_err.message),
+	})
+	e._write_response(w, cred, uri, ots_err.status, body)
+}
+
+// sign the response the way the client checks it
+func (e *OTSEmulator) _write_response(w http.ResponseWriter, cred credentials, uri string, status int, body []byte) {
+	header := w.Header()
+	header.Set("x-ots-contentmd5", _base64_md5(body))
+	header.Set("x-ots-requestid", fmt.Sprintf("%08x-0000-0000-0000-%012x", time.Now().Unix(), atomic.AddUint64(&e.request_id, 1)))
+	header.Set("x-ots-date", time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	header.Set("x-ots-contenttype", "protocol buffer")
+	header.Set("Authorization", "OTS "+cred.access_id+":"+cred._signature(_make_headers_string(header)+"\n"+uri))
+	header.Set("Content-Type", "application/x-protobuf")
+
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// the sorted x-ots-* headers except x-ots-signature
+func _make_headers_string(header http.Header) string {
+	strslice := []string{}
+	for k, v := range header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-ots-") && k != "x-ots-signature" && len(v) > 0 {
+			strslice = append(strslice, fmt.Sprintf("%s:%s", k, strings.TrimSpace(v[0])))
+		}
+	}
+	if len(strslice) == 0 {
+		return "\n"
+	}
+	sort.Strings(strslice)
+
+	return strings.Join(strslice, "\n")
+}
+
+func _sorted_query(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	strslice := []string{}
+	for k, v := range query {
+		strslice = append(strslice, fmt.Sprintf("%s:%s", k, v[0]))
+	}
+	sort.Strings(strslice)
+
+	result := ""
+	for _, c := range []byte(strings.Join(strslice, "&")) {
+		result += fmt.Sprintf("%%%X", c)
+	}
+	return result
+}
+
+func _base64_md5(body []byte) string {
+	sum := md5.Sum(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
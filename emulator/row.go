@@ -0,0 +1,412 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// row operations of emulator
+package emulator
+
+import (
+	"net/http"
+
+	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
+)
+
+// read a row with columns_to_get and filter, nil if it does not exist or is filtered out
+func (t *table) _read_row(primary_key []*Column, columns_to_get []string, filter *ColumnCondition) (*Row, int32, *ots_error) {
+	pk, ots_err := t._parse_primary_key(primary_key, false)
+	if ots_err != nil {
+		return nil, 0, ots_err
+	}
+
+	r := t._get(pk)
+	if r == nil {
+		return nil, 1, nil
+	}
+	if filter != nil {
+		ok, ots_err := _evaluate_column_condition(filter, r)
+		if ots_err != nil {
+			return nil, 0, ots_err
+		}
+		if !ok {
+			return nil, _row_capacity_unit(r), nil
+		}
+	}
+
+	return t._make_row(r, columns_to_get), _row_capacity_unit(r), nil
+}
+
+func (t *table) _write_put_row(condition *Condition, primary_key []*Column, attribute_columns []*Column) (int32, *ots_error) {
+	pk, ots_err := t._parse_primary_key(primary_key, false)
+	if ots_err != nil {
+		return 0, ots_err
+	}
+	if ots_err := _check_condition(condition, t._get(pk)); ots_err != nil {
+		return 1, ots_err
+	}
+
+	r := &row{primary_key: pk, attributes: map[string]*ColumnValue{}}
+	for _, column := range attribute_columns {
+		if ots_err := t._check_attribute_column(column.GetName(), column.GetValue()); ots_err != nil {
+			return 0, ots_err
+		}
+		r.attributes[column.GetName()] = column.GetValue()
+	}
+	t._put(r)
+
+	return _row_capacity_unit(r), nil
+}
+
+func (t *table) _write_update_row(condition *Condition, primary_key []*Column, attribute_columns []*ColumnUpdate) (int32, *ots_error) {
+	pk, ots_err := t._parse_primary_key(primary_key, false)
+	if ots_err != nil {
+		return 0, ots_err
+	}
+	old := t._get(pk)
+	if ots_err := _check_condition(condition, old); ots_err != nil {
+		return 1, ots_err
+	}
+
+	r := &row{primary_key: pk, attributes: map[string]*ColumnValue{}}
+	if old != nil {
+		for k, v := range old.attributes {
+			r.attributes[k] = v
+		}
+	}
+	for _, column := range attribute_columns {
+		switch column.GetType() {
+		case OperationType_PUT:
+			if ots_err := t._check_attribute_column(column.GetName(), column.GetValue()); ots_err != nil {
+				return 0, ots_err
+			}
+			r.attributes[column.GetName()] = column.GetValue()
+		case OperationType_DELETE:
+			delete(r.attributes, column.GetName())
+		default:
+			return 0, new_error(http.StatusBadRequest, OTSParameterInvalid, "Invalid operation type: %s", column.GetType())
+		}
+	}
+	t._put(r)
+
+	return _row_capacity_unit(r), nil
+}
+
+func (t *table) _write_delete_row(condition *Condition, primary_key []*Column) (int32, *ots_error) {
+	pk, ots_err := t._parse_primary_key(primary_key, false)
+	if ots_err != nil {
+		return 0, ots_err
+	}
+	old := t._get(pk)
+	if ots_err := _check_condition(condition, old); ots_err != nil {
+		return 1, ots_err
+	}
+	t._delete(pk)
+
+	return _row_capacity_unit(old), nil
+}
+
+func (t *table) _check_attribute_column(name string, value *ColumnValue) *ots_error {
+	if value == nil || value.GetType() == ColumnType_INF_MIN || value.GetType() == ColumnType_INF_MAX {
+		return new_error(http.StatusBadRequest, OTSParameterInvalid, "Invalid value for attribute column: '%s'.", name)
+	}
+	for _, column_schema := range t.meta.GetPrimaryKey() {
+		if column_schema.GetName() == name {
+			return new_error(http.StatusBadRequest, OTSParameterInvalid, "Attribute column should not be a primary key column: '%s'.", name)
+		}
+	}
+
+	return nil
+}
+
+func (e *OTSEmulator) _get_row(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*GetRowRequest)
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	t, ots_err := e._get_table(pb.GetTableName())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+	r, read, ots_err := t._read_row(pb.GetPrimaryKey(), pb.GetColumnsToGet(), pb.GetFilter())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+	if r == nil {
+		r = &Row{}
+	}
+
+	return &GetRowResponse{Consumed: _consumed(read, 0), Row: r}, nil
+}
+
+func (e *OTSEmulator) _put_row(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*PutRowRequest)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	t, ots_err := e._get_table(pb.GetTableName())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+	write, ots_err := t._write_put_row(pb.GetCondition(), pb.GetPrimaryKey(), pb.GetAttributeColumns())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+
+	return &PutRowResponse{Consumed: _consumed(0, write)}, nil
+}
+
+func (e *OTSEmulator) _update_row(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*UpdateRowRequest)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	t, ots_err := e._get_table(pb.GetTableName())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+	write, ots_err := t._write_update_row(pb.GetCondition(), pb.GetPrimaryKey(), pb.GetAttributeColumns())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+
+	return &UpdateRowResponse{Consumed: _consumed(0, write)}, nil
+}
+
+func (e *OTSEmulator) _delete_row(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*DeleteRowRequest)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	t, ots_err := e._get_table(pb.GetTableName())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+	write, ots_err := t._write_delete_row(pb.GetCondition(), pb.GetPrimaryKey())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+
+	return &DeleteRowResponse{Consumed: _consumed(0, write)}, nil
+}
+
+// the result of a row in batch operations
+func (e *OTSEmulator) _inject_row_error(api_name, table_name string, primary_key []*Column) *ots_error {
+	e.inject_lock.RLock()
+	inject_row_error := e.InjectRowError
+	e.inject_lock.RUnlock()
+	if inject_row_error == nil {
+		return nil
+	}
+	code, message := inject_row_error(api_name, table_name, primary_key)
+	if code == "" {
+		return nil
+	}
+
+	return new_error(http.StatusServiceUnavailable, code, "%s", message)
+}
+
+func _row_error(ots_err *ots_error) *Error {
+	return &Error{Code: proto.String(ots_err.code), Message: proto.String(ots_err.message)}
+}
+
+func (e *OTSEmulator) _batch_get_row(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*BatchGetRowRequest)
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	rows := 0
+	for _, table_item := range pb.GetTables() {
+		if _, ots_err := e._get_table(table_item.GetTableName()); ots_err != nil {
+			return nil, ots_err
+		}
+		rows += len(table_item.GetRows())
+	}
+	if rows > MAX_BATCH_GET_ROW_ROWS {
+		return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "Rows count exceeds the upper limit: %d.", MAX_BATCH_GET_ROW_ROWS)
+	}
+
+	response := &BatchGetRowResponse{}
+	for _, table_item := range pb.GetTables() {
+		t := e.tables[table_item.GetTableName()]
+		table_response := &TableInBatchGetRowResponse{TableName: proto.String(table_item.GetTableName())}
+		for _, row_item := range table_item.GetRows() {
+			ots_err := e._inject_row_error("BatchGetRow", table_item.GetTableName(), row_item.GetPrimaryKey())
+			var r *Row
+			var read int32
+			if ots_err == nil {
+				r, read, ots_err = t._read_row(row_item.GetPrimaryKey(), table_item.GetColumnsToGet(), table_item.GetFilter())
+			}
+			if ots_err != nil {
+				table_response.Rows = append(table_response.Rows, &RowInBatchGetRowResponse{IsOk: proto.Bool(false), Error: _row_error(ots_err)})
+				continue
+			}
+			if r == nil {
+				r = &Row{}
+			}
+			table_response.Rows = append(table_response.Rows, &RowInBatchGetRowResponse{IsOk: proto.Bool(true), Consumed: _consumed(read, 0), Row: r})
+		}
+		response.Tables = append(response.Tables, table_response)
+	}
+
+	return response, nil
+}
+
+func (e *OTSEmulator) _batch_write_row(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*BatchWriteRowRequest)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	rows := 0
+	for _, table_item := range pb.GetTables() {
+		if _, ots_err := e._get_table(table_item.GetTableName()); ots_err != nil {
+			return nil, ots_err
+		}
+		rows += len(table_item.GetPutRows()) + len(table_item.GetUpdateRows()) + len(table_item.GetDeleteRows())
+	}
+	if rows > MAX_BATCH_WRITE_ROW_ROWS {
+		return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "Rows count exceeds the upper limit: %d.", MAX_BATCH_WRITE_ROW_ROWS)
+	}
+
+	make_result := func(write int32, ots_err *ots_error) *RowInBatchWriteRowResponse {
+		if ots_err != nil {
+			return &RowInBatchWriteRowResponse{IsOk: proto.Bool(false), Error: _row_error(ots_err)}
+		}
+		return &RowInBatchWriteRowResponse{IsOk: proto.Bool(true), Consumed: _consumed(0, write)}
+	}
+
+	response := &BatchWriteRowResponse{}
+	for _, table_item := range pb.GetTables() {
+		table_name := table_item.GetTableName()
+		t := e.tables[table_name]
+		table_response := &TableInBatchWriteRowResponse{TableName: proto.String(table_name)}
+
+		for _, row_item := range table_item.GetPutRows() {
+			var write int32
+			ots_err := e._inject_row_error("BatchWriteRow", table_name, row_item.GetPrimaryKey())
+			if ots_err == nil {
+				write, ots_err = t._write_put_row(row_item.GetCondition(), row_item.GetPrimaryKey(), row_item.GetAttributeColumns())
+			}
+			table_response.PutRows = append(table_response.PutRows, make_result(write, ots_err))
+		}
+		for _, row_item := range table_item.GetUpdateRows() {
+			var write int32
+			ots_err := e._inject_row_error("BatchWriteRow", table_name, row_item.GetPrimaryKey())
+			if ots_err == nil {
+				write, ots_err = t._write_update_row(row_item.GetCondition(), row_item.GetPrimaryKey(), row_item.GetAttributeColumns())
+			}
+			table_response.UpdateRows = append(table_response.UpdateRows, make_result(write, ots_err))
+		}
+		for _, row_item := range table_item.GetDeleteRows() {
+			var write int32
+			ots_err := e._inject_row_error("BatchWriteRow", table_name, row_item.GetPrimaryKey())
+			if ots_err == nil {
+				write, ots_err = t._write_delete_row(row_item.GetCondition(), row_item.GetPrimaryKey())
+			}
+			table_response.DeleteRows = append(table_response.DeleteRows, make_result(write, ots_err))
+		}
+		response.Tables = append(response.Tables, table_response)
+	}
+
+	return response, nil
+}
+
+func (e *OTSEmulator) _get_range(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*GetRangeRequest)
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	t, ots_err := e._get_table(pb.GetTableName())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+	start, ots_err := t._parse_primary_key(pb.GetInclusiveStartPrimaryKey(), true)
+	if ots_err != nil {
+		return nil, ots_err
+	}
+	end, ots_err := t._parse_primary_key(pb.GetExclusiveEndPrimaryKey(), true)
+	if ots_err != nil {
+		return nil, ots_err
+	}
+
+	forward := pb.GetDirection() == Direction_FORWARD
+	c := _compare_primary_key(start, end)
+	if (forward && c > 0) || (!forward && c < 0) {
+		return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "Begin key must less than end key in FORWARD.")
+	}
+	if pb.Limit != nil && pb.GetLimit() <= 0 {
+		return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "The limit must be greater than 0.")
+	}
+
+	max_rows := e.GetRangeMaxRows
+	if max_rows <= 0 {
+		max_rows = DEFAULT_GET_RANGE_MAXROWS
+	}
+	limit := -1
+	if pb.Limit != nil {
+		limit = int(pb.GetLimit())
+	}
+
+	// the rows in [start, end) or (end, start] in the order of direction
+	var candidates []*row
+	if forward {
+		i, _ := t._search(start)
+		for ; i < len(t.rows) && _compare_primary_key(t.rows[i].primary_key, end) < 0; i++ {
+			candidates = append(candidates, t.rows[i])
+		}
+	} else {
+		i, found := t._search(start)
+		if !found {
+			i -= 1
+		}
+		for ; i >= 0 && _compare_primary_key(t.rows[i].primary_key, end) > 0; i-- {
+			candidates = append(candidates, t.rows[i])
+		}
+	}
+
+	response := &GetRangeResponse{}
+	read := int32(0)
+	scanned := 0
+	for _, r := range candidates {
+		if scanned >= max_rows || (limit > 0 && len(response.Rows) >= limit) {
+			response.NextStartPrimaryKey = t._make_primary_key_columns(r.primary_key)
+			break
+		}
+		scanned += 1
+		read += _row_capacity_unit(r)
+
+		if pb.GetFilter() != nil {
+			ok, ots_err := _evaluate_column_condition(pb.GetFilter(), r)
+			if ots_err != nil {
+				return nil, ots_err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if pb_row := t._make_row(r, pb.GetColumnsToGet()); pb_row != nil {
+			response.Rows = append(response.Rows, pb_row)
+		}
+	}
+	if read == 0 {
+		read = 1
+	}
+	response.Consumed = _consumed(read, 0)
+
+	return response, nil
+}
+
+func (t *table) _make_primary_key_columns(primary_key []*ColumnValue) []*Column {
+	columns := make([]*Column, len(primary_key))
+	for i, column_schema := range t.meta.GetPrimaryKey() {
+		columns[i] = &Column{Name: proto.String(column_schema.GetName()), Value: primary_key[i]}
+	}
+
+	return columns
+}
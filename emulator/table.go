@@ -0,0 +1,387 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// tables and rows of emulator
+package emulator
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
+)
+
+var table_name_regexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,254}$`)
+
+type table struct {
+	meta                      *TableMeta
+	capacity_unit             *CapacityUnit
+	last_increase_time        int64
+	last_decrease_time        int64
+	number_of_decreases_today int32
+
+	rows []*row // sorted by primary key
+
+	emulator *OTSEmulator
+}
+
+type row struct {
+	primary_key []*ColumnValue // in the order of schema
+	attributes  map[string]*ColumnValue
+}
+
+func (t *table) _reserved_throughput_details() *ReservedThroughputDetails {
+	details := &ReservedThroughputDetails{
+		CapacityUnit:           &CapacityUnit{Read: proto.Int32(t.capacity_unit.GetRead()), Write: proto.Int32(t.capacity_unit.GetWrite())},
+		LastIncreaseTime:       proto.Int64(t.last_increase_time),
+		NumberOfDecreasesToday: proto.Int32(t.number_of_decreases_today),
+	}
+	if t.last_decrease_time != 0 {
+		details.LastDecreaseTime = proto.Int64(t.last_decrease_time)
+	}
+
+	return details
+}
+
+// the primary key in the order of schema, INF_MIN and INF_MAX are only allowed for GetRange
+func (t *table) _parse_primary_key(columns []*Column, allow_inf bool) ([]*ColumnValue, *ots_error) {
+	schema := t.meta.GetPrimaryKey()
+	if len(columns) != len(schema) {
+		return nil, new_error(http.StatusBadRequest, OTSInvalidPK, "Validate PK size fail. Input: %d, Meta: %d.", len(columns), len(schema))
+	}
+
+	values := map[string]*ColumnValue{}
+	for i, column := range columns {
+		if t.emulator != nil && t.emulator.StrictPrimaryKeyOrder && column.GetName() != schema[i].GetName() {
+			return nil, new_error(http.StatusBadRequest, OTSInvalidPK, "Validate PK name fail. Input: %s, Meta: %s.", column.GetName(), schema[i].GetName())
+		}
+		values[column.GetName()] = column.GetValue()
+	}
+
+	primary_key := make([]*ColumnValue, len(schema))
+	for i, column_schema := range schema {
+		value, ok := values[column_schema.GetName()]
+		if !ok || value == nil {
+			return nil, new_error(http.StatusBadRequest, OTSInvalidPK, "Validate PK name fail. Input: %v, Meta: %s.", columns, column_schema.GetName())
+		}
+		value_type := value.GetType()
+		if allow_inf && (value_type == ColumnType_INF_MIN || value_type == ColumnType_INF_MAX) {
+			primary_key[i] = value
+			continue
+		}
+		if value_type != column_schema.GetType() {
+			return nil, new_error(http.StatusBadRequest, OTSInvalidPK, "Validate PK type fail. Input: %s, Meta: %s.", value_type, column_schema.GetType())
+		}
+		primary_key[i] = value
+	}
+
+	return primary_key, nil
+}
+
+// the index of the first row not less than primary_key, and whether it is equal
+func (t *table) _search(primary_key []*ColumnValue) (int, bool) {
+	i := sort.Search(len(t.rows), func(i int) bool {
+		return _compare_primary_key(t.rows[i].primary_key, primary_key) >= 0
+	})
+
+	return i, i < len(t.rows) && _compare_primary_key(t.rows[i].primary_key, primary_key) == 0
+}
+
+func (t *table) _get(primary_key []*ColumnValue) *row {
+	i, found := t._search(primary_key)
+	if !found {
+		return nil
+	}
+
+	return t.rows[i]
+}
+
+func (t *table) _put(r *row) {
+	i, found := t._search(r.primary_key)
+	if found {
+		t.rows[i] = r
+		return
+	}
+
+	t.rows = append(t.rows, nil)
+	copy(t.rows[i+1:], t.rows[i:])
+	t.rows[i] = r
+}
+
+func (t *table) _delete(primary_key []*ColumnValue) {
+	i, found := t._search(primary_key)
+	if found {
+		t.rows = append(t.rows[:i], t.rows[i+1:]...)
+	}
+}
+
+// make the Row message with columns_to_get, nil if none of the columns is returned
+func (t *table) _make_row(r *row, columns_to_get []string) *Row {
+	wanted := func(name string) bool {
+		if len(columns_to_get) == 0 {
+			return true
+		}
+		for _, v := range columns_to_get {
+			if v == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	pb := &Row{}
+	for i, column_schema := range t.meta.GetPrimaryKey() {
+		if wanted(column_schema.GetName()) {
+			pb.PrimaryKeyColumns = append(pb.PrimaryKeyColumns, &Column{Name: proto.String(column_schema.GetName()), Value: r.primary_key[i]})
+		}
+	}
+
+	names := make([]string, 0, len(r.attributes))
+	for name := range r.attributes {
+		if wanted(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pb.AttributeColumns = append(pb.AttributeColumns, &Column{Name: proto.String(name), Value: r.attributes[name]})
+	}
+
+	if len(pb.PrimaryKeyColumns) == 0 && len(pb.AttributeColumns) == 0 {
+		return nil
+	}
+
+	return pb
+}
+
+// the capacity unit of a row, 1 CU for every 4KB
+func _row_capacity_unit(r *row) int32 {
+	if r == nil {
+		return 1
+	}
+
+	size := 0
+	for _, v := range r.primary_key {
+		size += _column_value_size(v)
+	}
+	for k, v := range r.attributes {
+		size += len(k) + _column_value_size(v)
+	}
+
+	return int32(size/4096) + 1
+}
+
+func _column_value_size(value *ColumnValue) int {
+	switch value.GetType() {
+	case ColumnType_STRING:
+		return len(value.GetVString())
+	case ColumnType_BINARY:
+		return len(value.GetVBinary())
+	case ColumnType_BOOLEAN:
+		return 1
+	default:
+		return 8
+	}
+}
+
+func _consumed(read, write int32) *ConsumedCapacity {
+	return &ConsumedCapacity{CapacityUnit: &CapacityUnit{Read: proto.Int32(read), Write: proto.Int32(write)}}
+}
+
+func _compare_primary_key(a, b []*ColumnValue) int {
+	for i := range a {
+		if c := _compare_column_value(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// compare two values of the same type, INF_MIN and INF_MAX are less or greater than any value
+func _compare_column_value(a, b *ColumnValue) int {
+	a_type, b_type := a.GetType(), b.GetType()
+	if a_type == ColumnType_INF_MIN || b_type == ColumnType_INF_MAX {
+		if a_type == b_type {
+			return 0
+		}
+		return -1
+	}
+	if a_type == ColumnType_INF_MAX || b_type == ColumnType_INF_MIN {
+		return 1
+	}
+	if a_type != b_type {
+		if a_type < b_type {
+			return -1
+		}
+		return 1
+	}
+
+	switch a_type {
+	case ColumnType_INTEGER:
+		return _compare_int64(a.GetVInt(), b.GetVInt())
+	case ColumnType_STRING:
+		return bytes.Compare([]byte(a.GetVString()), []byte(b.GetVString()))
+	case ColumnType_BINARY:
+		return bytes.Compare(a.GetVBinary(), b.GetVBinary())
+	case ColumnType_BOOLEAN:
+		if a.GetVBool() == b.GetVBool() {
+			return 0
+		} else if b.GetVBool() {
+			return -1
+		}
+		return 1
+	case ColumnType_DOUBLE:
+		if a.GetVDouble() < b.GetVDouble() {
+			return -1
+		} else if a.GetVDouble() > b.GetVDouble() {
+			return 1
+		}
+		return 0
+	}
+
+	return 0
+}
+
+func _compare_int64(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func (e *OTSEmulator) _get_table(table_name string) (*table, *ots_error) {
+	t, ok := e.tables[table_name]
+	if !ok {
+		return nil, new_error(http.StatusNotFound, OTSObjectNotExist, "Requested table does not exist.")
+	}
+
+	return t, nil
+}
+
+func (e *OTSEmulator) _create_table(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*CreateTableRequest)
+	table_name := pb.GetTableMeta().GetTableName()
+	if !table_name_regexp.MatchString(table_name) {
+		return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "Invalid table name: '%s'.", table_name)
+	}
+
+	schema := pb.GetTableMeta().GetPrimaryKey()
+	if len(schema) == 0 || len(schema) > MAX_PRIMARY_KEY_NUM {
+		return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "The number of primary key columns must be in range: [1, %d].", MAX_PRIMARY_KEY_NUM)
+	}
+	names := map[string]bool{}
+	for _, column_schema := range schema {
+		if names[column_schema.GetName()] {
+			return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "Duplicated primary key name: '%s'.", column_schema.GetName())
+		}
+		names[column_schema.GetName()] = true
+		if column_schema.GetType() != ColumnType_INTEGER && column_schema.GetType() != ColumnType_STRING && column_schema.GetType() != ColumnType_BINARY {
+			return nil, new_error(http.StatusBadRequest, OTSParameterInvalid, "Invalid type for primary key: '%s'.", column_schema.GetType())
+		}
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ok := e.tables[table_name]; ok {
+		return nil, new_error(http.StatusConflict, OTSObjectAlreadyExist, "Requested table already exists.")
+	}
+	capacity_unit := pb.GetReservedThroughput().GetCapacityUnit()
+	e.tables[table_name] = &table{
+		meta:               pb.GetTableMeta(),
+		capacity_unit:      &CapacityUnit{Read: proto.Int32(capacity_unit.GetRead()), Write: proto.Int32(capacity_unit.GetWrite())},
+		last_increase_time: time.Now().Unix(),
+		emulator:           e,
+	}
+
+	return &CreateTableResponse{}, nil
+}
+
+func (e *OTSEmulator) _list_table(request proto.Message) (proto.Message, *ots_error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	response := &ListTableResponse{}
+	for table_name := range e.tables {
+		response.TableNames = append(response.TableNames, table_name)
+	}
+	sort.Strings(response.TableNames)
+
+	return response, nil
+}
+
+func (e *OTSEmulator) _delete_table(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*DeleteTableRequest)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ots_err := e._get_table(pb.GetTableName()); ots_err != nil {
+		return nil, ots_err
+	}
+	delete(e.tables, pb.GetTableName())
+
+	return &DeleteTableResponse{}, nil
+}
+
+func (e *OTSEmulator) _describe_table(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*DescribeTableRequest)
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	t, ots_err := e._get_table(pb.GetTableName())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+
+	return &DescribeTableResponse{
+		TableMeta:                 t.meta,
+		ReservedThroughputDetails: t._reserved_throughput_details(),
+	}, nil
+}
+
+func (e *OTSEmulator) _update_table(request proto.Message) (proto.Message, *ots_error) {
+	pb := request.(*UpdateTableRequest)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	t, ots_err := e._get_table(pb.GetTableName())
+	if ots_err != nil {
+		return nil, ots_err
+	}
+
+	capacity_unit := pb.GetReservedThroughput().GetCapacityUnit()
+	now := time.Now().Unix()
+	if capacity_unit.Read != nil {
+		if capacity_unit.GetRead() > t.capacity_unit.GetRead() {
+			t.last_increase_time = now
+		} else if capacity_unit.GetRead() < t.capacity_unit.GetRead() {
+			t.last_decrease_time = now
+			t.number_of_decreases_today += 1
+		}
+		t.capacity_unit.Read = proto.Int32(capacity_unit.GetRead())
+	}
+	if capacity_unit.Write != nil {
+		if capacity_unit.GetWrite() > t.capacity_unit.GetWrite() {
+			t.last_increase_time = now
+		} else if capacity_unit.GetWrite() < t.capacity_unit.GetWrite() {
+			t.last_decrease_time = now
+			t.number_of_decreases_today += 1
+		}
+		t.capacity_unit.Write = proto.Int32(capacity_unit.GetWrite())
+	}
+
+	return &UpdateTableResponse{
+		ReservedThroughputDetails: t._reserved_throughput_details(),
+	}, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test ots2 against the local emulator
+package goots
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/GiterLab/goots/emulator"
+	. "github.com/GiterLab/goots/otstype"
+	"github.com/GiterLab/goots/protobuf"
+)
+
+func newWithEmulator(t *testing.T, table_name string) (*OTSClient, *emulator.OTSEmulator, *httptest.Server) {
+	OTSErrorPanicMode = false
+	ots_emulator := emulator.New("test_accessid", "test_accesskey", "TestInstance")
+	server := ots_emulator.NewServer()
+
+	client, err := New(server.URL, "test_accessid", "test_accesskey", "TestInstance")
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	table_meta := &OTSTableMeta{
+		TableName: table_name,
+		SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{
+			{K: "gid", V: "INTEGER"},
+			{K: "uid", V: "INTEGER"},
+		},
+	}
+	if ots_err := client.CreateTable(table_meta, &OTSReservedThroughput{CapacityUnit: OTSCapacityUnit{Read: 100, Write: 100}}); ots_err != nil {
+		server.Close()
+		t.Fatal(ots_err)
+	}
+
+	return client, ots_emulator, server
+}
+
+func Test_emulator_row(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	primary_key := &OTSPrimaryKey{"gid": 1, "uid": 101}
+	_, ots_err := client.PutRow("myTable", OTSCondition_EXPECT_NOT_EXIST, primary_key, &OTSAttribute{"name": "张三", "age": 20})
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err = client.PutRow("myTable", OTSCondition_EXPECT_NOT_EXIST, primary_key, &OTSAttribute{"age": 21}); ots_err == nil ||
+		ots_err.ServiceError == nil || ots_err.ServiceError.Code != "OTSConditionCheckFail" {
+		t.Errorf("put an existing row with EXPECT_NOT_EXIST should fail, but %v", ots_err)
+	}
+
+	// optimistic lock by column condition
+	condition := NewCondition(OTSCondition_EXPECT_EXIST, NewRelationCondition("age", OTSComparatorType_EQUAL, 20, false))
+	if _, ots_err = client.UpdateRow("myTable", condition, primary_key, &OTSUpdateOfAttribute{OTSOperationType_PUT: OTSColumnsToPut{"age": 21}}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err = client.UpdateRow("myTable", condition, primary_key, &OTSUpdateOfAttribute{OTSOperationType_PUT: OTSColumnsToPut{"age": 22}}); ots_err == nil {
+		t.Error("update with a stale column condition should fail")
+	}
+
+	response, ots_err := client.GetRow("myTable", primary_key, &OTSColumnsToGet{"name", "age"})
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	attributes := response.GetAttributeColumns()
+	if attributes["name"] != "张三" || attributes["age"] != int64(21) {
+		t.Errorf("unexpected attributes: %v", attributes)
+	}
+
+	response, ots_err = client.GetRow("myTable", primary_key, nil, NewRelationCondition("age", OTSComparatorType_GREATER_THAN, 30, false))
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if len(response.GetAttributeColumns()) != 0 {
+		t.Errorf("the row should be filtered out, but %v", response.GetAttributeColumns())
+	}
+
+	if _, ots_err = client.DeleteRow("myTable", OTSCondition_EXPECT_EXIST, primary_key); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err = client.DeleteRow("myTable", OTSCondition_EXPECT_EXIST, primary_key); ots_err == nil {
+		t.Error("delete a missing row with EXPECT_EXIST should fail")
+	}
+}
+
+func Test_emulator_range(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	ots_emulator.GetRangeMaxRows = 7
+
+	writer := client.NewBulkWriter(0, 0)
+	for i := 0; i < 100; i++ {
+		writer.Put("myTable", OTSPutRowItem{
+			Condition:        OTSCondition_IGNORE,
+			PrimaryKey:       OTSPrimaryKey{"gid": i / 10, "uid": i},
+			AttributeColumns: OTSAttribute{"index": i},
+		})
+	}
+	if ots_err := writer.Close(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	start := &OTSPrimaryKey{"gid": OTSColumnType_INF_MIN, "uid": OTSColumnType_INF_MIN}
+	end := &OTSPrimaryKey{"gid": OTSColumnType_INF_MAX, "uid": OTSColumnType_INF_MAX}
+	iter := client.XGetRange("myTable", OTSDirection_FORWARD, start, end, nil, 0)
+	count := 0
+	for iter.Next() {
+		if iter.Row().GetAttributeColumns()["index"] != int64(count) {
+			t.Fatalf("row %d out of order: %v", count, iter.Row())
+		}
+		count += 1
+	}
+	if iter.Err() != nil || count != 100 || iter.Pages() < 100/7 {
+		t.Errorf("excpected %d rows but %d in %d pages, %v", 100, count, iter.Pages(), iter.Err())
+	}
+
+	// BACKWARD from INF_MAX
+	iter = client.XGetRange("myTable", OTSDirection_BACKWARD, end, start, nil, 3)
+	last := 100
+	for iter.Next() {
+		index := iter.Row().GetAttributeColumns()["index"].(int64)
+		if index != int64(last-1) {
+			t.Errorf("excpected index %d but %d", last-1, index)
+		}
+		last = int(index)
+	}
+	if last != 97 {
+		t.Errorf("excpected %d rows but %d", 3, 100-last)
+	}
+
+	var rows_lock sync.Mutex
+	seen := map[int64]bool{}
+	_, ots_err := client.ParallelScan("myTable", start, end, nil, 4, func(row *OTSRow) error {
+		rows_lock.Lock()
+		defer rows_lock.Unlock()
+		seen[row.GetAttributeColumns()["index"].(int64)] = true
+		return nil
+	}, NewRelationCondition("index", OTSComparatorType_LESS_THAN, 50, false))
+	if ots_err != nil || len(seen) != 50 {
+		t.Errorf("excpected %d rows but %d, %v", 50, len(seen), ots_err)
+	}
+}
+
+func Test_emulator_bulk_retry(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+
+	// every row fails once with a retriable error
+	var lock sync.Mutex
+	failed := map[string]bool{}
+	ots_emulator.InjectRowError = func(api_name, table_name string, primary_key []*protobuf.Column) (string, string) {
+		lock.Lock()
+		defer lock.Unlock()
+		key := api_name + primary_key[0].String() + primary_key[1].String()
+		if failed[key] {
+			return "", ""
+		}
+		failed[key] = true
+		return "OTSServerBusy", "Server is busy."
+	}
+
+	writer := client.NewBulkWriter(0, 0)
+	rows := OTSPrimaryKeyRows{}
+	for i := 0; i < 250; i++ {
+		primary_key := OTSPrimaryKey{"gid": 1, "uid": i}
+		writer.Put("myTable", OTSPutRowItem{
+			Condition:        OTSCondition_IGNORE,
+			PrimaryKey:       primary_key,
+			AttributeColumns: OTSAttribute{"index": i},
+		})
+		rows = append(rows, primary_key)
+	}
+	if ots_err := writer.Close(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if len(writer.Failures()) != 0 {
+		t.Fatalf("excpected no failures but %d", len(writer.Failures()))
+	}
+
+	response, ots_err := client.BulkGetRow(&OTSBatchGetRowRequest{{TableName: "myTable", Rows: rows}})
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	for i, row := range response.Tables[0].Rows {
+		if !row.IsOk || row.Row.GetAttributeColumns()["index"] != int64(i) {
+			t.Errorf("unexpected row %d: %v", i, row)
+		}
+	}
+}
@@ -9,12 +9,41 @@ import (
 	"fmt"
 )
 
-// 如果用户不希望使用panic模式，则设置此为false
-var OTSErrorPanicMode bool = true // 默认开启panic模式
+// 如果用户希望在出错时直接panic（旧版本的行为），则设置此为true
+// 关闭时所有错误都通过返回值返回，可以使用errors.Is/errors.As 判断错误类型
+var OTSErrorPanicMode bool = false // 默认关闭panic模式
 
 var ErrNonResponseBody = fmt.Errorf("response body not found")
 var ErrReadResponse = fmt.Errorf("response body read fail")
 
+// 服务端错误，只比较错误号，可以使用errors.Is 判断，如：
+//
+// 		_, ots_err := ots_client.PutRow("myTable", OTSCondition_EXPECT_NOT_EXIST, primary_key, attribute_columns)
+// 		if errors.Is(ots_err, ots2.ErrConditionCheckFail) {
+// 			// 行已经存在
+// 		}
+var (
+	ErrAuthFailed            = &OTSServiceError{Code: "OTSAuthFailed"}
+	ErrParameterInvalid      = &OTSServiceError{Code: "OTSParameterInvalid"}
+	ErrObjectNotExist        = &OTSServiceError{Code: "OTSObjectNotExist"}
+	ErrObjectAlreadyExist    = &OTSServiceError{Code: "OTSObjectAlreadyExist"}
+	ErrConditionCheckFail    = &OTSServiceError{Code: "OTSConditionCheckFail"}
+	ErrInvalidPK             = &OTSServiceError{Code: "OTSInvalidPK"}
+	ErrNotEnoughCapacityUnit = &OTSServiceError{Code: "OTSNotEnoughCapacityUnit"}
+	ErrRowOperationConflict  = &OTSServiceError{Code: "OTSRowOperationConflict"}
+	ErrTableNotReady         = &OTSServiceError{Code: "OTSTableNotReady"}
+	ErrPartitionUnavailable  = &OTSServiceError{Code: "OTSPartitionUnavailable"}
+	ErrServerBusy            = &OTSServiceError{Code: "OTSServerBusy"}
+	ErrOperationThrottled    = &OTSServiceError{Code: "OTSOperationThrottled"}
+	ErrQuotaExhausted        = &OTSServiceError{Code: "OTSQuotaExhausted"}
+	ErrTimeout               = &OTSServiceError{Code: "OTSTimeout"}
+	ErrInternalServerError   = &OTSServiceError{Code: "OTSInternalServerError"}
+	ErrServerUnavailable     = &OTSServiceError{Code: "OTSServerUnavailable"}
+)
+
+// 熔断器打开时客户端直接返回的错误，见 OTSCircuitBreaker
+var ErrCircuitOpen = &OTSServiceError{Code: "OTSCircuitOpen"}
+
 type OTSError struct {
 	ClientError  *OTSClientError
 	ServiceError *OTSServiceError
@@ -80,6 +109,23 @@ func (o *OTSError) String() string {
 	return client_message + " <--> " + service_message
 }
 
+// 返回ClientError 和ServiceError 中不为nil 的错误，用于errors.Is/errors.As
+func (o *OTSError) Unwrap() []error {
+	if o == nil {
+		return nil
+	}
+
+	errs := []error{}
+	if o.ClientError != nil {
+		errs = append(errs, o.ClientError)
+	}
+	if o.ServiceError != nil {
+		errs = append(errs, o.ServiceError)
+	}
+
+	return errs
+}
+
 func (o *OTSError) SetClientError(client_err *OTSClientError) *OTSError {
 	o.ClientError = client_err
 
@@ -93,7 +139,12 @@ func (o *OTSError) SetClientMessage(format string, a ...interface{}) *OTSError {
 	return o
 }
 
+// 请求没有发送时（如客户端校验失败）设置为ClientError
 func (o *OTSError) SetServiceError(service_err *OTSServiceError) *OTSError {
+	if client_err, ok := service_err.GetErr().(*OTSClientError); ok && service_err.Code == "" {
+		o.ClientError = client_err
+		return o
+	}
 	o.ServiceError = service_err
 
 	return o
@@ -196,6 +247,34 @@ func (o *OTSServiceError) Error() string {
 		o.Code, o.Message, o.RequestId)
 }
 
+// 错误号相同时认为是同一个错误，见ErrObjectNotExist 等
+func (o *OTSServiceError) Is(target error) bool {
+	t, ok := target.(*OTSServiceError)
+	if o == nil || !ok || t == nil || t.Code == "" {
+		return false
+	}
+
+	return o.Code == t.Code
+}
+
+// 返回导致该错误的底层错误，为nil 时返回nil
+func (o *OTSServiceError) GetErr() error {
+	if o == nil {
+		return nil
+	}
+
+	return o.Err
+}
+
+// 返回导致该错误的底层错误，如网络错误、context.Canceled、context.DeadlineExceeded
+func (o *OTSServiceError) Unwrap() error {
+	if o == nil {
+		return nil
+	}
+
+	return o.Err
+}
+
 func (o *OTSServiceError) String() string {
 	return fmt.Sprintf("[S]-ErrorCode: %s, ErrorMessage: %s, RequestID: %s",
 		o.Code, o.Message, o.RequestId)
@@ -0,0 +1,70 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test errors for ots2
+package goots
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_error_sentinel(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	primary_key := &OTSPrimaryKey{"gid": 1, "uid": 101}
+	if _, ots_err := client.PutRow("myTable", OTSCondition_EXPECT_NOT_EXIST, primary_key, &OTSAttribute{"age": 20}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	_, ots_err := client.PutRow("myTable", OTSCondition_EXPECT_NOT_EXIST, primary_key, &OTSAttribute{"age": 21})
+	if !errors.Is(ots_err, ErrConditionCheckFail) {
+		t.Fatalf("error should be ErrConditionCheckFail, not %v", ots_err)
+	}
+	if errors.Is(ots_err, ErrObjectNotExist) {
+		t.Fatal("error should not be ErrObjectNotExist")
+	}
+	var service_err *OTSServiceError
+	if !errors.As(ots_err, &service_err) || service_err.Code != "OTSConditionCheckFail" || service_err.HttpStatus != 403 {
+		t.Fatalf("unexpected service error: %v", service_err)
+	}
+
+	_, ots_err = client.DescribeTable("notExistTable")
+	if !errors.Is(ots_err, ErrObjectNotExist) {
+		t.Fatalf("error should be ErrObjectNotExist, not %v", ots_err)
+	}
+}
+
+func Test_error_no_panic(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	// unsupported column value type
+	_, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, &OTSPrimaryKey{"gid": 1, "uid": 101}, &OTSAttribute{"age": struct{}{}})
+	if ots_err == nil {
+		t.Fatal("put an unsupported value should fail")
+	}
+	if errors.Is(ots_err, ErrParameterInvalid) {
+		t.Fatal("invalid value should be rejected before sending")
+	}
+
+	// unknown param
+	if err := client.SetWithError(DictString{"Unknown": 1}); err == nil {
+		t.Fatal("set an unknown param should fail")
+	}
+	if err := client.SetWithError(DictString{"InstanceName": "OtherInstance", "SocketTimeout": "10"}); err == nil {
+		t.Fatal("set SocketTimeout with string should fail")
+	}
+	if client.InstanceName != "TestInstance" {
+		t.Fatalf("client should not be modified on error, InstanceName: %s", client.InstanceName)
+	}
+	if err := client.SetWithError(DictString{"SocketTimeout": 10}); err != nil || client.SocketTimeout != 10 {
+		t.Fatalf("set SocketTimeout failed: %v", err)
+	}
+	if client.Set(DictString{"InstanceName": 1}).InstanceName != "TestInstance" {
+		t.Fatalf("client should not be modified on error, InstanceName: %s", client.InstanceName)
+	}
+}
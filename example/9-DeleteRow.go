@@ -27,7 +27,7 @@ func main() {
 	// set running environment
 	ots2.OTSDebugEnable = true
 	ots2.OTSLoggerEnable = true
-	ots2.OTSErrorPanicMode = true // 默认为开启，如果不喜欢panic则设置此为false
+	ots2.OTSErrorPanicMode = false // 默认为关闭，所有错误通过返回值返回；设置为true 则在出错时panic
 
 	fmt.Println("Test goots start ...")
 
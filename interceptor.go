@@ -0,0 +1,178 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Interceptor for ots2
+package goots
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+var error_type = reflect.TypeOf((*error)(nil)).Elem()
+
+// 一次API 调用
+type OTSCall struct {
+	// API 名，如 "GetRow"
+	Api string
+	// API 的参数，与编码器的参数相同，如GetRow 为 table_name, primary_key, columns_to_get, filter
+	// 拦截器可以在调用invoker 前修改
+	Args []interface{}
+	// 每次HTTP 请求（包括重试）的结果，invoker 返回后可用
+	Attempts []OTSAttempt
+}
+
+// 一次HTTP 请求
+type OTSAttempt struct {
+	RetryTimes int
+	StartTime  time.Time
+	Latency    time.Duration
+	// 未收到响应时为0
+	HttpStatus int
+	RequestId  string
+	// 请求成功时为nil
+	Error *OTSServiceError
+}
+
+// 执行API 调用，返回API 的返回值：
+// 		如GetRow 为*OTSGetRowResponse，ListTable 为*OTSListTableResponse，
+// 		CreateTable、DeleteTable 等没有返回值的API 为nil
+type OTSInvoker func(ctx context.Context, call *OTSCall) (response interface{}, ots_service_error *OTSServiceError)
+
+// 拦截器，包裹每一次API 调用（包括所有重试）
+//
+// 		拦截器可以修改ctx 和call.Args 后调用invoker，也可以不调用invoker 直接返回（短路），
+// 		此时response 的类型必须与该API 的返回值类型相同，否则API 返回ClientError。
+//
+// 		示例：
+//
+// 		ots_client.Use(func(ctx context.Context, call *OTSCall, invoker OTSInvoker) (interface{}, *OTSServiceError) {
+// 			start := time.Now()
+// 			response, ots_service_error := invoker(ctx, call)
+// 			fmt.Println(call.Api, len(call.Attempts), time.Since(start), ots_service_error)
+// 			return response, ots_service_error
+// 		})
+//
+type OTSInterceptor func(ctx context.Context, call *OTSCall, invoker OTSInvoker) (response interface{}, ots_service_error *OTSServiceError)
+
+// 说明：注册拦截器，先注册的拦截器在外层。
+func (o *OTSClient) Use(interceptors ...OTSInterceptor) *OTSClient {
+	o.Interceptors = append(o.Interceptors, interceptors...)
+
+	return o
+}
+
+// the invoker with all interceptors
+func (o *OTSClient) _get_invoker() OTSInvoker {
+	invoker := o._invoke
+	for i := len(o.Interceptors) - 1; i >= 0; i-- {
+		interceptor, next := o.Interceptors[i], invoker
+		invoker = func(ctx context.Context, call *OTSCall) (interface{}, *OTSServiceError) {
+			return interceptor(ctx, call, next)
+		}
+	}
+
+	return invoker
+}
+
+// the innermost invoker, send the request and decode the response
+func (o *OTSClient) _invoke(ctx context.Context, call *OTSCall) (response interface{}, ots_service_error *OTSServiceError) {
+	resp, ots_service_error := o._send_request(ctx, call)
+	if ots_service_error != nil {
+		return nil, ots_service_error
+	}
+
+	response, err := o._check_request_helper_error(resp)
+	if err != nil {
+		ots_service_error = new(OTSServiceError).SetErrorMessage("%s", err)
+		ots_service_error.Err = err
+		return nil, ots_service_error
+	}
+
+	return response, nil
+}
+
+func (o *OTSCall) _add_attempt(retry_times int, start_time time.Time, status int, headers DictString, ots_service_error *OTSServiceError) {
+	attempt := OTSAttempt{
+		RetryTimes: retry_times,
+		StartTime:  start_time,
+		Latency:    time.Since(start_time),
+		HttpStatus: status,
+	}
+	if request_id, ok := headers.Get("x-ots-requestid").(string); ok {
+		attempt.RequestId = request_id
+	}
+	if ots_service_error != nil {
+		// the error is reused by the next attempt
+		e := *ots_service_error
+		attempt.Error = &e
+	}
+	o.Attempts = append(o.Attempts, attempt)
+}
+
+// the table names of call, the tables of BatchGetRow and BatchWriteRow are joined by ","
+func (o *OTSCall) _get_table_name() string {
+	if len(o.Args) == 0 {
+		return ""
+	}
+
+	table_names := []string{}
+	switch v := o.Args[0].(type) {
+	case string:
+		return v
+	case *OTSBatchGetRowRequest:
+		if v != nil {
+			for _, table := range *v {
+				table_names = append(table_names, table.TableName)
+			}
+		}
+	case *OTSBatchWriteRowRequest:
+		if v != nil {
+			for _, table := range *v {
+				table_names = append(table_names, table.TableName)
+			}
+		}
+	}
+
+	return strings.Join(table_names, ",")
+}
+
+// call fn with the consumed capacity unit of each table in response
+func _foreach_consumed(call *OTSCall, response interface{}, fn func(table_name string, read, write int32)) {
+	table_name := ""
+	if len(call.Args) > 0 {
+		table_name, _ = call.Args[0].(string)
+	}
+
+	switch r := response.(type) {
+	case *OTSGetRowResponse:
+		fn(table_name, r.GetReadConsumed(), 0)
+	case *OTSGetRangeResponse:
+		fn(table_name, r.GetReadConsumed(), 0)
+	case *OTSPutRowResponse:
+		fn(table_name, 0, r.GetWriteConsumed())
+	case *OTSUpdateRowResponse:
+		fn(table_name, 0, r.GetWriteConsumed())
+	case *OTSDeleteRowResponse:
+		fn(table_name, 0, r.GetWriteConsumed())
+	case *OTSBatchGetRowResponse:
+		for _, table := range r.GetTables() {
+			for _, row := range table.GetRows() {
+				fn(table.GetTableName(), row.GetReadConsumed(), 0)
+			}
+		}
+	case *OTSBatchWriteRowResponse:
+		for _, table := range r.GetTables() {
+			for _, rows := range [][]*OTSRowInBatchWriteRowResponseItem{table.GetPutRows(), table.GetUpdateRows(), table.GetDeleteRows()} {
+				for _, row := range rows {
+					fn(table.GetTableName(), 0, row.GetWriteConsumed())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test interceptor for ots2
+package goots
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_interceptor(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+
+	// the order of interceptors and the attempts
+	order := []string{}
+	var last *OTSCall
+	client.Use(func(ctx context.Context, call *OTSCall, invoker OTSInvoker) (interface{}, *OTSServiceError) {
+		order = append(order, "outer")
+		response, ots_service_error := invoker(ctx, call)
+		last = call
+		return response, ots_service_error
+	}, func(ctx context.Context, call *OTSCall, invoker OTSInvoker) (interface{}, *OTSServiceError) {
+		order = append(order, "inner")
+		return invoker(ctx, call)
+	})
+	calls := 0
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		calls += 1
+		if calls == 1 {
+			return 503, "OTSServerBusy", "Server is busy."
+		}
+		return 0, "", ""
+	}
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Fatalf("unexpected order: %v", order)
+	}
+	if last.Api != "ListTable" || len(last.Attempts) != 2 {
+		t.Fatalf("unexpected call: %+v", last)
+	}
+	if !errors.Is(last.Attempts[0].Error, ErrServerBusy) || last.Attempts[0].HttpStatus != 503 || last.Attempts[0].RequestId == "" ||
+		last.Attempts[1].Error != nil || last.Attempts[1].RetryTimes != 1 || last.Attempts[1].Latency <= 0 {
+		t.Fatalf("unexpected attempts: %+v", last.Attempts)
+	}
+
+	// the error of call
+	if _, ots_err := client.DescribeTable("notExistTable"); !errors.Is(ots_err, ErrObjectNotExist) {
+		t.Fatalf("error should be ErrObjectNotExist, not %v", ots_err)
+	}
+	if last.Api != "DescribeTable" || last.Args[0] != "notExistTable" || !errors.Is(last.Attempts[0].Error, ErrObjectNotExist) {
+		t.Fatalf("unexpected call: %+v", last)
+	}
+
+	// modify the arguments
+	client.Interceptors = nil
+	client.Use(func(ctx context.Context, call *OTSCall, invoker OTSInvoker) (interface{}, *OTSServiceError) {
+		if call.Api == "PutRow" {
+			attribute_columns := OTSAttribute{"tenant": "t1"}
+			for k, v := range *call.Args[3].(*OTSAttribute) {
+				attribute_columns[k] = v
+			}
+			call.Args[3] = &attribute_columns
+		}
+		return invoker(ctx, call)
+	})
+	primary_key := &OTSPrimaryKey{"gid": 1, "uid": 101}
+	if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, primary_key, &OTSAttribute{"age": 20}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	get_row_response, ots_err := client.GetRow("myTable", primary_key, nil)
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if get_row_response.Row.GetAttributeColumns().Get("tenant") != "t1" {
+		t.Fatalf("unexpected row: %v", get_row_response.Row)
+	}
+
+	// short-circuit
+	client.Use(func(ctx context.Context, call *OTSCall, invoker OTSInvoker) (interface{}, *OTSServiceError) {
+		switch call.Api {
+		case "ListTable":
+			return &OTSListTableResponse{TableNames: []string{"fakeTable"}}, nil
+		case "DeleteTable":
+			return nil, &OTSServiceError{HttpStatus: 403, Code: "OTSAuthFailed", Message: "injected"}
+		case "DescribeTable":
+			return nil, nil
+		case "GetRow":
+			return &OTSPutRowResponse{}, nil
+		}
+		return invoker(ctx, call)
+	})
+	calls = 0
+	list_table_response, ots_err := client.ListTable()
+	if ots_err != nil || len(list_table_response.TableNames) != 1 || list_table_response.TableNames[0] != "fakeTable" || calls != 0 {
+		t.Fatalf("unexpected response: %v, %v", list_table_response, ots_err)
+	}
+	// a short-circuit without the response of API does not panic
+	if _, ots_err = client.DescribeTable("myTable"); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("nil response should fail on client, not %v", ots_err)
+	}
+	if _, ots_err = client.GetRow("myTable", primary_key, nil); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("wrong response should fail on client, not %v", ots_err)
+	}
+	if ots_err = client.DeleteTable("myTable"); !errors.Is(ots_err, ErrAuthFailed) {
+		t.Fatalf("error should be ErrAuthFailed, not %v", ots_err)
+	}
+}
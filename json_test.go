@@ -0,0 +1,106 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test typed JSON encoding for ots2
+package goots
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_json_row(t *testing.T) {
+	row := &OTSRow{
+		PrimaryKeyColumns:        OTSPrimaryKey{"uid": int64(math.MaxInt64), "gid": "a"},
+		OrderedPrimaryKeyColumns: OTSOrderedPrimaryKey{{K: "uid", V: int64(math.MaxInt64)}, {K: "gid", V: "a"}},
+		AttributeColumns: OTSAttribute{
+			"age":    int64(1),
+			"price":  float64(1),
+			"nan":    math.Inf(-1),
+			"name":   "AQID",
+			"avatar": []byte{1, 2, 3},
+			"ok":     true,
+		},
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"uid":{"type":"INTEGER","value":"9223372036854775807"}`) {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+
+	decoded := new(OTSRow)
+	if err = json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	attribute_columns := decoded.GetAttributeColumns()
+	if attribute_columns["age"] != int64(1) || attribute_columns["price"] != float64(1) || attribute_columns["name"] != "AQID" ||
+		attribute_columns["ok"] != true || !math.IsInf(attribute_columns["nan"].(float64), -1) {
+		t.Fatalf("unexpected attribute columns: %v", attribute_columns)
+	}
+	if v, ok := attribute_columns["avatar"].([]byte); !ok || !bytes.Equal(v, []byte{1, 2, 3}) {
+		t.Fatalf("unexpected avatar: %v", attribute_columns["avatar"])
+	}
+	ordered := decoded.GetOrderedPrimaryKeyColumns()
+	if len(ordered) != 2 || ordered[0].K != "uid" || ordered[0].V != int64(math.MaxInt64) || decoded.GetPrimaryKeyColumns()["gid"] != "a" {
+		t.Fatalf("unexpected primary key: %v, %v", ordered, decoded.GetPrimaryKeyColumns())
+	}
+
+	// INF_MIN and INF_MAX are kept
+	primary_key := &OTSOrderedPrimaryKey{{K: "gid", V: OTSColumnType_INF_MIN}, {K: "uid", V: OTSColumnType_INF_MAX}}
+	if data, err = json.Marshal(primary_key); err != nil {
+		t.Fatal(err)
+	}
+	var decoded_primary_key OTSOrderedPrimaryKey
+	if err = json.Unmarshal(data, &decoded_primary_key); err != nil {
+		t.Fatal(err)
+	}
+	if decoded_primary_key[0].V != OTSColumnType_INF_MIN || decoded_primary_key[1].V != OTSColumnType_INF_MAX {
+		t.Fatalf("unexpected primary key: %s, %v", data, decoded_primary_key)
+	}
+
+	// invalid values
+	if _, err = json.Marshal(OTSAttribute{"age": uint64(math.MaxUint64)}); err == nil {
+		t.Fatal("uint64 overflowing int64 should fail")
+	}
+	if err = json.Unmarshal([]byte(`{"age":{"type":"INTEGER","value":"1.5"}}`), new(OTSAttribute)); err == nil {
+		t.Fatal("invalid INTEGER should fail")
+	}
+	if err = json.Unmarshal([]byte(`{"age":{"type":"DATE","value":"1"}}`), new(OTSAttribute)); err == nil {
+		t.Fatal("invalid type should fail")
+	}
+}
+
+func Test_json_describe_table(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	describe_table_response, ots_err := client.DescribeTable("myTable")
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	data, err := json.Marshal(describe_table_response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"SchemaOfPrimaryKey":[{"name":"gid","type":"INTEGER"},{"name":"uid","type":"INTEGER"}]`) {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+
+	decoded := new(OTSDescribeTableResponse)
+	if err = json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TableMeta.TableName != "myTable" || decoded.TableMeta.SchemaOfPrimaryKey[1].V != OTSColumnType_INTEGER ||
+		decoded.ReservedThroughputDetails.CapacityUnit.Read != 100 ||
+		!decoded.ReservedThroughputDetails.LastIncreaseTime.Equal(describe_table_response.ReservedThroughputDetails.LastIncreaseTime) {
+		t.Fatalf("unexpected response: %s", data)
+	}
+}
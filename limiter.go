@@ -0,0 +1,339 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Capacity unit limiter for ots2
+package goots
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+const (
+	DEFAULT_LIMITER_DECREASE_FACTOR = 0.7
+	DEFAULT_LIMITER_INCREASE_RATIO  = 0.05
+	DEFAULT_LIMITER_MIN_RATIO       = 0.1
+)
+
+// 按表限制读写CapacityUnit 的令牌桶限速器，通过拦截器在发送请求前等待，避免服务端返回OTSNotEnoughCapacityUnit
+//
+// 		每次请求前按行数预估消耗的CU（GetRow、PutRow 等为1，BatchGetRow、BatchWriteRow 为每个表的行数），
+// 		收到响应后按实际消耗的CU 修正，超出的部分会延后之后的请求。
+// 		收到流控错误时速率乘以DecreaseFactor，之后每次成功的请求恢复IncreaseRatio，最多恢复到设置的速率。
+// 		没有设置速率的表不限速。
+//
+// 		示例：
+//
+// 		limiter := NewCapacityLimiter()
+// 		limiter.LoadReservedThroughput(ctx, ots_client, "myTable") // 或 limiter.SetTable("myTable", 100, 100)
+// 		ots_client.Use(limiter.Interceptor())
+//
+type OTSCapacityLimiter struct {
+	// 收到流控错误时速率的衰减系数，默认为DEFAULT_LIMITER_DECREASE_FACTOR
+	DecreaseFactor float64
+	// 每次成功的请求恢复的速率占设置速率的比例，默认为DEFAULT_LIMITER_INCREASE_RATIO
+	IncreaseRatio float64
+	// 衰减后速率不低于设置速率的比例，默认为DEFAULT_LIMITER_MIN_RATIO
+	MinRatio float64
+
+	lock   sync.Mutex
+	tables map[string]*table_limiter
+}
+
+type table_limiter struct {
+	read  *cu_bucket
+	write *cu_bucket
+}
+
+// a token bucket of capacity unit, the tokens can be negative after charging the actual consumption
+type cu_bucket struct {
+	rate         float64 // the configured CU per second
+	current_rate float64
+	tokens       float64
+	last         time.Time
+}
+
+func NewCapacityLimiter() *OTSCapacityLimiter {
+	return &OTSCapacityLimiter{
+		DecreaseFactor: DEFAULT_LIMITER_DECREASE_FACTOR,
+		IncreaseRatio:  DEFAULT_LIMITER_INCREASE_RATIO,
+		MinRatio:       DEFAULT_LIMITER_MIN_RATIO,
+		tables:         map[string]*table_limiter{},
+	}
+}
+
+// 说明：设置表每秒的读写CU，小于等于0 时不限制。
+func (o *OTSCapacityLimiter) SetTable(table_name string, read_cu, write_cu int32) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	now := time.Now()
+	o.tables[table_name] = &table_limiter{
+		read:  _new_cu_bucket(float64(read_cu), now),
+		write: _new_cu_bucket(float64(write_cu), now),
+	}
+}
+
+// 说明：通过DescribeTable 获取表的预留读写吞吐量，并设置为该表的读写CU 速率。
+//
+// 		返回：错误信息。
+func (o *OTSCapacityLimiter) LoadReservedThroughput(ctx context.Context, client *OTSClient, table_name string) *OTSError {
+	describe_table_response, ots_err := client.DescribeTableWithContext(ctx, table_name)
+	if ots_err != nil {
+		return ots_err
+	}
+	if describe_table_response.ReservedThroughputDetails == nil || describe_table_response.ReservedThroughputDetails.CapacityUnit == nil {
+		return new(OTSError).SetClientMessage("[LoadReservedThroughput] reserved throughput of %s is not found", table_name)
+	}
+
+	capacity_unit := describe_table_response.ReservedThroughputDetails.CapacityUnit
+	o.SetTable(table_name, capacity_unit.GetRead(), capacity_unit.GetWrite())
+
+	return nil
+}
+
+// 说明：返回表当前的读写CU 速率，未设置的表为0。
+func (o *OTSCapacityLimiter) Rate(table_name string) (read_cu, write_cu float64) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	table, ok := o.tables[table_name]
+	if !ok {
+		return 0, 0
+	}
+
+	return table.read.current_rate, table.write.current_rate
+}
+
+// 说明：返回限速的拦截器，通过OTSClient.Use 注册。
+func (o *OTSCapacityLimiter) Interceptor() OTSInterceptor {
+	return func(ctx context.Context, call *OTSCall, invoker OTSInvoker) (interface{}, *OTSServiceError) {
+		estimated := _estimate_capacity_unit(call)
+		if ctx_err := o._wait(ctx, estimated); ctx_err != nil {
+			ots_service_error := _set_context_error(new(OTSServiceError), ctx_err)
+			return nil, ots_service_error
+		}
+
+		response, ots_service_error := invoker(ctx, call)
+		o._observe(call, estimated, response)
+
+		return response, ots_service_error
+	}
+}
+
+// take the estimated capacity unit from buckets, and wait until they are available
+func (o *OTSCapacityLimiter) _wait(ctx context.Context, estimated map[string]*OTSCapacityUnit) error {
+	o.lock.Lock()
+	now := time.Now()
+	wait := time.Duration(0)
+	for table_name, capacity_unit := range estimated {
+		table, ok := o.tables[table_name]
+		if !ok {
+			continue
+		}
+		if d := table.read.take(now, float64(capacity_unit.Read)); d > wait {
+			wait = d
+		}
+		if d := table.write.take(now, float64(capacity_unit.Write)); d > wait {
+			wait = d
+		}
+	}
+	o.lock.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// give back the capacity unit
+		o._charge(estimated, nil)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// charge the actual consumption, and adapt the rate from throttling errors
+func (o *OTSCapacityLimiter) _observe(call *OTSCall, estimated map[string]*OTSCapacityUnit, response interface{}) {
+	consumed := map[string]*OTSCapacityUnit{}
+	if response != nil {
+		for table_name := range estimated {
+			consumed[table_name] = &OTSCapacityUnit{}
+		}
+		_foreach_consumed(call, response, func(table_name string, read, write int32) {
+			if _, ok := consumed[table_name]; !ok {
+				consumed[table_name] = &OTSCapacityUnit{}
+			}
+			consumed[table_name].Read += read
+			consumed[table_name].Write += write
+		})
+	}
+	o._charge(estimated, consumed)
+
+	throttled := false
+	for _, attempt := range call.Attempts {
+		if attempt.Error != nil && is_server_throttling_exception(attempt.Error) {
+			throttled = true
+		}
+	}
+	throttled_tables := _throttled_tables(response)
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for table_name, capacity_unit := range estimated {
+		table, ok := o.tables[table_name]
+		if !ok {
+			continue
+		}
+		if throttled || throttled_tables[table_name] {
+			if capacity_unit.Read > 0 {
+				table.read.decrease(o.DecreaseFactor, o.MinRatio)
+			}
+			if capacity_unit.Write > 0 {
+				table.write.decrease(o.DecreaseFactor, o.MinRatio)
+			}
+		} else if response != nil {
+			table.read.increase(o.IncreaseRatio)
+			table.write.increase(o.IncreaseRatio)
+		}
+	}
+}
+
+// replace the estimated capacity unit with the consumed, nil consumed gives back all of them
+func (o *OTSCapacityLimiter) _charge(estimated, consumed map[string]*OTSCapacityUnit) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for table_name, capacity_unit := range estimated {
+		table, ok := o.tables[table_name]
+		if !ok {
+			continue
+		}
+		actual := &OTSCapacityUnit{}
+		if consumed != nil && consumed[table_name] != nil {
+			actual = consumed[table_name]
+		}
+		table.read.tokens -= float64(actual.Read - capacity_unit.Read)
+		table.write.tokens -= float64(actual.Write - capacity_unit.Write)
+	}
+}
+
+func _new_cu_bucket(rate float64, now time.Time) *cu_bucket {
+	return &cu_bucket{rate: rate, current_rate: rate, tokens: rate, last: now}
+}
+
+// take n tokens, return how long to wait before they are available
+func (o *cu_bucket) take(now time.Time, n float64) time.Duration {
+	if o.rate <= 0 || n <= 0 {
+		return 0
+	}
+
+	// refill, at most 1 second of tokens
+	o.tokens += now.Sub(o.last).Seconds() * o.current_rate
+	if o.tokens > o.current_rate {
+		o.tokens = o.current_rate
+	}
+	o.last = now
+
+	o.tokens -= n
+	if o.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-o.tokens / o.current_rate * float64(time.Second))
+}
+
+func (o *cu_bucket) decrease(factor, min_ratio float64) {
+	if o.rate <= 0 {
+		return
+	}
+	o.current_rate *= factor
+	if o.current_rate < o.rate*min_ratio {
+		o.current_rate = o.rate * min_ratio
+	}
+}
+
+func (o *cu_bucket) increase(ratio float64) {
+	if o.rate <= 0 {
+		return
+	}
+	o.current_rate += o.rate * ratio
+	if o.current_rate > o.rate {
+		o.current_rate = o.rate
+	}
+}
+
+// the capacity unit of each table before sending the request, one per row
+func _estimate_capacity_unit(call *OTSCall) map[string]*OTSCapacityUnit {
+	estimated := map[string]*OTSCapacityUnit{}
+	if len(call.Args) == 0 {
+		return estimated
+	}
+
+	switch call.Api {
+	case "GetRow", "GetRange":
+		if table_name, ok := call.Args[0].(string); ok {
+			estimated[table_name] = &OTSCapacityUnit{Read: 1}
+		}
+	case "PutRow", "UpdateRow", "DeleteRow":
+		if table_name, ok := call.Args[0].(string); ok {
+			estimated[table_name] = &OTSCapacityUnit{Write: 1}
+		}
+	case "BatchGetRow":
+		if batch_list, ok := call.Args[0].(*OTSBatchGetRowRequest); ok && batch_list != nil {
+			for _, table := range *batch_list {
+				if _, ok := estimated[table.TableName]; !ok {
+					estimated[table.TableName] = &OTSCapacityUnit{}
+				}
+				estimated[table.TableName].Read += int32(len(table.Rows))
+			}
+		}
+	case "BatchWriteRow":
+		if batch_list, ok := call.Args[0].(*OTSBatchWriteRowRequest); ok && batch_list != nil {
+			for _, table := range *batch_list {
+				if _, ok := estimated[table.TableName]; !ok {
+					estimated[table.TableName] = &OTSCapacityUnit{}
+				}
+				estimated[table.TableName].Write += int32(len(table.PutRows) + len(table.UpdateRows) + len(table.DeleteRows))
+			}
+		}
+	}
+
+	return estimated
+}
+
+// the tables with throttled rows in BatchGetRow or BatchWriteRow
+func _throttled_tables(response interface{}) map[string]bool {
+	throttled := map[string]bool{}
+	is_throttled := func(is_ok bool, error_code, error_message string) bool {
+		return !is_ok && is_server_throttling_exception(&OTSServiceError{Code: error_code, Message: error_message})
+	}
+
+	switch r := response.(type) {
+	case *OTSBatchGetRowResponse:
+		for _, table := range r.GetTables() {
+			for _, row := range table.GetRows() {
+				if is_throttled(row.IsOk, row.ErrorCode, row.ErrorMessage) {
+					throttled[table.GetTableName()] = true
+				}
+			}
+		}
+	case *OTSBatchWriteRowResponse:
+		for _, table := range r.GetTables() {
+			for _, rows := range [][]*OTSRowInBatchWriteRowResponseItem{table.GetPutRows(), table.GetUpdateRows(), table.GetDeleteRows()} {
+				for _, row := range rows {
+					if is_throttled(row.IsOk, row.ErrorCode, row.ErrorMessage) {
+						throttled[table.GetTableName()] = true
+					}
+				}
+			}
+		}
+	}
+
+	return throttled
+}
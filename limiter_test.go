@@ -0,0 +1,91 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test capacity unit limiter for ots2
+package goots
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_capacity_limiter(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+
+	limiter := NewCapacityLimiter()
+	client.Use(limiter.Interceptor())
+
+	// seeded from DescribeTable
+	if ots_err := limiter.LoadReservedThroughput(context.Background(), client, "myTable"); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if read_cu, write_cu := limiter.Rate("myTable"); read_cu != 100 || write_cu != 100 {
+		t.Fatalf("unexpected rate: %v, %v", read_cu, write_cu)
+	}
+	if ots_err := limiter.LoadReservedThroughput(context.Background(), client, "notExistTable"); !errors.Is(ots_err, ErrObjectNotExist) {
+		t.Fatalf("error should be ErrObjectNotExist, not %v", ots_err)
+	}
+
+	// 10 write CU per second, the first 10 rows are burst
+	limiter.SetTable("myTable", 0, 10)
+	start_time := time.Now()
+	for i := 0; i < 15; i++ {
+		if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, &OTSPrimaryKey{"gid": 1, "uid": i}, &OTSAttribute{"age": i}); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+	if elapsed := time.Since(start_time); elapsed < 400*time.Millisecond {
+		t.Fatalf("15 rows should take about 500ms, not %v", elapsed)
+	}
+	// reads are not limited
+	start_time = time.Now()
+	for i := 0; i < 15; i++ {
+		if _, ots_err := client.GetRow("myTable", &OTSPrimaryKey{"gid": 1, "uid": i}, nil); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+	if elapsed := time.Since(start_time); elapsed > 400*time.Millisecond {
+		t.Fatalf("reads should not be limited, took %v", elapsed)
+	}
+
+	// decrease on throttling, then recover
+	calls := 0
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		calls += 1
+		if calls == 1 {
+			return 403, "OTSNotEnoughCapacityUnit", "Remaining capacity unit is not enough."
+		}
+		return 0, "", ""
+	}
+	if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, &OTSPrimaryKey{"gid": 2, "uid": 1}, &OTSAttribute{"age": 1}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if read_cu, write_cu := limiter.Rate("myTable"); read_cu != 0 || write_cu != 10*DEFAULT_LIMITER_DECREASE_FACTOR {
+		t.Fatalf("unexpected rate: %v, %v", read_cu, write_cu)
+	}
+	ots_emulator.InjectError = nil
+	if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, &OTSPrimaryKey{"gid": 2, "uid": 2}, &OTSAttribute{"age": 2}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, write_cu := limiter.Rate("myTable"); write_cu <= 10*DEFAULT_LIMITER_DECREASE_FACTOR || write_cu > 10 {
+		t.Fatalf("unexpected rate: %v", write_cu)
+	}
+
+	// the context is done while waiting
+	limiter.SetTable("myTable", 0, 1)
+	if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, &OTSPrimaryKey{"gid": 3, "uid": 1}, &OTSAttribute{"age": 1}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ots_err := client.PutRowWithContext(ctx, "myTable", OTSCondition_IGNORE, &OTSPrimaryKey{"gid": 3, "uid": 2}, &OTSAttribute{"age": 2}); !errors.Is(ots_err, context.DeadlineExceeded) {
+		t.Fatalf("error should be context.DeadlineExceeded, not %v", ots_err)
+	}
+}
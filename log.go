@@ -5,9 +5,243 @@
 // Logger for ots2
 package goots
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+// 结构化日志接口，可以通过OTSClient.Logger 为每个OTSClient 单独设置
+//
+// fields 为交替出现的键值对，如 "api", "GetRow", "request_id", "xxx"，与log/slog 的用法相同。
+// 日志级别：
+// 		Debug 请求和响应的详细内容
+// 		Info  每次API调用完成
+// 		Warn  每次重试
+// 		Error API调用最终失败
+type OTSLogger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+}
+
+// 可选接口，OTSLogger 同时实现此接口时，日志通过XxxContext 输出，ctx 为API 调用的ctx（XxxWithContext 的参数），
+// 可以从中获取trace id 等信息；*slog.Logger 和NewSlogLogger 返回的OTSLogger 都实现了此接口
+type OTSContextLogger interface {
+	DebugContext(ctx context.Context, msg string, fields ...interface{})
+	InfoContext(ctx context.Context, msg string, fields ...interface{})
+	WarnContext(ctx context.Context, msg string, fields ...interface{})
+	ErrorContext(ctx context.Context, msg string, fields ...interface{})
+}
+
+// 丢弃所有日志的OTSLogger
+type OTSNopLogger struct{}
+
+func (OTSNopLogger) Debug(msg string, fields ...interface{}) {}
+func (OTSNopLogger) Info(msg string, fields ...interface{})  {}
+func (OTSNopLogger) Warn(msg string, fields ...interface{})  {}
+func (OTSNopLogger) Error(msg string, fields ...interface{}) {}
+
+var (
+	logger_once   sync.Once
+	debug_logger  OTSLogger // OTSDebugEnable
+	stderr_logger OTSLogger // OTSLoggerEnable
+)
+
+// 初始化未设置OTSClient.Logger 时使用的默认日志：
+// 		OTSDebugEnable 为true 时，输出Debug 及以上级别的日志到stderr
+// 		OTSLoggerEnable 为true 时，输出Info 及以上级别的日志到stderr
+// 		否则不输出日志
 func LoggerInit() error {
-	// TODO:
-	// open log file
+	logger_once.Do(func() {
+		debug_logger = &ots_text_logger{w: os.Stderr, level: log_level_debug}
+		stderr_logger = &ots_text_logger{w: os.Stderr, level: log_level_info}
+	})
 
 	return nil
 }
+
+type log_level int
+
+const (
+	log_level_debug log_level = iota
+	log_level_info
+	log_level_warn
+	log_level_error
+)
+
+func (l log_level) String() string {
+	switch l {
+	case log_level_debug:
+		return "DEBUG"
+	case log_level_info:
+		return "INFO"
+	case log_level_warn:
+		return "WARN"
+	}
+
+	return "ERROR"
+}
+
+// the default logger, writes the logs of level and above in the key=value format of log/slog
+type ots_text_logger struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level log_level
+}
+
+func (o *ots_text_logger) Debug(msg string, fields ...interface{}) {
+	o.log(log_level_debug, msg, fields)
+}
+
+func (o *ots_text_logger) Info(msg string, fields ...interface{}) {
+	o.log(log_level_info, msg, fields)
+}
+
+func (o *ots_text_logger) Warn(msg string, fields ...interface{}) {
+	o.log(log_level_warn, msg, fields)
+}
+
+func (o *ots_text_logger) Error(msg string, fields ...interface{}) {
+	o.log(log_level_error, msg, fields)
+}
+
+func (o *ots_text_logger) log(level log_level, msg string, fields []interface{}) {
+	if level < o.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("time=" + time.Now().Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteString(" level=" + level.String())
+	b.WriteString(" msg=" + _log_quote(msg))
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			b.WriteString(" !BADKEY=" + _log_quote(fmt.Sprint(fields[i])))
+			break
+		}
+		b.WriteString(" " + fmt.Sprint(fields[i]) + "=" + _log_quote(fmt.Sprint(fields[i+1])))
+	}
+	b.WriteString("\n")
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	io.WriteString(o.w, b.String())
+}
+
+// quote the value if it is empty or has spaces, quotes or equal signs
+func _log_quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// write the log to logger, with ctx if logger is an OTSContextLogger
+func _log(ctx context.Context, logger OTSLogger, level log_level, msg string, fields ...interface{}) {
+	if ctx_logger, ok := logger.(OTSContextLogger); ok && ctx != nil {
+		switch level {
+		case log_level_debug:
+			ctx_logger.DebugContext(ctx, msg, fields...)
+		case log_level_info:
+			ctx_logger.InfoContext(ctx, msg, fields...)
+		case log_level_warn:
+			ctx_logger.WarnContext(ctx, msg, fields...)
+		default:
+			ctx_logger.ErrorContext(ctx, msg, fields...)
+		}
+		return
+	}
+
+	switch level {
+	case log_level_debug:
+		logger.Debug(msg, fields...)
+	case log_level_info:
+		logger.Info(msg, fields...)
+	case log_level_warn:
+		logger.Warn(msg, fields...)
+	default:
+		logger.Error(msg, fields...)
+	}
+}
+
+// the logger of client, with the logger name as a field
+func (o *OTSClient) _get_logger() (OTSLogger, []interface{}) {
+	fields := []interface{}{}
+	if o.LoggerName != "" {
+		fields = append(fields, "logger", o.LoggerName)
+	}
+
+	if o.Logger != nil {
+		return o.Logger, fields
+	}
+	LoggerInit()
+	if OTSDebugEnable {
+		return debug_logger, fields
+	}
+	if OTSLoggerEnable {
+		return stderr_logger, fields
+	}
+
+	return OTSNopLogger{}, fields
+}
+
+func _log_fields(base []interface{}, fields ...interface{}) []interface{} {
+	r := make([]interface{}, 0, len(base)+len(fields))
+	return append(append(r, base...), fields...)
+}
+
+func (o *OTSClient) _log_debug(ctx context.Context, msg string, fields ...interface{}) {
+	logger, base := o._get_logger()
+	_log(ctx, logger, log_level_debug, msg, _log_fields(base, fields...)...)
+}
+
+func (o *OTSClient) _log_retry(ctx context.Context, api_name string, retry_times int, retry_delay float64, ots_service_error *OTSServiceError) {
+	logger, base := o._get_logger()
+	_log(ctx, logger, log_level_warn, "OTS request retry", _log_fields(base,
+		"api", api_name,
+		"retry_times", retry_times,
+		"retry_delay", time.Duration(retry_delay*1000)*time.Millisecond,
+		"http_status", ots_service_error.HttpStatus,
+		"error_code", ots_service_error.Code,
+		"request_id", ots_service_error.RequestId,
+		"error", ots_service_error.Message)...)
+}
+
+// log the final failure of an API call, and return the error as is
+func (o *OTSClient) _log_failure(ctx context.Context, api_name string, retry_times int, start_time time.Time, ots_service_error *OTSServiceError) *OTSServiceError {
+	logger, base := o._get_logger()
+	_log(ctx, logger, log_level_error, "OTS request failed", _log_fields(base,
+		"api", api_name,
+		"retry_times", retry_times,
+		"latency", time.Since(start_time),
+		"http_status", ots_service_error.HttpStatus,
+		"error_code", ots_service_error.Code,
+		"request_id", ots_service_error.RequestId,
+		"error", ots_service_error.Message)...)
+
+	return ots_service_error
+}
+
+func (o *OTSClient) _log_success(ctx context.Context, api_name string, retry_times int, start_time time.Time, headers DictString, resp []reflect.Value) {
+	logger, base := o._get_logger()
+	_log(ctx, logger, log_level_info, "OTS request", _log_fields(base,
+		"api", api_name,
+		"retry_times", retry_times,
+		"latency", time.Since(start_time),
+		"request_id", headers.Get("x-ots-requestid"))...)
+	if len(resp) > 0 {
+		// formatted by the logger only if debug level is enabled
+		_log(ctx, logger, log_level_debug, "OTS response", _log_fields(base, "api", api_name, "response", resp[0].Interface())...)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build go1.21
+// +build go1.21
+
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// log/slog adapter of the logger for ots2
+package goots
+
+import (
+	"context"
+	"log/slog"
+)
+
+// 将log/slog 的Logger 适配为OTSLogger（需要Go 1.21 及以上），同时实现了OTSContextLogger
+type OTSSlogLogger struct {
+	Logger *slog.Logger
+}
+
+// 说明：使用log/slog 的Logger 创建OTSLogger，logger 为nil 时使用slog.Default()。
+//
+// 		示例：
+//
+// 		handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+// 		ots_client.Logger = NewSlogLogger(slog.New(handler))
+//
+func NewSlogLogger(logger *slog.Logger) *OTSSlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &OTSSlogLogger{Logger: logger}
+}
+
+func (o *OTSSlogLogger) Debug(msg string, fields ...interface{}) {
+	o.DebugContext(context.Background(), msg, fields...)
+}
+
+func (o *OTSSlogLogger) Info(msg string, fields ...interface{}) {
+	o.InfoContext(context.Background(), msg, fields...)
+}
+
+func (o *OTSSlogLogger) Warn(msg string, fields ...interface{}) {
+	o.WarnContext(context.Background(), msg, fields...)
+}
+
+func (o *OTSSlogLogger) Error(msg string, fields ...interface{}) {
+	o.ErrorContext(context.Background(), msg, fields...)
+}
+
+func (o *OTSSlogLogger) DebugContext(ctx context.Context, msg string, fields ...interface{}) {
+	o.Logger.Log(ctx, slog.LevelDebug, msg, fields...)
+}
+
+func (o *OTSSlogLogger) InfoContext(ctx context.Context, msg string, fields ...interface{}) {
+	o.Logger.Log(ctx, slog.LevelInfo, msg, fields...)
+}
+
+func (o *OTSSlogLogger) WarnContext(ctx context.Context, msg string, fields ...interface{}) {
+	o.Logger.Log(ctx, slog.LevelWarn, msg, fields...)
+}
+
+func (o *OTSSlogLogger) ErrorContext(ctx context.Context, msg string, fields ...interface{}) {
+	o.Logger.Log(ctx, slog.LevelError, msg, fields...)
+}
@@ -0,0 +1,52 @@
+//go:build go1.21
+// +build go1.21
+
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test log/slog adapter for ots2
+package goots
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+// *slog.Logger can be used as OTSLogger directly
+var _ OTSLogger = (*slog.Logger)(nil)
+var _ OTSContextLogger = (*slog.Logger)(nil)
+
+// records the value of ctx_key in the context of slog records
+type recording_slog_handler struct {
+	values []interface{}
+}
+
+func (h *recording_slog_handler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recording_slog_handler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h *recording_slog_handler) WithGroup(string) slog.Handler           { return h }
+func (h *recording_slog_handler) Handle(ctx context.Context, record slog.Record) error {
+	h.values = append(h.values, ctx.Value(ctx_key{}))
+	return nil
+}
+
+func Test_slog_logger(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	handler := &recording_slog_handler{}
+	client.Logger = NewSlogLogger(slog.New(handler))
+
+	ctx := context.WithValue(context.Background(), ctx_key{}, "trace-1")
+	if _, ots_err := client.ListTableWithContext(ctx); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if len(handler.values) == 0 {
+		t.Fatal("the logs should be written to slog")
+	}
+	for _, value := range handler.values {
+		if value != "trace-1" {
+			t.Fatalf("the context should be passed to slog: %v", handler.values)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test logger for ots2
+package goots
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GiterLab/goots/protobuf/coder"
+)
+
+type log_record struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+type recording_logger struct {
+	lock    sync.Mutex
+	records []log_record
+}
+
+func (l *recording_logger) log(level, msg string, fields []interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	record := log_record{level: level, msg: msg, fields: map[string]interface{}{}}
+	for i := 0; i+1 < len(fields); i += 2 {
+		record.fields[fields[i].(string)] = fields[i+1]
+	}
+	l.records = append(l.records, record)
+}
+
+func (l *recording_logger) Debug(msg string, fields ...interface{}) { l.log("DEBUG", msg, fields) }
+func (l *recording_logger) Info(msg string, fields ...interface{})  { l.log("INFO", msg, fields) }
+func (l *recording_logger) Warn(msg string, fields ...interface{})  { l.log("WARN", msg, fields) }
+func (l *recording_logger) Error(msg string, fields ...interface{}) { l.log("ERROR", msg, fields) }
+
+// records the value of ctx_key in the context of logs
+type recording_context_logger struct {
+	recording_logger
+}
+
+type ctx_key struct{}
+
+func (l *recording_context_logger) with_context(ctx context.Context, level, msg string, fields []interface{}) {
+	l.log(level, msg, append(fields, "ctx_value", ctx.Value(ctx_key{})))
+}
+
+func (l *recording_context_logger) DebugContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.with_context(ctx, "DEBUG", msg, fields)
+}
+func (l *recording_context_logger) InfoContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.with_context(ctx, "INFO", msg, fields)
+}
+func (l *recording_context_logger) WarnContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.with_context(ctx, "WARN", msg, fields)
+}
+func (l *recording_context_logger) ErrorContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.with_context(ctx, "ERROR", msg, fields)
+}
+
+func (l *recording_logger) find(level string) []log_record {
+	r := []log_record{}
+	for _, record := range l.records {
+		if record.level == level {
+			r = append(r, record)
+		}
+	}
+	return r
+}
+
+func Test_logger(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	logger := &recording_logger{}
+	client.Logger = logger
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+
+	calls := 0
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		calls += 1
+		if calls == 1 {
+			return 503, "OTSServerBusy", "Server is busy."
+		}
+		return 0, "", ""
+	}
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	warns := logger.find("WARN")
+	if len(warns) != 1 || warns[0].fields["api"] != "ListTable" || warns[0].fields["error_code"] != "OTSServerBusy" ||
+		warns[0].fields["request_id"] == "" || warns[0].fields["logger"] != DEFAULT_LOGGER_NAME {
+		t.Errorf("unexpected retry logs: %v", warns)
+	}
+	infos := logger.find("INFO")
+	if len(infos) != 1 || infos[0].fields["retry_times"] != 1 || infos[0].fields["latency"] == nil || infos[0].fields["request_id"] == "" {
+		t.Errorf("unexpected call logs: %v", infos)
+	}
+	if len(logger.find("DEBUG")) == 0 {
+		t.Error("request and response should be logged at debug level")
+	}
+
+	if _, ots_err := client.DescribeTable("notExistTable"); ots_err == nil {
+		t.Fatal("describe a missing table should fail")
+	}
+	errors := logger.find("ERROR")
+	if len(errors) != 1 || errors[0].fields["api"] != "DescribeTable" || errors[0].fields["error_code"] != "OTSObjectNotExist" {
+		t.Errorf("unexpected failure logs: %v", errors)
+	}
+}
+
+func Test_logger_protobuf_message(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	logger := &recording_logger{}
+	client.Logger = logger
+
+	coder.DebugEncoderEnable, coder.DebugDecoderEnable = true, true
+	defer func() { coder.DebugEncoderEnable, coder.DebugDecoderEnable = false, false }()
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// the messages are written to the logger of client
+	types := map[string]interface{}{}
+	for _, record := range logger.find("DEBUG") {
+		if record.msg == "Request Debug" || record.msg == "Response Debug" {
+			if record.fields["api"] != "ListTable" || record.fields["logger"] != DEFAULT_LOGGER_NAME || record.fields["protobuf"] == nil {
+				t.Errorf("unexpected message log: %v", record)
+			}
+			types[record.msg] = record.fields["type"]
+		}
+	}
+	if types["Request Debug"] != "ListTableRequest" || types["Response Debug"] != "ListTableResponse" {
+		t.Errorf("unexpected message logs: %v", types)
+	}
+}
+
+func Test_logger_context(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	logger := &recording_context_logger{}
+	client.Logger = logger
+
+	coder.DebugEncoderEnable = true
+	defer func() { coder.DebugEncoderEnable = false }()
+	ctx := context.WithValue(context.Background(), ctx_key{}, "trace-1")
+	if _, ots_err := client.ListTableWithContext(ctx); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err := client.DescribeTableWithContext(ctx, "notExistTable"); ots_err == nil {
+		t.Fatal("describe a missing table should fail")
+	}
+
+	// the context of API call is passed to the logs
+	levels := map[string]bool{}
+	for _, record := range logger.records {
+		if record.fields["ctx_value"] != "trace-1" {
+			t.Errorf("the context should be passed to the log: %v", record)
+		}
+		levels[record.level] = true
+	}
+	if !levels["DEBUG"] || !levels["INFO"] || !levels["ERROR"] {
+		t.Errorf("unexpected logs: %v", logger.records)
+	}
+}
+
+func Test_text_logger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &ots_text_logger{w: &buf, level: log_level_info}
+	logger.Debug("OTS response", "api", "GetRow")
+	logger.Info("OTS request", "api", "GetRow", "error", "Server is busy.", "request_id", "")
+	logger.Warn("odd fields", "api")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("the logs below Info should be discarded: %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], ` level=INFO msg="OTS request" api=GetRow error="Server is busy." request_id=""`) {
+		t.Errorf("unexpected log: %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], ` level=WARN msg="odd fields" !BADKEY=api`) {
+		t.Errorf("unexpected log: %s", lines[1])
+	}
+}
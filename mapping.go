@@ -0,0 +1,233 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// struct based row operations for ots2
+package goots
+
+import (
+	"context"
+	"reflect"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+// 说明：将结构体写入一行，主键和属性列由结构体的``ots``标签决定，见``otstype.MarshalRow``。
+//
+// 		``condition``与PutRow 相同。
+// 		``row``为结构体或结构体指针。
+//
+// 		返回：与PutRow 相同。
+//
+// 		示例：
+//
+// 		type User struct {
+// 			Gid  int64  `ots:"gid,pk"`
+// 			Uid  int64  `ots:"uid,pk"`
+// 			Name string `ots:"name"`
+// 			Age  *int   `ots:"age"`
+// 		}
+// 		put_row_response, ots_err := ots_client.PutRowStruct("myTable", OTSCondition_IGNORE, &User{Gid: 1, Uid: 101, Name: "张三"})
+//
+func (o *OTSClient) PutRowStruct(table_name string, condition interface{}, row interface{}) (put_row_response *OTSPutRowResponse, err *OTSError) {
+	return o.PutRowStructWithContext(context.Background(), table_name, condition, row)
+}
+
+// 说明：同PutRowStruct，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) PutRowStructWithContext(ctx context.Context, table_name string, condition interface{}, row interface{}) (put_row_response *OTSPutRowResponse, err *OTSError) {
+	primary_key, attribute_columns, marshal_err := MarshalRow(row)
+	if marshal_err != nil {
+		return nil, new(OTSError).SetClientMessage("[PutRowStruct] %s", marshal_err)
+	}
+
+	return o.PutRowWithContext(ctx, table_name, condition, primary_key, &attribute_columns)
+}
+
+// 说明：读取一行并填充到结构体中，只读取结构体映射的列。
+//
+// 		``row``为结构体指针，读取前需要设置好主键字段。
+// 		``filter``与GetRow 相同。
+//
+// 		返回：该行是否存在，行不存在或被filter 过滤时为false，此时row 不会被修改。
+// 		      与GetRow 相同的结果。
+// 		      错误信息。
+//
+// 		示例：
+//
+// 		user := &User{Gid: 1, Uid: 101}
+// 		found, _, ots_err := ots_client.GetRowStruct("myTable", user)
+//
+func (o *OTSClient) GetRowStruct(table_name string, row interface{}, filter ...OTSColumnCondition) (found bool, get_row_response *OTSGetRowResponse, err *OTSError) {
+	return o.GetRowStructWithContext(context.Background(), table_name, row, filter...)
+}
+
+// 说明：同GetRowStruct，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) GetRowStructWithContext(ctx context.Context, table_name string, row interface{}, filter ...OTSColumnCondition) (found bool, get_row_response *OTSGetRowResponse, err *OTSError) {
+	primary_key, marshal_err := MarshalPrimaryKey(row)
+	if marshal_err != nil {
+		return false, nil, new(OTSError).SetClientMessage("[GetRowStruct] %s", marshal_err)
+	}
+	columns_to_get, marshal_err := ColumnsOfStruct(row)
+	if marshal_err != nil {
+		return false, nil, new(OTSError).SetClientMessage("[GetRowStruct] %s", marshal_err)
+	}
+
+	get_row_response, err = o.GetRowWithContext(ctx, table_name, primary_key, &columns_to_get, filter...)
+	if err != nil {
+		return false, nil, err
+	}
+	if !_row_found(get_row_response.Row) {
+		return false, get_row_response, nil
+	}
+	if unmarshal_err := UnmarshalRow(get_row_response.Row, row); unmarshal_err != nil {
+		return false, get_row_response, new(OTSError).SetClientMessage("[GetRowStruct] %s", unmarshal_err)
+	}
+
+	return true, get_row_response, nil
+}
+
+// 说明：范围读取，读取的行填充为结构体后追加到``rows``中，只读取结构体映射的列。
+//
+// 		``rows``为结构体切片的指针，如``*[]User``或``*[]*User``。
+// 		其它参数与GetRange 相同。
+//
+// 		返回：与GetRange 相同的结果，需要根据``NextStartPrimaryKey``判断是否还有数据。
+// 		      错误信息。
+//
+// 		示例：
+//
+// 		users := []*User{}
+// 		get_range_response, ots_err := ots_client.GetRangeStruct("myTable", OTSDirection_FORWARD,
+// 			inclusive_start_primary_key, exclusive_end_primary_key, 100, &users)
+//
+func (o *OTSClient) GetRangeStruct(table_name string, direction string,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
+	limit int32,
+	rows interface{},
+	filter ...OTSColumnCondition) (get_range_response *OTSGetRangeResponse, err *OTSError) {
+	return o.GetRangeStructWithContext(context.Background(), table_name, direction, inclusive_start_primary_key, exclusive_end_primary_key, limit, rows, filter...)
+}
+
+// 说明：同GetRangeStruct，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) GetRangeStructWithContext(ctx context.Context, table_name string, direction string,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
+	limit int32,
+	rows interface{},
+	filter ...OTSColumnCondition) (get_range_response *OTSGetRangeResponse, err *OTSError) {
+	slice := reflect.ValueOf(rows)
+	if slice.Kind() != reflect.Ptr || slice.IsNil() || slice.Elem().Kind() != reflect.Slice {
+		return nil, new(OTSError).SetClientMessage("[GetRangeStruct] rows should be a pointer to slice, not %T", rows)
+	}
+	slice = slice.Elem()
+	elem_type := slice.Type().Elem()
+	columns_to_get, marshal_err := ColumnsOfStruct(elem_type)
+	if marshal_err != nil {
+		return nil, new(OTSError).SetClientMessage("[GetRangeStruct] %s", marshal_err)
+	}
+
+	get_range_response, err = o.GetRangeWithContext(ctx, table_name, direction, inclusive_start_primary_key, exclusive_end_primary_key, &columns_to_get, limit, filter...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range get_range_response.Rows {
+		ptr := reflect.New(elem_type)
+		if elem_type.Kind() == reflect.Ptr {
+			ptr.Elem().Set(reflect.New(elem_type.Elem()))
+			ptr = ptr.Elem()
+		}
+		if unmarshal_err := UnmarshalRow(row, ptr.Interface()); unmarshal_err != nil {
+			return get_range_response, new(OTSError).SetClientMessage("[GetRangeStruct] %s", unmarshal_err)
+		}
+		if elem_type.Kind() == reflect.Ptr {
+			slice.Set(reflect.Append(slice, ptr))
+		} else {
+			slice.Set(reflect.Append(slice, ptr.Elem()))
+		}
+	}
+
+	return get_range_response, nil
+}
+
+// 说明：批量读取一个表中的多行，读取的结果直接填充到``rows``的各个元素中，只读取结构体映射的列。
+//
+// 		``rows``为结构体切片或结构体指针切片，如``[]User``或``[]*User``，读取前需要设置好各元素的主键字段。
+// 		``filter``与BatchGetRow 相同。
+//
+// 		返回：与BatchGetRow 相同的结果，结果中行的顺序与rows 一一对应；读取失败、不存在或被filter 过滤的行
+// 		      对应的元素不会被修改。
+// 		      错误信息。
+//
+// 		示例：
+//
+// 		users := []*User{{Gid: 1, Uid: 101}, {Gid: 2, Uid: 202}}
+// 		response_rows_list, ots_err := ots_client.BatchGetRowStruct("myTable", users)
+//
+func (o *OTSClient) BatchGetRowStruct(table_name string, rows interface{}, filter ...OTSColumnCondition) (response_rows_list *OTSBatchGetRowResponse, err *OTSError) {
+	return o.BatchGetRowStructWithContext(context.Background(), table_name, rows, filter...)
+}
+
+// 说明：同BatchGetRowStruct，ctx用于控制本次请求（包括重试等待）的超时和取消。
+func (o *OTSClient) BatchGetRowStructWithContext(ctx context.Context, table_name string, rows interface{}, filter ...OTSColumnCondition) (response_rows_list *OTSBatchGetRowResponse, err *OTSError) {
+	slice := reflect.ValueOf(rows)
+	if slice.Kind() != reflect.Slice {
+		return nil, new(OTSError).SetClientMessage("[BatchGetRowStruct] rows should be a slice, not %T", rows)
+	}
+	columns_to_get, marshal_err := ColumnsOfStruct(slice.Type().Elem())
+	if marshal_err != nil {
+		return nil, new(OTSError).SetClientMessage("[BatchGetRowStruct] %s", marshal_err)
+	}
+
+	request_item := OTSTableInBatchGetRowRequestItem{
+		TableName:    table_name,
+		ColumnsToGet: columns_to_get,
+	}
+	if len(filter) > 0 {
+		request_item.Filter = filter[0]
+	}
+	elems := make([]interface{}, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		elem := slice.Index(i)
+		if elem.Kind() != reflect.Ptr {
+			elem = elem.Addr()
+		}
+		primary_key, marshal_err := MarshalPrimaryKey(elem.Interface())
+		if marshal_err != nil {
+			return nil, new(OTSError).SetClientMessage("[BatchGetRowStruct] row %d: %s", i, marshal_err)
+		}
+		if request_item.SchemaOfPrimaryKey == nil {
+			// the rows are sent in the order of fields
+			for _, column := range *primary_key {
+				column_value, _ := NewColumnValue(column.V)
+				request_item.SchemaOfPrimaryKey = append(request_item.SchemaOfPrimaryKey, TupleString{K: column.K, V: column_value.Type()})
+			}
+		}
+		request_item.Rows = append(request_item.Rows, primary_key.ToPrimaryKey())
+		elems[i] = elem.Interface()
+	}
+
+	response_rows_list, err = o.BatchGetRowWithContext(ctx, &OTSBatchGetRowRequest{request_item})
+	if err != nil {
+		return nil, err
+	}
+	if len(response_rows_list.Tables) != 1 {
+		return response_rows_list, nil
+	}
+
+	for i, row_item := range response_rows_list.Tables[0].Rows {
+		if i >= len(elems) || row_item == nil || !row_item.IsOk || !_row_found(row_item.Row) {
+			continue
+		}
+		if unmarshal_err := UnmarshalRow(row_item.Row, elems[i]); unmarshal_err != nil {
+			return response_rows_list, new(OTSError).SetClientMessage("[BatchGetRowStruct] row %d: %s", i, unmarshal_err)
+		}
+	}
+
+	return response_rows_list, nil
+}
+
+func _row_found(row *OTSRow) bool {
+	return row != nil && (len(row.PrimaryKeyColumns) > 0 || len(row.AttributeColumns) > 0)
+}
@@ -0,0 +1,146 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test struct based row operations for ots2
+package goots
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+type mapping_user struct {
+	Gid     int64   `ots:"gid,pk"`
+	Uid     int     `ots:"uid,pk"`
+	Name    string  `ots:"name"`
+	Age     *int32  `ots:"age"`
+	Score   float64 `ots:"score"`
+	Vip     bool
+	Avatar  []byte `ots:"avatar"`
+	Comment string `ots:"-"`
+	secret  string
+}
+
+func Test_marshal_row(t *testing.T) {
+	age := int32(20)
+	user := &mapping_user{Gid: 1, Uid: 101, Name: "张三", Age: &age, Score: 9.5, Avatar: []byte{1, 2}, Comment: "x"}
+
+	primary_key, attribute_columns, err := MarshalRow(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// in the order of fields
+	if len(*primary_key) != 2 || (*primary_key)[0].K != "gid" || (*primary_key)[0].V != int64(1) || (*primary_key)[1].K != "uid" || (*primary_key)[1].V != int64(101) {
+		t.Errorf("unexpected primary key: %v", primary_key)
+	}
+	if len(attribute_columns) != 5 || attribute_columns["age"] != int64(20) || attribute_columns["Vip"] != false {
+		t.Errorf("unexpected attribute columns: %v", attribute_columns)
+	}
+
+	user.Age = nil
+	if _, attribute_columns, _ = MarshalRow(user); attribute_columns.Get("age") != nil {
+		t.Errorf("nil pointer should not be written, but %v", attribute_columns)
+	}
+
+	if _, err = MarshalPrimaryKey(struct{ Name string }{"x"}); err == nil {
+		t.Error("struct without primary key should fail")
+	}
+
+	columns_to_get, err := ColumnsOfStruct(mapping_user{})
+	if err != nil || len(columns_to_get) != 7 {
+		t.Errorf("unexpected columns: %v, %v", columns_to_get, err)
+	}
+
+	row := &OTSRow{
+		PrimaryKeyColumns: OTSPrimaryKey{"gid": int64(2), "uid": int64(202)},
+		AttributeColumns:  OTSAttribute{"name": "李四", "age": int64(30), "avatar": []byte{3}},
+	}
+	if err = UnmarshalRow(row, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Gid != 2 || user.Uid != 202 || user.Name != "李四" || user.Age == nil || *user.Age != 30 ||
+		user.Score != 0 || !bytes.Equal(user.Avatar, []byte{3}) || user.Comment != "x" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	row.AttributeColumns["name"] = int64(1)
+	if err = UnmarshalRow(row, user); err == nil {
+		t.Error("unmarshal int64 into string should fail")
+	}
+}
+
+func Test_emulator_struct_row(t *testing.T) {
+	client, _, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	for i := 0; i < 10; i++ {
+		age := int32(i + 10)
+		if _, ots_err := client.PutRowStruct("myTable", OTSCondition_IGNORE, &mapping_user{Gid: 1, Uid: i, Name: "user", Age: &age}); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+
+	user := &mapping_user{Gid: 1, Uid: 3}
+	found, _, ots_err := client.GetRowStruct("myTable", user)
+	if ots_err != nil || !found || user.Name != "user" || *user.Age != 13 {
+		t.Errorf("unexpected user: %+v, %v", user, ots_err)
+	}
+	user = &mapping_user{Gid: 2, Uid: 3}
+	if found, _, ots_err = client.GetRowStruct("myTable", user); ots_err != nil || found {
+		t.Errorf("row should not be found, %v", ots_err)
+	}
+
+	users := []mapping_user{}
+	start := &OTSPrimaryKey{"gid": 1, "uid": OTSColumnType_INF_MIN}
+	end := &OTSPrimaryKey{"gid": 1, "uid": OTSColumnType_INF_MAX}
+	response, ots_err := client.GetRangeStruct("myTable", OTSDirection_FORWARD, start, end, 4, &users)
+	if ots_err != nil || len(users) != 4 || users[3].Uid != 3 || response.NextStartPrimaryKey == nil {
+		t.Errorf("unexpected users: %+v, %v", users, ots_err)
+	}
+
+	batch_users := []*mapping_user{{Gid: 1, Uid: 5}, {Gid: 9, Uid: 9}, {Gid: 1, Uid: 7}}
+	if _, ots_err = client.BatchGetRowStruct("myTable", batch_users); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if *batch_users[0].Age != 15 || batch_users[1].Age != nil || *batch_users[2].Age != 17 {
+		t.Errorf("unexpected users: %+v %+v %+v", batch_users[0], batch_users[1], batch_users[2])
+	}
+}
+
+func Test_emulator_struct_schema_order(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	ots_emulator.StrictPrimaryKeyOrder = true
+
+	type order_user struct {
+		Uid  int64  `ots:"uid,pk"`
+		Gid  int64  `ots:"gid,pk"`
+		Name string `ots:"name"`
+	}
+	table_meta := &OTSTableMeta{
+		TableName: "orderTable",
+		SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{
+			{K: "uid", V: "INTEGER"},
+			{K: "gid", V: "INTEGER"},
+		},
+	}
+	if ots_err := client.CreateTable(table_meta, &OTSReservedThroughput{CapacityUnit: OTSCapacityUnit{Read: 100, Write: 100}}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// the primary key is sent in the order of fields
+	if _, ots_err := client.PutRowStruct("orderTable", OTSCondition_IGNORE, &order_user{Uid: 1, Gid: 2, Name: "张三"}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	user := &order_user{Uid: 1, Gid: 2}
+	if found, _, ots_err := client.GetRowStruct("orderTable", user); ots_err != nil || !found || user.Name != "张三" {
+		t.Errorf("unexpected user: %+v, %v", user, ots_err)
+	}
+	batch_users := []*order_user{{Uid: 1, Gid: 2}}
+	if _, ots_err := client.BatchGetRowStruct("orderTable", batch_users); ots_err != nil || batch_users[0].Name != "张三" {
+		t.Errorf("unexpected user: %+v, %v", batch_users[0], ots_err)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Metrics for ots2
+package goots
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+// API 调用耗时直方图的默认分桶，单位为秒
+var DEFAULT_METRICS_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+const DEFAULT_METRICS_NAMESPACE = "ots"
+
+// 指标收集器，通过拦截器统计每次API 调用，并以Prometheus 文本格式输出
+//
+// 		收集的指标（前缀为Namespace）：
+// 		ots_request_duration_seconds{api}           API 调用耗时的直方图（包括重试）
+// 		ots_requests_total{api}                     API 调用次数
+// 		ots_request_errors_total{api,code}          API 调用最终失败的次数，按错误号统计
+// 		ots_request_retries_total{api}              重试次数
+// 		ots_throttled_total{api,code}               流控错误次数，包括每次重试和BatchGetRow/BatchWriteRow 中的行错误
+// 		ots_read_capacity_units_total{table}        消耗的读CapacityUnit
+// 		ots_write_capacity_units_total{table}       消耗的写CapacityUnit
+//
+// 		示例：
+//
+// 		metrics := NewMetrics()
+// 		ots_client.Use(metrics.Interceptor())
+// 		http.Handle("/metrics", metrics)
+//
+type OTSMetrics struct {
+	// 指标名的前缀，默认为DEFAULT_METRICS_NAMESPACE
+	Namespace string
+	// 耗时直方图的分桶，需要递增，第一次调用Interceptor 时复制，之后的修改不生效，默认为DEFAULT_METRICS_BUCKETS
+	Buckets []float64
+
+	lock      sync.Mutex
+	buckets   []float64 // the copy of Buckets used by the histograms
+	latency   map[string]*metrics_histogram
+	errors    map[metrics_labels]uint64
+	retries   map[string]uint64
+	throttled map[metrics_labels]uint64
+	read_cu   map[string]int64
+	write_cu  map[string]int64
+}
+
+type metrics_labels struct {
+	api  string
+	code string
+}
+
+type metrics_histogram struct {
+	buckets []uint64 // not cumulative
+	sum     float64
+	count   uint64
+}
+
+func NewMetrics() *OTSMetrics {
+	return &OTSMetrics{
+		Namespace: DEFAULT_METRICS_NAMESPACE,
+		Buckets:   append([]float64(nil), DEFAULT_METRICS_BUCKETS...),
+		latency:   map[string]*metrics_histogram{},
+		errors:    map[metrics_labels]uint64{},
+		retries:   map[string]uint64{},
+		throttled: map[metrics_labels]uint64{},
+		read_cu:   map[string]int64{},
+		write_cu:  map[string]int64{},
+	}
+}
+
+// 说明：返回统计每次API 调用的拦截器，通过OTSClient.Use 注册，可以注册到多个OTSClient。
+func (o *OTSMetrics) Interceptor() OTSInterceptor {
+	o.lock.Lock()
+	if o.buckets == nil {
+		o.buckets = append([]float64{}, o._get_buckets()...)
+	}
+	o.lock.Unlock()
+
+	return func(ctx context.Context, call *OTSCall, invoker OTSInvoker) (interface{}, *OTSServiceError) {
+		start_time := time.Now()
+		response, ots_service_error := invoker(ctx, call)
+		o._observe(call, time.Since(start_time), response, ots_service_error)
+
+		return response, ots_service_error
+	}
+}
+
+func (o *OTSMetrics) _observe(call *OTSCall, latency time.Duration, response interface{}, ots_service_error *OTSServiceError) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	histogram, ok := o.latency[call.Api]
+	if !ok {
+		histogram = &metrics_histogram{buckets: make([]uint64, len(o.buckets))}
+		o.latency[call.Api] = histogram
+	}
+	seconds := latency.Seconds()
+	for i, le := range o.buckets {
+		if seconds <= le {
+			histogram.buckets[i] += 1
+			break
+		}
+	}
+	histogram.sum += seconds
+	histogram.count += 1
+
+	if ots_service_error != nil {
+		o.errors[metrics_labels{call.Api, _metrics_error_code(ots_service_error)}] += 1
+	}
+	if len(call.Attempts) > 1 {
+		o.retries[call.Api] += uint64(len(call.Attempts) - 1)
+	}
+	for _, attempt := range call.Attempts {
+		if is_server_throttling_exception(attempt.Error) {
+			o.throttled[metrics_labels{call.Api, attempt.Error.Code}] += 1
+		}
+	}
+	if response != nil {
+		o._observe_capacity_unit(call, response)
+	}
+}
+
+// the consumed capacity unit of each table, and the throttled rows of batch operations
+func (o *OTSMetrics) _observe_capacity_unit(call *OTSCall, response interface{}) {
+	_foreach_consumed(call, response, func(table_name string, read, write int32) {
+		o.read_cu[table_name] += int64(read)
+		o.write_cu[table_name] += int64(write)
+	})
+
+	switch r := response.(type) {
+	case *OTSBatchGetRowResponse:
+		for _, table := range r.GetTables() {
+			for _, row := range table.GetRows() {
+				if !row.IsOk && row.ErrorCode != "" {
+					o._observe_row_error(call.Api, row.ErrorCode, row.ErrorMessage)
+				}
+			}
+		}
+	case *OTSBatchWriteRowResponse:
+		for _, table := range r.GetTables() {
+			for _, rows := range [][]*OTSRowInBatchWriteRowResponseItem{table.GetPutRows(), table.GetUpdateRows(), table.GetDeleteRows()} {
+				for _, row := range rows {
+					if !row.IsOk && row.ErrorCode != "" {
+						o._observe_row_error(call.Api, row.ErrorCode, row.ErrorMessage)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (o *OTSMetrics) _observe_row_error(api_name, error_code, error_message string) {
+	if is_server_throttling_exception(&OTSServiceError{Code: error_code, Message: error_message}) {
+		o.throttled[metrics_labels{api_name, error_code}] += 1
+	}
+}
+
+func (o *OTSMetrics) _get_buckets() []float64 {
+	if len(o.Buckets) == 0 {
+		return DEFAULT_METRICS_BUCKETS
+	}
+
+	return o.Buckets
+}
+
+func _metrics_error_code(ots_service_error *OTSServiceError) string {
+	if ots_service_error.Code != "" {
+		return ots_service_error.Code
+	}
+	if ots_service_error.HttpStatus != 0 {
+		return strconv.Itoa(ots_service_error.HttpStatus)
+	}
+
+	return "ClientError"
+}
+
+// 以Prometheus 文本格式输出所有指标
+func (o *OTSMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	o.WriteTo(w)
+}
+
+// 说明：以Prometheus 文本格式输出所有指标到w。
+func (o *OTSMetrics) WriteTo(w io.Writer) (int64, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	namespace := o.Namespace
+	if namespace == "" {
+		namespace = DEFAULT_METRICS_NAMESPACE
+	}
+	mw := &metrics_writer{Writer: bufio.NewWriter(w)}
+
+	name := namespace + "_request_duration_seconds"
+	mw.header(name, "histogram", "Latency of OTS API calls in seconds, including retries.")
+	for _, api_name := range _sorted_keys(o.latency) {
+		histogram := o.latency[api_name]
+		cumulative := uint64(0)
+		for i, le := range o.buckets {
+			cumulative += histogram.buckets[i]
+			mw.sample(name+"_bucket", `api="`+_escape_label(api_name)+`",le="`+_format_float(le)+`"`, float64(cumulative))
+		}
+		mw.sample(name+"_bucket", `api="`+_escape_label(api_name)+`",le="+Inf"`, float64(histogram.count))
+		mw.sample(name+"_sum", `api="`+_escape_label(api_name)+`"`, histogram.sum)
+		mw.sample(name+"_count", `api="`+_escape_label(api_name)+`"`, float64(histogram.count))
+	}
+
+	name = namespace + "_requests_total"
+	mw.header(name, "counter", "Number of OTS API calls.")
+	for _, api_name := range _sorted_keys(o.latency) {
+		mw.sample(name, `api="`+_escape_label(api_name)+`"`, float64(o.latency[api_name].count))
+	}
+
+	name = namespace + "_request_errors_total"
+	mw.header(name, "counter", "Number of failed OTS API calls by error code.")
+	mw.labeled(name, o.errors)
+
+	name = namespace + "_request_retries_total"
+	mw.header(name, "counter", "Number of retried OTS requests.")
+	for _, api_name := range _sorted_keys(o.retries) {
+		mw.sample(name, `api="`+_escape_label(api_name)+`"`, float64(o.retries[api_name]))
+	}
+
+	name = namespace + "_throttled_total"
+	mw.header(name, "counter", "Number of throttling errors, including retried requests and rows of batch operations.")
+	mw.labeled(name, o.throttled)
+
+	name = namespace + "_read_capacity_units_total"
+	mw.header(name, "counter", "Consumed read capacity units by table.")
+	for _, table_name := range _sorted_keys(o.read_cu) {
+		mw.sample(name, `table="`+_escape_label(table_name)+`"`, float64(o.read_cu[table_name]))
+	}
+
+	name = namespace + "_write_capacity_units_total"
+	mw.header(name, "counter", "Consumed write capacity units by table.")
+	for _, table_name := range _sorted_keys(o.write_cu) {
+		mw.sample(name, `table="`+_escape_label(table_name)+`"`, float64(o.write_cu[table_name]))
+	}
+
+	if err := mw.Flush(); err != nil {
+		return mw.n, err
+	}
+	return mw.n, mw.err
+}
+
+type metrics_writer struct {
+	*bufio.Writer
+	n   int64
+	err error
+}
+
+func (o *metrics_writer) printf(format string, a ...interface{}) {
+	if o.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(o.Writer, format, a...)
+	o.n += int64(n)
+	o.err = err
+}
+
+func (o *metrics_writer) header(name, metric_type, help string) {
+	o.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, metric_type)
+}
+
+func (o *metrics_writer) sample(name, labels string, value float64) {
+	o.printf("%s{%s} %s\n", name, labels, _format_float(value))
+}
+
+func (o *metrics_writer) labeled(name string, values map[metrics_labels]uint64) {
+	keys := make([]metrics_labels, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].api != keys[j].api {
+			return keys[i].api < keys[j].api
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		o.sample(name, `api="`+_escape_label(k.api)+`",code="`+_escape_label(k.code)+`"`, float64(values[k]))
+	}
+}
+
+// the sorted keys of map[string]xxx
+func _sorted_keys(m interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func _escape_label(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func _format_float(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
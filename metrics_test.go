@@ -0,0 +1,75 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test metrics for ots2
+package goots
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_metrics(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+	metrics := NewMetrics()
+	client.Use(metrics.Interceptor())
+
+	calls := 0
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		calls += 1
+		if api_name == "PutRow" && calls == 1 {
+			return 503, "OTSServerBusy", "Server is busy."
+		}
+		return 0, "", ""
+	}
+	primary_key := &OTSPrimaryKey{"gid": 1, "uid": 101}
+	if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, primary_key, &OTSAttribute{"name": "张三"}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err := client.GetRow("myTable", primary_key, nil); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err := client.DescribeTable("notExistTable"); ots_err == nil {
+		t.Fatal("describe a missing table should fail")
+	}
+	// the buckets are copied by Interceptor, changing Buckets later has no effect
+	metrics.Buckets[0] = 100
+	metrics.Buckets = []float64{1}
+	if DEFAULT_METRICS_BUCKETS[0] != 0.005 {
+		t.Fatalf("DEFAULT_METRICS_BUCKETS should not be changed: %v", DEFAULT_METRICS_BUCKETS)
+	}
+	if _, ots_err := client.GetRow("myTable", primary_key, nil); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+	text := string(body)
+	for _, line := range []string{
+		"# TYPE ots_request_duration_seconds histogram",
+		`ots_request_duration_seconds_bucket{api="PutRow",le="+Inf"} 1`,
+		`ots_request_duration_seconds_bucket{api="GetRow",le="10"} 2`,
+		`ots_request_duration_seconds_count{api="GetRow"} 2`,
+		`ots_requests_total{api="DescribeTable"} 1`,
+		`ots_request_errors_total{api="DescribeTable",code="OTSObjectNotExist"} 1`,
+		`ots_request_retries_total{api="PutRow"} 1`,
+		`ots_throttled_total{api="PutRow",code="OTSServerBusy"} 1`,
+		`ots_read_capacity_units_total{table="myTable"} 2`,
+		`ots_write_capacity_units_total{table="myTable"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("%q not found in metrics:\n%s", line, text)
+		}
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type: %s", recorder.Header().Get("Content-Type"))
+	}
+}
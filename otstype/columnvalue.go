@@ -0,0 +1,258 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// typed column value for ots2
+package otstype
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// INF_MIN 和INF_MAX 的类型名，只用于GetRange
+const (
+	OTSColumnType_INF_MIN_NAME = "INF_MIN"
+	OTSColumnType_INF_MAX_NAME = "INF_MAX"
+)
+
+// 有类型的列值，通过NewIntegerValue 等函数创建，可以作为主键和属性列的值使用
+//
+// 		map 中的Go 类型值在发送前都会通过NewColumnValue 转换为OTSColumnValue，
+// 		无法转换（如超出int64 范围的uint64）时返回错误，而不会被截断。
+//
+// 		示例：
+//
+// 		primary_key := &OTSPrimaryKey{
+// 			"gid": NewIntegerValue(1),
+// 			"uid": NewIntegerValue(101),
+// 		}
+//
+type OTSColumnValue struct {
+	column_type string
+	v_int       int64
+	v_string    string
+	v_bool      bool
+	v_double    float64
+	v_binary    []byte
+}
+
+func NewIntegerValue(v int64) OTSColumnValue {
+	return OTSColumnValue{column_type: OTSColumnType_INTEGER, v_int: v}
+}
+
+func NewStringValue(v string) OTSColumnValue {
+	return OTSColumnValue{column_type: OTSColumnType_STRING, v_string: v}
+}
+
+func NewBooleanValue(v bool) OTSColumnValue {
+	return OTSColumnValue{column_type: OTSColumnType_BOOLEAN, v_bool: v}
+}
+
+func NewDoubleValue(v float64) OTSColumnValue {
+	return OTSColumnValue{column_type: OTSColumnType_DOUBLE, v_double: v}
+}
+
+// 值为nil 时为空的BINARY
+func NewBinaryValue(v []byte) OTSColumnValue {
+	if v == nil {
+		v = []byte{}
+	}
+	return OTSColumnValue{column_type: OTSColumnType_BINARY, v_binary: v}
+}
+
+// 无限小，只用于GetRange
+func NewInfMinValue() OTSColumnValue {
+	return OTSColumnValue{column_type: OTSColumnType_INF_MIN_NAME}
+}
+
+// 无限大，只用于GetRange
+func NewInfMaxValue() OTSColumnValue {
+	return OTSColumnValue{column_type: OTSColumnType_INF_MAX_NAME}
+}
+
+// 说明：将Go 类型的值转换为OTSColumnValue。
+//
+// 		支持string、bool、int、int8~int64、uint、uint8~uint64、float32、float64、[]byte、
+// 		OTS_INF_MIN、OTS_INF_MAX，以及OTSColumnValue 和 *OTSColumnValue。
+//
+// 		返回：列值。
+// 		      错误信息，类型不支持或无符号整数超出int64 范围时返回错误。
+func NewColumnValue(value interface{}) (OTSColumnValue, error) {
+	switch v := value.(type) {
+	case OTSColumnValue:
+		if v.column_type == "" {
+			return OTSColumnValue{}, errors.New("invalid column value without type")
+		}
+		return v, nil
+	case *OTSColumnValue:
+		if v == nil {
+			return OTSColumnValue{}, errors.New("column value is nil")
+		}
+		return NewColumnValue(*v)
+	case string:
+		return NewStringValue(v), nil
+	case bool:
+		return NewBooleanValue(v), nil
+	case int:
+		return NewIntegerValue(int64(v)), nil
+	case int8:
+		return NewIntegerValue(int64(v)), nil
+	case int16:
+		return NewIntegerValue(int64(v)), nil
+	case int32:
+		return NewIntegerValue(int64(v)), nil
+	case int64:
+		return NewIntegerValue(v), nil
+	case uint:
+		return _new_unsigned_value(uint64(v))
+	case uint8:
+		return NewIntegerValue(int64(v)), nil
+	case uint16:
+		return NewIntegerValue(int64(v)), nil
+	case uint32:
+		return NewIntegerValue(int64(v)), nil
+	case uint64:
+		return _new_unsigned_value(v)
+	case float32:
+		return NewDoubleValue(float64(v)), nil
+	case float64:
+		return NewDoubleValue(v), nil
+	case []byte:
+		return NewBinaryValue(v), nil
+	case OTS_INF_MIN:
+		return NewInfMinValue(), nil
+	case OTS_INF_MAX:
+		return NewInfMaxValue(), nil
+	}
+
+	return OTSColumnValue{}, errors.New(fmt.Sprintf("expect string, bool, (u)int, (u)int8, (u)int16, (u)int32, (u)int64, float32, float64, []byte or OTSColumnValue for column value, not %v", reflect.TypeOf(value)))
+}
+
+func _new_unsigned_value(v uint64) (OTSColumnValue, error) {
+	if v > math.MaxInt64 {
+		return OTSColumnValue{}, errors.New(fmt.Sprintf("%d overflows int64", v))
+	}
+	return NewIntegerValue(int64(v)), nil
+}
+
+// 列值的类型，OTSColumnType_INTEGER 等，或OTSColumnType_INF_MIN_NAME、OTSColumnType_INF_MAX_NAME
+func (o OTSColumnValue) Type() string {
+	return o.column_type
+}
+
+func (o OTSColumnValue) IsInfMin() bool {
+	return o.column_type == OTSColumnType_INF_MIN_NAME
+}
+
+func (o OTSColumnValue) IsInfMax() bool {
+	return o.column_type == OTSColumnType_INF_MAX_NAME
+}
+
+func (o OTSColumnValue) AsInteger() (int64, error) {
+	if err := o._check_type(OTSColumnType_INTEGER); err != nil {
+		return 0, err
+	}
+	return o.v_int, nil
+}
+
+func (o OTSColumnValue) AsString() (string, error) {
+	if err := o._check_type(OTSColumnType_STRING); err != nil {
+		return "", err
+	}
+	return o.v_string, nil
+}
+
+func (o OTSColumnValue) AsBoolean() (bool, error) {
+	if err := o._check_type(OTSColumnType_BOOLEAN); err != nil {
+		return false, err
+	}
+	return o.v_bool, nil
+}
+
+func (o OTSColumnValue) AsDouble() (float64, error) {
+	if err := o._check_type(OTSColumnType_DOUBLE); err != nil {
+		return 0, err
+	}
+	return o.v_double, nil
+}
+
+func (o OTSColumnValue) AsBinary() ([]byte, error) {
+	if err := o._check_type(OTSColumnType_BINARY); err != nil {
+		return nil, err
+	}
+	return o.v_binary, nil
+}
+
+// INTEGER 转换为int32，超出范围时返回错误
+func (o OTSColumnValue) AsInt32() (int32, error) {
+	v, err := o.AsInteger()
+	if err != nil {
+		return 0, err
+	}
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return 0, errors.New(fmt.Sprintf("%d overflows int32", v))
+	}
+	return int32(v), nil
+}
+
+// INTEGER 转换为uint64，负数时返回错误
+func (o OTSColumnValue) AsUint64() (uint64, error) {
+	v, err := o.AsInteger()
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		return 0, errors.New(fmt.Sprintf("%d overflows uint64", v))
+	}
+	return uint64(v), nil
+}
+
+// 返回map 中使用的Go 类型值：int64、string、bool、float64、[]byte、OTS_INF_MIN 或OTS_INF_MAX，无效时返回nil
+func (o OTSColumnValue) Interface() interface{} {
+	switch o.column_type {
+	case OTSColumnType_INTEGER:
+		return o.v_int
+	case OTSColumnType_STRING:
+		return o.v_string
+	case OTSColumnType_BOOLEAN:
+		return o.v_bool
+	case OTSColumnType_DOUBLE:
+		return o.v_double
+	case OTSColumnType_BINARY:
+		return o.v_binary
+	case OTSColumnType_INF_MIN_NAME:
+		return OTSColumnType_INF_MIN
+	case OTSColumnType_INF_MAX_NAME:
+		return OTSColumnType_INF_MAX
+	}
+
+	return nil
+}
+
+func (o OTSColumnValue) String() string {
+	switch o.column_type {
+	case "":
+		return "None"
+	case OTSColumnType_INF_MIN_NAME, OTSColumnType_INF_MAX_NAME:
+		return o.column_type
+	}
+
+	return fmt.Sprintf("%v", o.Interface())
+}
+
+func (o OTSColumnValue) _check_type(column_type string) error {
+	if o.column_type != column_type {
+		return errors.New(fmt.Sprintf("expect %s column value, not %s", column_type, o._type_name()))
+	}
+	return nil
+}
+
+func (o OTSColumnValue) _type_name() string {
+	if o.column_type == "" {
+		return "None"
+	}
+	return o.column_type
+}
@@ -0,0 +1,131 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// primary key comparison for ots2
+package otstype
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// the order of column types, the same as the server
+var column_type_rank = map[string]int{
+	OTSColumnType_INF_MIN_NAME: 0,
+	OTSColumnType_INTEGER:      1,
+	OTSColumnType_STRING:       2,
+	OTSColumnType_BOOLEAN:      3,
+	OTSColumnType_DOUBLE:       4,
+	OTSColumnType_BINARY:       5,
+	OTSColumnType_INF_MAX_NAME: 6,
+}
+
+// 说明：按OTS 服务端的规则比较两个列值。
+//
+// 		INF_MIN 小于任何值，INF_MAX 大于任何值；不同类型按INTEGER < STRING < BOOLEAN < DOUBLE < BINARY 排序；
+// 		STRING 和BINARY 按字节比较。
+//
+// 		返回：a 小于、等于、大于b 时分别返回-1、0、1。
+func CompareColumnValue(a, b OTSColumnValue) int {
+	a_rank, b_rank := column_type_rank[a.Type()], column_type_rank[b.Type()]
+	if a_rank != b_rank {
+		return _compare_int64(int64(a_rank), int64(b_rank))
+	}
+
+	switch a.Type() {
+	case OTSColumnType_INTEGER:
+		return _compare_int64(a.v_int, b.v_int)
+	case OTSColumnType_STRING:
+		return bytes.Compare([]byte(a.v_string), []byte(b.v_string))
+	case OTSColumnType_BINARY:
+		return bytes.Compare(a.v_binary, b.v_binary)
+	case OTSColumnType_BOOLEAN:
+		if a.v_bool == b.v_bool {
+			return 0
+		} else if b.v_bool {
+			return -1
+		}
+		return 1
+	case OTSColumnType_DOUBLE:
+		if a.v_double < b.v_double {
+			return -1
+		} else if a.v_double > b.v_double {
+			return 1
+		}
+		return 0
+	}
+
+	return 0
+}
+
+// 说明：按OTS 服务端的规则比较两个主键。
+//
+// 		主键列按发送顺序逐列比较，两个主键的列数和列名需要一一对应，
+// 		*OTSPrimaryKey 按列名排序比较，多列主键应使用 *OTSOrderedPrimaryKey。
+//
+// 		返回：a 小于、等于、大于b 时分别返回-1、0、1。
+// 		      错误信息，主键列不对应或列值无效时返回错误。
+func ComparePrimaryKey(a, b OTSPrimaryKeyInterface) (int, error) {
+	a_list, b_list := GetPrimaryKeyList(a), GetPrimaryKeyList(b)
+	if len(a_list) != len(b_list) {
+		return 0, errors.New(fmt.Sprintf("primary keys have %d and %d columns", len(a_list), len(b_list)))
+	}
+
+	for i := range a_list {
+		if a_list[i].K != b_list[i].K {
+			return 0, errors.New(fmt.Sprintf("primary key column %d is %s and %s", i, a_list[i].K, b_list[i].K))
+		}
+		a_value, err := NewColumnValue(a_list[i].V)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("primary key column %s: %s", a_list[i].K, err))
+		}
+		b_value, err := NewColumnValue(b_list[i].V)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("primary key column %s: %s", b_list[i].K, err))
+		}
+		if c := CompareColumnValue(a_value, b_value); c != 0 {
+			return c, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// 说明：检查GetRange 的范围与读取方向是否一致。
+//
+// 		FORWARD 时``inclusive_start_primary_key``不能大于``exclusive_end_primary_key``，
+// 		BACKWARD 时不能小于。
+//
+// 		返回：错误信息，范围无效时返回错误。
+func ValidateRange(direction string, inclusive_start_primary_key, exclusive_end_primary_key OTSPrimaryKeyInterface) error {
+	c, err := ComparePrimaryKey(inclusive_start_primary_key, exclusive_end_primary_key)
+	if err != nil {
+		return err
+	}
+
+	switch direction {
+	case OTSDirection_FORWARD:
+		if c > 0 {
+			return errors.New("inclusive_start_primary_key should not be greater than exclusive_end_primary_key in FORWARD")
+		}
+	case OTSDirection_BACKWARD:
+		if c < 0 {
+			return errors.New("inclusive_start_primary_key should not be less than exclusive_end_primary_key in BACKWARD")
+		}
+	default:
+		return errors.New(fmt.Sprintf("direction should be FORWARD or BACKWARD, not %s", direction))
+	}
+
+	return nil
+}
+
+func _compare_int64(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
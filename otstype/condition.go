@@ -0,0 +1,120 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// column condition for ots2
+package otstype
+
+const (
+	// OTSColumnConditionType
+	OTSColumnConditionType_RELATION  = "CCT_RELATION"
+	OTSColumnConditionType_COMPOSITE = "CCT_COMPOSITE"
+
+	// OTSComparatorType
+	OTSComparatorType_EQUAL         = "CT_EQUAL"
+	OTSComparatorType_NOT_EQUAL     = "CT_NOT_EQUAL"
+	OTSComparatorType_GREATER_THAN  = "CT_GREATER_THAN"
+	OTSComparatorType_GREATER_EQUAL = "CT_GREATER_EQUAL"
+	OTSComparatorType_LESS_THAN     = "CT_LESS_THAN"
+	OTSComparatorType_LESS_EQUAL    = "CT_LESS_EQUAL"
+
+	// OTSLogicalOperator
+	OTSLogicalOperator_NOT = "LO_NOT"
+	OTSLogicalOperator_AND = "LO_AND"
+	OTSLogicalOperator_OR  = "LO_OR"
+)
+
+// 列条件，在GetRow、BatchGetRow和GetRange中作为服务端过滤条件使用
+// 可以是单列条件 OTSRelationCondition 或组合条件 OTSCompositeCondition
+type OTSColumnCondition interface {
+	// 条件类型，取值为 OTSColumnConditionType_RELATION 或 OTSColumnConditionType_COMPOSITE
+	GetConditionType() string
+}
+
+// 单列条件，将某一属性列的值与给定值进行比较
+type OTSRelationCondition struct {
+	// 比较的列名
+	ColumnName string
+	// 比较方式，取值为 OTSComparatorType_XXX
+	Comparator string
+	// 比较的值，类型与属性列的值相同，如int、string、bool、float64、[]byte
+	ColumnValue interface{}
+	// 当该列不存在时，是否认为条件成立
+	PassIfMissing bool
+}
+
+// 创建一个单列条件
+func NewRelationCondition(column_name string, comparator string, column_value interface{}, pass_if_missing bool) *OTSRelationCondition {
+	return &OTSRelationCondition{
+		ColumnName:    column_name,
+		Comparator:    comparator,
+		ColumnValue:   column_value,
+		PassIfMissing: pass_if_missing,
+	}
+}
+
+func (o OTSRelationCondition) GetConditionType() string {
+	return OTSColumnConditionType_RELATION
+}
+
+// 组合条件，使用NOT、AND或OR将多个子条件组合起来
+// NOT 只能有一个子条件，AND 和 OR 至少需要两个子条件，子条件可以嵌套组合条件。
+type OTSCompositeCondition struct {
+	// 组合方式，取值为 OTSLogicalOperator_XXX
+	Combinator string
+	// 全部子条件
+	SubConditions []OTSColumnCondition
+}
+
+// 创建一个组合条件
+func NewCompositeCondition(combinator string, sub_conditions ...OTSColumnCondition) *OTSCompositeCondition {
+	return &OTSCompositeCondition{
+		Combinator:    combinator,
+		SubConditions: sub_conditions,
+	}
+}
+
+// NOT 条件
+func NewNotCondition(sub_condition OTSColumnCondition) *OTSCompositeCondition {
+	return NewCompositeCondition(OTSLogicalOperator_NOT, sub_condition)
+}
+
+// AND 条件
+func NewAndCondition(sub_conditions ...OTSColumnCondition) *OTSCompositeCondition {
+	return NewCompositeCondition(OTSLogicalOperator_AND, sub_conditions...)
+}
+
+// OR 条件
+func NewOrCondition(sub_conditions ...OTSColumnCondition) *OTSCompositeCondition {
+	return NewCompositeCondition(OTSLogicalOperator_OR, sub_conditions...)
+}
+
+// 添加一个子条件
+func (o *OTSCompositeCondition) AddSubCondition(sub_condition OTSColumnCondition) *OTSCompositeCondition {
+	if o != nil {
+		o.SubConditions = append(o.SubConditions, sub_condition)
+	}
+
+	return o
+}
+
+func (o OTSCompositeCondition) GetConditionType() string {
+	return OTSColumnConditionType_COMPOSITE
+}
+
+// 行条件，在PutRow、UpdateRow、DeleteRow和BatchWriteRow中使用，满足条件才执行写操作
+// 由行存在性条件和可选的列条件组成，列条件可用于实现乐观锁，如“仅当version等于7时才更新”
+type OTSCondition struct {
+	// 行存在性条件，取值为 OTSCondition_IGNORE、OTSCondition_EXPECT_EXIST 或 OTSCondition_EXPECT_NOT_EXIST
+	RowExistence string
+	// 可选，列条件，可以是 OTSRelationCondition 或 OTSCompositeCondition
+	ColumnCondition OTSColumnCondition
+}
+
+// 创建一个行条件，column_condition 为 nil 时只检查行存在性
+func NewCondition(row_existence string, column_condition OTSColumnCondition) *OTSCondition {
+	return &OTSCondition{
+		RowExistence:    row_existence,
+		ColumnCondition: column_condition,
+	}
+}
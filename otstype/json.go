@@ -0,0 +1,307 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// typed JSON encoding for ots2
+//
+// 列值编码为带类型的JSON 对象，解码后类型不变：
+//
+// 	{"type":"INTEGER","value":"9223372036854775807"}  // INTEGER 编码为字符串，避免精度丢失
+// 	{"type":"STRING","value":"张三"}
+// 	{"type":"BOOLEAN","value":true}
+// 	{"type":"DOUBLE","value":1}                      // NaN 和±Inf 编码为"NaN"、"Infinity"、"-Infinity"
+// 	{"type":"BINARY","value":"AQID"}                 // base64
+// 	{"type":"INF_MIN"}
+//
+// OTSPrimaryKey 和OTSAttribute 编码为列名到列值的对象，OTSOrderedPrimaryKey 编码为
+// 带``name``的列值数组，OTSSchemaOfPrimaryKey 编码为``[{"name":"gid","type":"INTEGER"}]``。
+package otstype
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+var json_null = []byte("null")
+
+// a column value in JSON, name is only used in lists
+type json_column struct {
+	Name  string          `json:"name,omitempty"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (o OTSColumnValue) MarshalJSON() ([]byte, error) {
+	column, err := o._to_json("")
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(column)
+}
+
+func (o *OTSColumnValue) UnmarshalJSON(data []byte) error {
+	var column json_column
+	if err := json.Unmarshal(data, &column); err != nil {
+		return err
+	}
+
+	return o._from_json(column)
+}
+
+func (o OTSColumnValue) _to_json(name string) (json_column, error) {
+	column := json_column{Name: name, Type: o.column_type}
+
+	var err error
+	switch o.column_type {
+	case OTSColumnType_INTEGER:
+		column.Value, err = json.Marshal(strconv.FormatInt(o.v_int, 10))
+	case OTSColumnType_STRING:
+		column.Value, err = json.Marshal(o.v_string)
+	case OTSColumnType_BOOLEAN:
+		column.Value, err = json.Marshal(o.v_bool)
+	case OTSColumnType_DOUBLE:
+		switch {
+		case math.IsNaN(o.v_double):
+			column.Value, err = json.Marshal("NaN")
+		case math.IsInf(o.v_double, 1):
+			column.Value, err = json.Marshal("Infinity")
+		case math.IsInf(o.v_double, -1):
+			column.Value, err = json.Marshal("-Infinity")
+		default:
+			column.Value, err = json.Marshal(o.v_double)
+		}
+	case OTSColumnType_BINARY:
+		column.Value, err = json.Marshal(base64.StdEncoding.EncodeToString(o.v_binary))
+	case OTSColumnType_INF_MIN_NAME, OTSColumnType_INF_MAX_NAME:
+	default:
+		return column, errors.New(fmt.Sprintf("invalid column value type: %s", o._type_name()))
+	}
+
+	return column, err
+}
+
+func (o *OTSColumnValue) _from_json(column json_column) error {
+	value := column.Value
+
+	switch column.Type {
+	case OTSColumnType_INTEGER:
+		// the canonical form is a string, a number is also accepted
+		text := string(value)
+		if len(value) > 0 && value[0] == '"' {
+			if err := json.Unmarshal(value, &text); err != nil {
+				return err
+			}
+		}
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid INTEGER value %s", value))
+		}
+		*o = NewIntegerValue(v)
+
+	case OTSColumnType_STRING:
+		var v string
+		if err := json.Unmarshal(value, &v); err != nil {
+			return errors.New(fmt.Sprintf("invalid STRING value %s", value))
+		}
+		*o = NewStringValue(v)
+
+	case OTSColumnType_BOOLEAN:
+		var v bool
+		if err := json.Unmarshal(value, &v); err != nil {
+			return errors.New(fmt.Sprintf("invalid BOOLEAN value %s", value))
+		}
+		*o = NewBooleanValue(v)
+
+	case OTSColumnType_DOUBLE:
+		var v float64
+		var text string
+		if json.Unmarshal(value, &text) == nil {
+			switch text {
+			case "NaN":
+				v = math.NaN()
+			case "Infinity":
+				v = math.Inf(1)
+			case "-Infinity":
+				v = math.Inf(-1)
+			default:
+				return errors.New(fmt.Sprintf("invalid DOUBLE value %s", value))
+			}
+		} else if err := json.Unmarshal(value, &v); err != nil {
+			return errors.New(fmt.Sprintf("invalid DOUBLE value %s", value))
+		}
+		*o = NewDoubleValue(v)
+
+	case OTSColumnType_BINARY:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return errors.New(fmt.Sprintf("invalid BINARY value %s", value))
+		}
+		v, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid BINARY value %s", value))
+		}
+		*o = NewBinaryValue(v)
+
+	case OTSColumnType_INF_MIN_NAME:
+		*o = NewInfMinValue()
+
+	case OTSColumnType_INF_MAX_NAME:
+		*o = NewInfMaxValue()
+
+	default:
+		return errors.New(fmt.Sprintf("invalid column value type: %s", column.Type))
+	}
+
+	return nil
+}
+
+// encode a map of column values, the values are converted by NewColumnValue
+func _marshal_column_dict(dict DictString) ([]byte, error) {
+	if dict == nil {
+		return json_null, nil
+	}
+
+	columns := make(map[string]OTSColumnValue, len(dict))
+	for name, v := range dict {
+		column_value, err := NewColumnValue(v)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("column %s: %s", name, err))
+		}
+		columns[name] = column_value
+	}
+
+	return json.Marshal(columns)
+}
+
+// decode a map of column values into the Go values used by DictString
+func _unmarshal_column_dict(data []byte) (DictString, error) {
+	if bytes.Equal(bytes.TrimSpace(data), json_null) {
+		return nil, nil
+	}
+
+	var columns map[string]OTSColumnValue
+	if err := json.Unmarshal(data, &columns); err != nil {
+		return nil, err
+	}
+
+	dict := make(DictString, len(columns))
+	for name, v := range columns {
+		dict[name] = v.Interface()
+	}
+
+	return dict, nil
+}
+
+func (o OTSPrimaryKey) MarshalJSON() ([]byte, error) {
+	return _marshal_column_dict(DictString(o))
+}
+
+func (o *OTSPrimaryKey) UnmarshalJSON(data []byte) error {
+	dict, err := _unmarshal_column_dict(data)
+	if err != nil {
+		return err
+	}
+	*o = OTSPrimaryKey(dict)
+
+	return nil
+}
+
+func (o OTSAttribute) MarshalJSON() ([]byte, error) {
+	return _marshal_column_dict(DictString(o))
+}
+
+func (o *OTSAttribute) UnmarshalJSON(data []byte) error {
+	dict, err := _unmarshal_column_dict(data)
+	if err != nil {
+		return err
+	}
+	*o = OTSAttribute(dict)
+
+	return nil
+}
+
+func (o OTSOrderedPrimaryKey) MarshalJSON() ([]byte, error) {
+	if o == nil {
+		return json_null, nil
+	}
+
+	columns := make([]json_column, len(o))
+	for i, v := range o {
+		column_value, err := NewColumnValue(v.V)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("column %s: %s", v.K, err))
+		}
+		if columns[i], err = column_value._to_json(v.K); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(columns)
+}
+
+func (o *OTSOrderedPrimaryKey) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), json_null) {
+		*o = nil
+		return nil
+	}
+
+	var columns []json_column
+	if err := json.Unmarshal(data, &columns); err != nil {
+		return err
+	}
+
+	ordered := make(OTSOrderedPrimaryKey, len(columns))
+	for i, column := range columns {
+		var column_value OTSColumnValue
+		if err := column_value._from_json(column); err != nil {
+			return errors.New(fmt.Sprintf("column %s: %s", column.Name, err))
+		}
+		ordered[i] = TupleString{K: column.Name, V: column_value.Interface()}
+	}
+	*o = ordered
+
+	return nil
+}
+
+func (o OTSSchemaOfPrimaryKey) MarshalJSON() ([]byte, error) {
+	if o == nil {
+		return json_null, nil
+	}
+
+	columns := make([]json_column, len(o))
+	for i, v := range o {
+		column_type, ok := v.V.(string)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("the type of primary key column %s should be string, not %T", v.K, v.V))
+		}
+		columns[i] = json_column{Name: v.K, Type: column_type}
+	}
+
+	return json.Marshal(columns)
+}
+
+func (o *OTSSchemaOfPrimaryKey) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), json_null) {
+		*o = nil
+		return nil
+	}
+
+	var columns []json_column
+	if err := json.Unmarshal(data, &columns); err != nil {
+		return err
+	}
+
+	schema_of_primary_key := make(OTSSchemaOfPrimaryKey, len(columns))
+	for i, column := range columns {
+		schema_of_primary_key[i] = TupleString{K: column.Name, V: column.Type}
+	}
+	*o = schema_of_primary_key
+
+	return nil
+}
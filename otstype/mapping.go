@@ -0,0 +1,332 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// struct tag based row mapping for ots2
+//
+// 结构体的导出字段通过``ots``标签映射到表的列，未设置标签时使用字段名作为列名：
+//
+// 	type User struct {
+// 		Gid     int64   `ots:"gid,pk"`
+// 		Uid     int64   `ots:"uid,pk"`
+// 		Name    string  `ots:"name"`
+// 		Age     *int    `ots:"age"` // 指针字段，列不存在时为nil
+// 		Avatar  []byte  `ots:"avatar"`
+// 		Comment string  `ots:"-"`   // 忽略该字段
+// 	}
+//
+// 支持的字段类型为int、int8~int64、uint8~uint64、string、bool、float32、float64、[]byte，
+// 以及它们的指针类型。
+package otstype
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const OTS_STRUCT_TAG = "ots"
+
+// a column mapped from a struct field
+type struct_column struct {
+	name  string
+	index int
+	pk    bool
+}
+
+// the columns of struct types, map[reflect.Type][]struct_column
+var struct_columns_cache sync.Map
+
+var bytes_type = reflect.TypeOf([]byte(nil))
+
+// 说明：将结构体的主键字段转换为有序的主键。
+//
+// 		``v``为结构体或结构体指针，主键字段使用``ots:"name,pk"``标签标记，
+// 		主键列按字段在结构体中的顺序排列，应与建表时的主键顺序一致。
+//
+// 		返回：主键。
+// 		      错误信息。
+func MarshalPrimaryKey(v interface{}) (primary_key *OTSOrderedPrimaryKey, err error) {
+	primary_key, _, err = _marshal_row(v, false)
+	return primary_key, err
+}
+
+// 说明：将结构体转换为主键和属性列，可以直接用于PutRow 等接口。
+//
+// 		``v``为结构体或结构体指针；值为nil 的指针字段不会写入。
+// 		主键与MarshalPrimaryKey 相同，按字段在结构体中的顺序排列。
+//
+// 		返回：主键。
+// 		      属性列。
+// 		      错误信息。
+func MarshalRow(v interface{}) (primary_key *OTSOrderedPrimaryKey, attribute_columns OTSAttribute, err error) {
+	return _marshal_row(v, true)
+}
+
+// 说明：将读取到的行数据填充到结构体中。
+//
+// 		``v``为结构体指针；行中不存在的列对应的字段被置为零值，指针字段被置为nil。
+//
+// 		返回：错误信息，列的类型与字段类型不匹配时返回。
+func UnmarshalRow(row *OTSRow, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("UnmarshalRow: v should be a non-nil pointer to struct, not %T", v)
+	}
+	rv = rv.Elem()
+
+	columns, err := _struct_columns(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	var primary_key OTSPrimaryKey
+	var attribute_columns OTSAttribute
+	if row != nil {
+		primary_key = row.GetPrimaryKeyColumns()
+		attribute_columns = row.GetAttributeColumns()
+	}
+	for _, column := range columns {
+		field := rv.Field(column.index)
+		value, ok := primary_key[column.name]
+		if !ok {
+			value, ok = attribute_columns[column.name]
+		}
+		if !ok || value == nil {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+		if err := _set_field(field, value); err != nil {
+			return fmt.Errorf("UnmarshalRow: column %s: %s", column.name, err)
+		}
+	}
+
+	return nil
+}
+
+// 说明：返回结构体映射的所有列名，可以作为columns_to_get 使用。
+//
+// 		``v``为结构体、结构体指针或它们的reflect.Type。
+func ColumnsOfStruct(v interface{}) (columns_to_get OTSColumnsToGet, err error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ColumnsOfStruct: v should be a struct, not %v", t)
+	}
+
+	columns, err := _struct_columns(t)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		columns_to_get = append(columns_to_get, column.name)
+	}
+
+	return columns_to_get, nil
+}
+
+func _marshal_row(v interface{}, with_attributes bool) (*OTSOrderedPrimaryKey, OTSAttribute, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("MarshalRow: v should be a struct or pointer to struct, not %T", v)
+	}
+
+	columns, err := _struct_columns(rv.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	primary_key := OTSOrderedPrimaryKey{}
+	attribute_columns := OTSAttribute{}
+	for _, column := range columns {
+		if !column.pk && !with_attributes {
+			continue
+		}
+		value, err := _get_field(rv.Field(column.index))
+		if err != nil {
+			return nil, nil, fmt.Errorf("MarshalRow: column %s: %s", column.name, err)
+		}
+		if column.pk {
+			if value == nil {
+				return nil, nil, fmt.Errorf("MarshalRow: primary key %s should not be nil", column.name)
+			}
+			primary_key = append(primary_key, TupleString{K: column.name, V: value})
+		} else if value != nil {
+			attribute_columns[column.name] = value
+		}
+	}
+	if len(primary_key) == 0 {
+		return nil, nil, fmt.Errorf("MarshalRow: %s has no primary key field, use `ots:\"name,pk\"` to tag it", rv.Type())
+	}
+
+	return &primary_key, attribute_columns, nil
+}
+
+func _struct_columns(t reflect.Type) ([]struct_column, error) {
+	if cached, ok := struct_columns_cache.Load(t); ok {
+		return cached.([]struct_column), nil
+	}
+
+	columns := []struct_column{}
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		tag := field.Tag.Get(OTS_STRUCT_TAG)
+		if tag == "-" {
+			continue
+		}
+
+		column := struct_column{name: field.Name, index: i}
+		options := strings.Split(tag, ",")
+		if options[0] != "" {
+			column.name = options[0]
+		}
+		for _, option := range options[1:] {
+			switch option {
+			case "pk":
+				column.pk = true
+			default:
+				return nil, fmt.Errorf("%s.%s: unknown ots tag option %q", t, field.Name, option)
+			}
+		}
+		if !_is_supported_type(field.Type) {
+			return nil, fmt.Errorf("%s.%s: unsupported field type %s", t, field.Name, field.Type)
+		}
+		if names[column.name] {
+			return nil, fmt.Errorf("%s.%s: duplicated column name %s", t, field.Name, column.name)
+		}
+		names[column.name] = true
+		columns = append(columns, column)
+	}
+	struct_columns_cache.Store(t, columns)
+
+	return columns, nil
+}
+
+func _is_supported_type(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == bytes_type || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.String, reflect.Bool, reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// the column value of field, nil for nil pointer
+func _get_field(field reflect.Value) (interface{}, error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, nil
+		}
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int(), nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if field.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int64", field.Uint())
+		}
+		return int64(field.Uint()), nil
+	case reflect.String:
+		return field.String(), nil
+	case reflect.Bool:
+		return field.Bool(), nil
+	case reflect.Float32, reflect.Float64:
+		return field.Float(), nil
+	case reflect.Slice:
+		if field.IsNil() {
+			return nil, nil
+		}
+		return field.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported field type %s", field.Type())
+}
+
+func _set_field(field reflect.Value, value interface{}) error {
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := _set_field(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, ok := value.(int64)
+		if !ok {
+			break
+		}
+		if field.OverflowInt(v) {
+			return fmt.Errorf("%d overflows %s", v, field.Type())
+		}
+		field.SetInt(v)
+		return nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, ok := value.(int64)
+		if !ok {
+			break
+		}
+		if v < 0 || field.OverflowUint(uint64(v)) {
+			return fmt.Errorf("%d overflows %s", v, field.Type())
+		}
+		field.SetUint(uint64(v))
+		return nil
+	case reflect.String:
+		v, ok := value.(string)
+		if !ok {
+			break
+		}
+		field.SetString(v)
+		return nil
+	case reflect.Bool:
+		v, ok := value.(bool)
+		if !ok {
+			break
+		}
+		field.SetBool(v)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		v, ok := value.(float64)
+		if !ok {
+			break
+		}
+		field.SetFloat(v)
+		return nil
+	case reflect.Slice:
+		v, ok := value.([]byte)
+		if !ok {
+			break
+		}
+		field.SetBytes(append([]byte(nil), v...))
+		return nil
+	}
+
+	return fmt.Errorf("cannot unmarshal %T into %s", value, field.Type())
+}
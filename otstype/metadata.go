@@ -6,6 +6,7 @@
 package otstype
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
@@ -233,22 +234,34 @@ type OTSColumnsToDelete []string
 
 // 创建行对象
 type OTSPutRowItem struct {
-	Condition        string
-	PrimaryKey       OTSPrimaryKey
+	// 行存在性条件，取值为 OTSCondition_IGNORE、OTSCondition_EXPECT_EXIST 或 OTSCondition_EXPECT_NOT_EXIST
+	Condition string
+	// 可选，列条件，满足条件才执行写操作
+	ColumnCondition OTSColumnCondition
+	// 主键，OTSPrimaryKey、*OTSPrimaryKey 或 *OTSOrderedPrimaryKey
+	PrimaryKey       OTSPrimaryKeyInterface
 	AttributeColumns OTSAttribute
 }
 
 // 更新行对象
 type OTSUpdateRowItem struct {
-	Condition                string
-	PrimaryKey               OTSPrimaryKey
+	// 行存在性条件，取值为 OTSCondition_IGNORE、OTSCondition_EXPECT_EXIST 或 OTSCondition_EXPECT_NOT_EXIST
+	Condition string
+	// 可选，列条件，满足条件才执行写操作
+	ColumnCondition OTSColumnCondition
+	// 主键，OTSPrimaryKey、*OTSPrimaryKey 或 *OTSOrderedPrimaryKey
+	PrimaryKey               OTSPrimaryKeyInterface
 	UpdateOfAttributeColumns OTSUpdateOfAttribute
 }
 
 // 删除行对象
 type OTSDeleteRowItem struct {
-	Condition  string
-	PrimaryKey OTSPrimaryKey
+	// 行存在性条件，取值为 OTSCondition_IGNORE、OTSCondition_EXPECT_EXIST 或 OTSCondition_EXPECT_NOT_EXIST
+	Condition string
+	// 可选，列条件，满足条件才执行写操作
+	ColumnCondition OTSColumnCondition
+	// 主键，OTSPrimaryKey、*OTSPrimaryKey 或 *OTSOrderedPrimaryKey
+	PrimaryKey OTSPrimaryKeyInterface
 }
 
 // 创建行对象，复杂数据模型
@@ -282,6 +295,10 @@ type OTSTableInBatchGetRowRequestItem struct {
 	Rows OTSPrimaryKeyRows
 	// 该表中需要返回的全部列的列名
 	ColumnsToGet OTSColumnsToGet
+	// 可选，服务端过滤条件，只返回满足条件的行
+	Filter OTSColumnCondition
+	// 可选，表的主键列描述，Rows 按此顺序发送；为nil 时使用OTSClient 缓存的表结构，没有缓存时按列名排序
+	SchemaOfPrimaryKey OTSSchemaOfPrimaryKey
 }
 
 // 在BatchGetRow 操作中，表示要读取的多个表的请求信息
@@ -356,6 +373,8 @@ type OTSDescribeTableResponse struct {
 type OTSRow struct {
 	// 主键列
 	PrimaryKeyColumns OTSPrimaryKey
+	// 主键列，按响应中的顺序排列，即建表时的主键顺序
+	OrderedPrimaryKeyColumns OTSOrderedPrimaryKey
 	// 属性列
 	AttributeColumns OTSAttribute
 }
@@ -375,6 +394,14 @@ func (o *OTSRow) GetPrimaryKeyColumns() OTSPrimaryKey {
 	}
 }
 
+func (o *OTSRow) GetOrderedPrimaryKeyColumns() OTSOrderedPrimaryKey {
+	if o.OrderedPrimaryKeyColumns == nil {
+		return nil
+	} else {
+		return o.OrderedPrimaryKeyColumns
+	}
+}
+
 func (o *OTSRow) GetAttributeColumns() OTSAttribute {
 	if o.AttributeColumns == nil {
 		return nil
@@ -383,6 +410,32 @@ func (o *OTSRow) GetAttributeColumns() OTSAttribute {
 	}
 }
 
+// 说明：返回有类型的主键列值。
+//
+// 		返回：列值。
+// 		      错误信息，列不存在时返回错误。
+func (o *OTSRow) GetPrimaryKeyValue(name string) (OTSColumnValue, error) {
+	value, ok := o.PrimaryKeyColumns[name]
+	if !ok {
+		return OTSColumnValue{}, errors.New(fmt.Sprintf("primary key column %s is missing", name))
+	}
+
+	return NewColumnValue(value)
+}
+
+// 说明：返回有类型的属性列值。
+//
+// 		返回：列值。
+// 		      错误信息，列不存在时返回错误。
+func (o *OTSRow) GetAttributeValue(name string) (OTSColumnValue, error) {
+	value, ok := o.AttributeColumns[name]
+	if !ok {
+		return OTSColumnValue{}, errors.New(fmt.Sprintf("attribute column %s is missing", name))
+	}
+
+	return NewColumnValue(value)
+}
+
 // 多行数据
 type OTSRows []*OTSRow
 
@@ -637,6 +690,8 @@ type OTSGetRangeResponse struct {
 	// 即使在GetRange 请求中未设定limit，在响应中仍可能出现next_start_primary_key。因此在使用
 	// GetRange 时一定要对响应中是否有next_start_primary_key 进行处理。
 	NextStartPrimaryKey OTSPrimaryKey
+	// 同NextStartPrimaryKey，按建表时的主键顺序排列，可以直接作为下一次GetRange 的inclusive_start_primary_key
+	NextStartOrderedPrimaryKey OTSOrderedPrimaryKey
 	// 读取到的所有数据，若请求中direction 为FORWARD，则所有行按照主键由小到大进行排
 	// 序；若请求中direction 为BACKWARD，则所有行按照主键由大到小进行排序
 	// 其中每行的primary_key_columns 和attribute_columns 均只包含在columns_to_get 中指定的
@@ -663,6 +718,14 @@ func (o *OTSGetRangeResponse) GetNextStartPrimaryKey() OTSPrimaryKey {
 	return nil
 }
 
+func (o *OTSGetRangeResponse) GetNextStartOrderedPrimaryKey() OTSOrderedPrimaryKey {
+	if o.NextStartOrderedPrimaryKey != nil {
+		return o.NextStartOrderedPrimaryKey
+	}
+
+	return nil
+}
+
 func (o *OTSGetRangeResponse) GetRows() OTSRows {
 	if o.Rows != nil {
 		return o.Rows
@@ -0,0 +1,134 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// ordered primary key for ots2
+package otstype
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// 主键，可以是 OTSPrimaryKey、*OTSPrimaryKey 或 *OTSOrderedPrimaryKey
+//
+// 		OTS 要求主键列按建表时的顺序发送，OTSPrimaryKey 没有顺序，OTSClient 缓存了表结构时按表的主键顺序发送，
+// 		否则按列名排序后发送，只有主键列名的字母顺序与建表顺序相同时才正确；多列主键应使用 *OTSOrderedPrimaryKey。
+type OTSPrimaryKeyInterface interface {
+	// 返回按发送顺序排列的主键列
+	PrimaryKeyList() ListString
+}
+
+// 按列名排序的主键列，nil 时返回nil
+func (o OTSPrimaryKey) PrimaryKeyList() ListString {
+	if o == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make(ListString, len(names))
+	for i, name := range names {
+		list[i] = TupleString{K: name, V: o[name]}
+	}
+
+	return list
+}
+
+// 说明：返回主键的主键列，primary_key 为nil 或nil 指针时返回nil。
+func GetPrimaryKeyList(primary_key OTSPrimaryKeyInterface) ListString {
+	switch v := primary_key.(type) {
+	case nil:
+		return nil
+	case *OTSPrimaryKey:
+		if v == nil {
+			return nil
+		}
+	}
+
+	return primary_key.PrimaryKeyList()
+}
+
+// 有序的主键列值，按建表时的主键顺序排列，如：
+//
+// 		primary_key := &OTSOrderedPrimaryKey{
+// 			{K: "uid", V: 101},
+// 			{K: "gid", V: 1},
+// 		}
+type OTSOrderedPrimaryKey ListString
+
+// 说明：按表的主键顺序创建有序的主键。
+//
+// 		``schema_of_primary_key``是表的主键列描述，可以通过DescribeTable 获取。
+// 		``primary_key``需要包含全部主键列，且不能包含其它列。
+//
+// 		返回：有序的主键。
+// 		      错误信息。
+func NewPrimaryKeyFromSchema(schema_of_primary_key OTSSchemaOfPrimaryKey, primary_key OTSPrimaryKey) (*OTSOrderedPrimaryKey, error) {
+	if len(primary_key) != len(schema_of_primary_key) {
+		return nil, errors.New(fmt.Sprintf("primary key should have %d columns, not %d", len(schema_of_primary_key), len(primary_key)))
+	}
+
+	ordered := make(OTSOrderedPrimaryKey, len(schema_of_primary_key))
+	for i, column_schema := range schema_of_primary_key {
+		value, ok := primary_key[column_schema.GetName()]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("primary key column %s is missing", column_schema.GetName()))
+		}
+		ordered[i] = TupleString{K: column_schema.GetName(), V: value}
+	}
+
+	return &ordered, nil
+}
+
+func (o *OTSOrderedPrimaryKey) PrimaryKeyList() ListString {
+	if o == nil {
+		return nil
+	}
+
+	return ListString(*o)
+}
+
+func (o OTSOrderedPrimaryKey) String() string {
+	r := ""
+	if o == nil {
+		return "None"
+	}
+
+	for _, v := range o {
+		r = r + fmt.Sprintf("(%s:%v)", v.K, v.V)
+	}
+
+	return r
+}
+
+func (o *OTSOrderedPrimaryKey) Del(key string) {
+	(*ListString)(o).Del(key)
+}
+
+func (o *OTSOrderedPrimaryKey) Get(key string) interface{} {
+	return (*ListString)(o).Get(key)
+}
+
+func (o *OTSOrderedPrimaryKey) Set(key string, value interface{}) {
+	(*ListString)(o).Set(key, value)
+}
+
+// 转换为没有顺序的OTSPrimaryKey
+func (o OTSOrderedPrimaryKey) ToPrimaryKey() OTSPrimaryKey {
+	if o == nil {
+		return nil
+	}
+
+	primary_key := make(OTSPrimaryKey, len(o))
+	for _, v := range o {
+		primary_key[v.K] = v.V
+	}
+
+	return primary_key
+}
@@ -126,7 +126,7 @@ func (d *ListString) Del(key string) {
 		for i, v := range *d {
 			if key == v.GetKey() {
 				*d = append((*d)[:i], (*d)[i+1:]...)
-				fmt.Println("test", d)
+				return
 			}
 		}
 	}
@@ -0,0 +1,181 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test ordered primary key for ots2
+package goots
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_ordered_primary_key(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	ots_emulator.StrictPrimaryKeyOrder = true
+	ots_emulator.GetRangeMaxRows = 2
+
+	// the order of schema is not the order of names
+	table_meta := &OTSTableMeta{
+		TableName: "orderTable",
+		SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{
+			{K: "uid", V: "INTEGER"},
+			{K: "gid", V: "INTEGER"},
+		},
+	}
+	if ots_err := client.CreateTable(table_meta, &OTSReservedThroughput{CapacityUnit: OTSCapacityUnit{Read: 100, Write: 100}}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// OTSPrimaryKey is sent in the order of names if the schema is unknown
+	client.schemas.invalidate("orderTable")
+	if _, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, &OTSPrimaryKey{"uid": 1, "gid": 1}, &OTSAttribute{"age": 1}); !errors.Is(ots_err, ErrInvalidPK) {
+		t.Fatalf("error should be ErrInvalidPK, not %v", ots_err)
+	}
+
+	describe_table_response, ots_err := client.DescribeTable("orderTable")
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	for i := 0; i < 5; i++ {
+		primary_key, err := NewPrimaryKeyFromSchema(describe_table_response.TableMeta.SchemaOfPrimaryKey, OTSPrimaryKey{"gid": 1, "uid": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, primary_key, &OTSAttribute{"age": i}); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+	if _, err := NewPrimaryKeyFromSchema(describe_table_response.TableMeta.SchemaOfPrimaryKey, OTSPrimaryKey{"uid": 1}); err == nil {
+		t.Fatal("primary key without gid should fail")
+	}
+	if _, err := NewPrimaryKeyFromSchema(describe_table_response.TableMeta.SchemaOfPrimaryKey, OTSPrimaryKey{"uid": 1, "name": "a"}); err == nil {
+		t.Fatal("primary key with name should fail")
+	}
+
+	// the decoded primary key is in the order of schema
+	get_row_response, ots_err := client.GetRow("orderTable", &OTSOrderedPrimaryKey{{K: "uid", V: 3}, {K: "gid", V: 1}}, nil)
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	ordered := get_row_response.Row.GetOrderedPrimaryKeyColumns()
+	if len(ordered) != 2 || ordered[0].K != "uid" || ordered[0].V != int64(3) || ordered[1].K != "gid" ||
+		get_row_response.Row.GetPrimaryKeyColumns()["uid"] != int64(3) {
+		t.Fatalf("unexpected row: %v, %v", ordered, get_row_response.Row)
+	}
+
+	// GetRange pages with next_start_primary_key
+	inclusive_start_primary_key := &OTSOrderedPrimaryKey{{K: "uid", V: OTSColumnType_INF_MIN}, {K: "gid", V: OTSColumnType_INF_MIN}}
+	exclusive_end_primary_key := &OTSOrderedPrimaryKey{{K: "uid", V: OTSColumnType_INF_MAX}, {K: "gid", V: OTSColumnType_INF_MAX}}
+	get_range_response, ots_err := client.GetRange("orderTable", OTSDirection_FORWARD, inclusive_start_primary_key, exclusive_end_primary_key, nil, 0)
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	next_start_primary_key := get_range_response.GetNextStartOrderedPrimaryKey()
+	if len(get_range_response.Rows) != 2 || len(next_start_primary_key) != 2 || next_start_primary_key[0].K != "uid" || next_start_primary_key[0].V != int64(2) {
+		t.Fatalf("unexpected response: %v, %v", get_range_response.Rows, next_start_primary_key)
+	}
+	if _, ots_err = client.GetRange("orderTable", OTSDirection_FORWARD, &next_start_primary_key, exclusive_end_primary_key, nil, 0); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	iter := client.XGetRange("orderTable", OTSDirection_FORWARD, inclusive_start_primary_key, exclusive_end_primary_key, nil, 0)
+	defer iter.Close()
+	count := 0
+	for iter.Next() {
+		if iter.Row().GetPrimaryKeyColumns()["uid"] != int64(count) {
+			t.Fatalf("unexpected row %d: %v", count, iter.Row())
+		}
+		count += 1
+	}
+	if iter.Err() != nil || count != 5 {
+		t.Fatalf("excpected %d rows but %d, %v", 5, count, iter.Err())
+	}
+
+	// batch operations, *OTSPrimaryKey is sent in the order of the schema cached by DescribeTable
+	batch_list_write := &OTSBatchWriteRowRequest{
+		{
+			TableName: "orderTable",
+			PutRows: OTSPutRows{
+				{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSOrderedPrimaryKey{{K: "uid", V: 5}, {K: "gid", V: 1}}, AttributeColumns: OTSAttribute{"age": 5}},
+				{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSPrimaryKey{"uid": 6, "gid": 1}, AttributeColumns: OTSAttribute{"age": 6}},
+			},
+			UpdateRows: OTSUpdateRows{
+				{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSOrderedPrimaryKey{{K: "uid", V: 1}, {K: "gid", V: 1}}, UpdateOfAttributeColumns: OTSUpdateOfAttribute{OTSOperationType_PUT: OTSColumnsToPut{"age": 10}}},
+			},
+			DeleteRows: OTSDeleteRows{
+				{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSOrderedPrimaryKey{{K: "uid", V: 0}, {K: "gid", V: 1}}},
+			},
+		},
+	}
+	batch_write_response, ots_err := client.BatchWriteRow(batch_list_write)
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	table := batch_write_response.Tables[0]
+	if !table.PutRows[0].IsOk || !table.PutRows[1].IsOk || !table.UpdateRows[0].IsOk || !table.DeleteRows[0].IsOk {
+		t.Fatalf("unexpected response: %v, %v, %v, %v", table.PutRows[0], table.PutRows[1], table.UpdateRows[0], table.DeleteRows[0])
+	}
+
+	batch_list_get := &OTSBatchGetRowRequest{
+		{
+			TableName:          "orderTable",
+			Rows:               OTSPrimaryKeyRows{{"uid": 1, "gid": 1}, {"uid": 5, "gid": 1}},
+			SchemaOfPrimaryKey: describe_table_response.TableMeta.SchemaOfPrimaryKey,
+		},
+	}
+	batch_get_response, ots_err := client.BulkGetRow(batch_list_get)
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	for i, age := range []int64{10, 5} {
+		row := batch_get_response.Tables[0].Rows[i]
+		if !row.IsOk || row.Row.GetAttributeColumns()["age"] != age {
+			t.Fatalf("unexpected row %d: %v", i, row)
+		}
+	}
+
+	// OTSPrimaryKey and *OTSPrimaryKey are sent in the order of the schema cached in Validator
+	client.Validator = NewValidator()
+	client.Validator.SetTableMeta(describe_table_response.TableMeta)
+	if _, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, &OTSPrimaryKey{"uid": 6, "gid": 1}, &OTSAttribute{"age": 6}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	writer := client.NewBulkWriter(0, 0)
+	writer.Put("orderTable", OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: OTSPrimaryKey{"uid": 7, "gid": 1}, AttributeColumns: OTSAttribute{"age": 7}})
+	if ots_err = writer.Close(); ots_err != nil || len(writer.Failures()) != 0 {
+		t.Fatalf("unexpected result: %v, %v", ots_err, writer.Failures())
+	}
+	batch_list_get = &OTSBatchGetRowRequest{
+		{
+			TableName: "orderTable",
+			Rows:      OTSPrimaryKeyRows{{"uid": 1, "gid": 1}, {"uid": 6, "gid": 1}, {"uid": 7, "gid": 1}},
+		},
+	}
+	batch_get_response, ots_err = client.BatchGetRow(batch_list_get)
+	if ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	for i, age := range []int64{10, 6, 7} {
+		row := batch_get_response.Tables[0].Rows[i]
+		if !row.IsOk || row.Row.GetAttributeColumns()["age"] != age {
+			t.Fatalf("unexpected row %d: %v", i, row)
+		}
+	}
+	if _, ots_err := client.GetRow("orderTable", OTSPrimaryKey{"uid": 7, "gid": 1}, nil); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// nil primary key
+	var primary_key *OTSOrderedPrimaryKey
+	if _, ots_err = client.GetRow("orderTable", primary_key, nil); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("nil primary key should fail on client, not %v", ots_err)
+	}
+	var map_primary_key *OTSPrimaryKey
+	if _, ots_err = client.GetRow("orderTable", map_primary_key, nil); ots_err == nil || ots_err.ClientError == nil {
+		t.Fatalf("nil primary key should fail on client, not %v", ots_err)
+	}
+}
@@ -53,23 +53,30 @@ func _parse_column_type(column_type_enum ColumnType) string {
 	return ColumnType_name[int32(column_type_enum)]
 }
 
-func _parse_value(value *ColumnValue) interface{} {
+func _parse_value(value *ColumnValue) (interface{}, error) {
+	column_value, err := _parse_column_value(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return column_value.Interface(), nil
+}
+
+func _parse_column_value(value *ColumnValue) (OTSColumnValue, error) {
 	switch value.GetType() {
 	case ColumnType_INTEGER:
-		return value.GetVInt()
+		return NewIntegerValue(value.GetVInt()), nil
 	case ColumnType_STRING:
-		return value.GetVString()
+		return NewStringValue(value.GetVString()), nil
 	case ColumnType_BOOLEAN:
-		return value.GetVBool()
+		return NewBooleanValue(value.GetVBool()), nil
 	case ColumnType_DOUBLE:
-		return value.GetVDouble()
+		return NewDoubleValue(value.GetVDouble()), nil
 	case ColumnType_BINARY:
-		return value.GetVBinary()
-	default:
-		panic(errors.New(fmt.Sprintf("invalid column value type: %d", value.GetType())))
+		return NewBinaryValue(value.GetVBinary()), nil
 	}
 
-	return nil
+	return OTSColumnValue{}, errors.New(fmt.Sprintf("invalid column value type: %d", value.GetType()))
 }
 
 func _parse_schema_list(primary_key []*ColumnSchema) OTSSchemaOfPrimaryKey {
@@ -84,42 +91,78 @@ func _parse_schema_list(primary_key []*ColumnSchema) OTSSchemaOfPrimaryKey {
 	return schema_of_primary_key
 }
 
-func _parse_column_dict(colum []*Column) DictString {
+func _parse_column_dict(colum []*Column) (DictString, error) {
 	if len(colum) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	dict := make(DictString, len(colum))
 	for _, v := range colum {
-		dict[v.GetName()] = _parse_value(v.GetValue())
+		value, err := _parse_value(v.GetValue())
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("column %s: %s", v.GetName(), err))
+		}
+		dict[v.GetName()] = value
 	}
 
-	return dict
+	return dict, nil
 }
 
-func _parse_row(row *Row) *OTSRow {
+// the columns in the order of response
+func _parse_column_list(colum []*Column) (ListString, error) {
+	if len(colum) == 0 {
+		return nil, nil
+	}
+
+	list := make(ListString, len(colum))
+	for i, v := range colum {
+		value, err := _parse_value(v.GetValue())
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("column %s: %s", v.GetName(), err))
+		}
+		list[i] = TupleString{K: v.GetName(), V: value}
+	}
+
+	return list, nil
+}
+
+func _parse_row(row *Row) (*OTSRow, error) {
 	if row == nil {
-		return nil
+		return nil, nil
+	}
+
+	primary_key_columns, err := _parse_column_list(row.GetPrimaryKeyColumns())
+	if err != nil {
+		return nil, err
+	}
+	attribute_columns, err := _parse_column_dict(row.GetAttributeColumns())
+	if err != nil {
+		return nil, err
 	}
 
 	ots_row := new(OTSRow)
-	ots_row.PrimaryKeyColumns = (OTSPrimaryKey)(_parse_column_dict(row.GetPrimaryKeyColumns()))
-	ots_row.AttributeColumns = (OTSAttribute)(_parse_column_dict(row.GetAttributeColumns()))
+	ots_row.OrderedPrimaryKeyColumns = (OTSOrderedPrimaryKey)(primary_key_columns)
+	ots_row.PrimaryKeyColumns = ots_row.OrderedPrimaryKeyColumns.ToPrimaryKey()
+	ots_row.AttributeColumns = (OTSAttribute)(attribute_columns)
 
-	return ots_row
+	return ots_row, nil
 }
 
-func _parse_row_list(rows []*Row) OTSRows {
+func _parse_row_list(rows []*Row) (OTSRows, error) {
 	if len(rows) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	ots_rows := make(OTSRows, len(rows))
 	for i, v := range rows {
-		ots_rows[i] = _parse_row(v)
+		ots_row, err := _parse_row(v)
+		if err != nil {
+			return nil, err
+		}
+		ots_rows[i] = ots_row
 	}
 
-	return ots_rows
+	return ots_rows, nil
 }
 
 func _parse_table_meta(table_meta *TableMeta) *OTSTableMeta {
@@ -160,9 +203,9 @@ func _parse_reserved_throughput_details(reserved_throughput_details *ReservedThr
 	return pobj
 }
 
-func _parse_get_row_item(row_list []*RowInBatchGetRowResponse) []*OTSRowInBatchGetRowResponseItem {
+func _parse_get_row_item(row_list []*RowInBatchGetRowResponse) ([]*OTSRowInBatchGetRowResponseItem, error) {
 	if len(row_list) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	pobj := make([]*OTSRowInBatchGetRowResponseItem, len(row_list))
@@ -173,7 +216,11 @@ func _parse_get_row_item(row_list []*RowInBatchGetRowResponse) []*OTSRowInBatchG
 			row_item.ErrorCode = "None"
 			row_item.ErrorMessage = "None"
 			row_item.Consumed = _parse_capacity_unit(v.GetConsumed().GetCapacityUnit())
-			row_item.Row = _parse_row(v.GetRow())
+			row, err := _parse_row(v.GetRow())
+			if err != nil {
+				return nil, err
+			}
+			row_item.Row = row
 
 		} else {
 			row_item.IsOk = v.GetIsOk()
@@ -186,23 +233,27 @@ func _parse_get_row_item(row_list []*RowInBatchGetRowResponse) []*OTSRowInBatchG
 		pobj[i] = row_item
 	}
 
-	return pobj
+	return pobj, nil
 }
 
-func _parse_batch_get_row(table_list []*TableInBatchGetRowResponse) []*OTSTableInBatchGetRowResponseItem {
+func _parse_batch_get_row(table_list []*TableInBatchGetRowResponse) ([]*OTSTableInBatchGetRowResponseItem, error) {
 	if len(table_list) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	pobj := make([]*OTSTableInBatchGetRowResponseItem, len(table_list))
 	for i, v := range table_list {
+		rows, err := _parse_get_row_item(v.GetRows())
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("table %s: %s", v.GetTableName(), err))
+		}
 		table_item := new(OTSTableInBatchGetRowResponseItem)
 		table_item.TableName = v.GetTableName()
-		table_item.Rows = _parse_get_row_item(v.GetRows())
+		table_item.Rows = rows
 		pobj[i] = table_item
 	}
 
-	return pobj
+	return pobj, nil
 }
 
 func _parse_write_row_item(row_list []*RowInBatchWriteRowResponse) []*OTSRowInBatchWriteRowResponseItem {
@@ -257,7 +308,6 @@ func _decode_create_table(buf []byte) (err error) {
 	if err != nil {
 		return err
 	}
-	print_response_message(pb)
 
 	return nil
 }
@@ -268,7 +318,6 @@ func _decode_delete_table(buf []byte) (err error) {
 	if err != nil {
 		return err
 	}
-	print_response_message(pb)
 
 	return nil
 }
@@ -279,7 +328,6 @@ func _decode_list_table(buf []byte) (list_tables *OTSListTableResponse, err erro
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	list_tables = new(OTSListTableResponse)
 	list_tables.TableNames = make([]string, len(pb.TableNames))
@@ -294,7 +342,6 @@ func _decode_update_table(buf []byte) (update_table_response *OTSUpdateTableResp
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	update_table_response = new(OTSUpdateTableResponse)
 	update_table_response.ReservedThroughputDetails = _parse_reserved_throughput_details(pb.GetReservedThroughputDetails())
@@ -308,7 +355,6 @@ func _decode_describe_table(buf []byte) (describe_table_response *OTSDescribeTab
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	describe_table_response = new(OTSDescribeTableResponse)
 	describe_table_response.TableMeta = _parse_table_meta(pb.GetTableMeta())
@@ -323,10 +369,12 @@ func _decode_get_row(buf []byte) (get_row_response *OTSGetRowResponse, err error
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	get_row_response = new(OTSGetRowResponse)
-	get_row_response.Row = _parse_row(pb.GetRow())
+	get_row_response.Row, err = _parse_row(pb.GetRow())
+	if err != nil {
+		return nil, err
+	}
 	get_row_response.Consumed = _parse_capacity_unit(pb.GetConsumed().GetCapacityUnit())
 
 	return get_row_response, nil
@@ -338,7 +386,6 @@ func _decode_put_row(buf []byte) (put_row_response *OTSPutRowResponse, err error
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	put_row_response = new(OTSPutRowResponse)
 	put_row_response.Consumed = _parse_capacity_unit(pb.GetConsumed().GetCapacityUnit())
@@ -352,7 +399,6 @@ func _decode_update_row(buf []byte) (update_row_response *OTSUpdateRowResponse,
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	update_row_response = new(OTSUpdateRowResponse)
 	update_row_response.Consumed = _parse_capacity_unit(pb.GetConsumed().GetCapacityUnit())
@@ -366,7 +412,6 @@ func _decode_delete_row(buf []byte) (delete_row_response *OTSDeleteRowResponse,
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	delete_row_response = new(OTSDeleteRowResponse)
 	delete_row_response.Consumed = _parse_capacity_unit(pb.GetConsumed().GetCapacityUnit())
@@ -380,10 +425,12 @@ func _decode_batch_get_row(buf []byte) (response_item_list *OTSBatchGetRowRespon
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	response_item_list = new(OTSBatchGetRowResponse)
-	response_item_list.Tables = _parse_batch_get_row(pb.GetTables())
+	response_item_list.Tables, err = _parse_batch_get_row(pb.GetTables())
+	if err != nil {
+		return nil, err
+	}
 
 	return response_item_list, nil
 }
@@ -394,7 +441,6 @@ func _decode_batch_write_row(buf []byte) (response_item_list *OTSBatchWriteRowRe
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	response_item_list = new(OTSBatchWriteRowResponse)
 	response_item_list.Tables = _parse_batch_write_row(pb.GetTables())
@@ -408,12 +454,19 @@ func _decode_get_range(buf []byte) (response_row_list *OTSGetRangeResponse, err
 	if err != nil {
 		return nil, err
 	}
-	print_response_message(pb)
 
 	response_row_list = new(OTSGetRangeResponse)
 	response_row_list.Consumed = _parse_capacity_unit(pb.GetConsumed().GetCapacityUnit())
-	response_row_list.NextStartPrimaryKey = (OTSPrimaryKey)(_parse_column_dict(pb.GetNextStartPrimaryKey()))
-	response_row_list.Rows = _parse_row_list(pb.GetRows())
+	next_start_primary_key, err := _parse_column_list(pb.GetNextStartPrimaryKey())
+	if err != nil {
+		return nil, err
+	}
+	response_row_list.NextStartOrderedPrimaryKey = (OTSOrderedPrimaryKey)(next_start_primary_key)
+	response_row_list.NextStartPrimaryKey = response_row_list.NextStartOrderedPrimaryKey.ToPrimaryKey()
+	response_row_list.Rows, err = _parse_row_list(pb.GetRows())
+	if err != nil {
+		return nil, err
+	}
 
 	return response_row_list, nil
 }
@@ -421,8 +474,19 @@ func _decode_get_range(buf []byte) (response_row_list *OTSGetRangeResponse, err
 // request encode for ots2
 func DecodeRequest(api_name string, args ...interface{}) (req []reflect.Value, err error) {
 	if _, ok := api_decode_map[api_name]; !ok {
-		return nil, errors.New(fmt.Sprintf("No PB decode method for API %s" + api_name))
-	}
+		return nil, fmt.Errorf("No PB decode method for API %s", api_name)
+	}
+	// reflect panics if args do not match the decoder, return it as an error
+	defer func() {
+		if r := recover(); r != nil {
+			req = nil
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
 
 	req, err = api_decode_map.Call(api_name, args...)
 	if err != nil {
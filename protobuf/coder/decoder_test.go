@@ -0,0 +1,53 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// testcase for decoder
+
+package coder
+
+import (
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
+)
+
+func Test_decode_invalid_column_value(t *testing.T) {
+	consumed := &ConsumedCapacity{CapacityUnit: &CapacityUnit{Read: NewInt32(1), Write: NewInt32(0)}}
+	inf_min := ColumnType_INF_MIN
+	integer := ColumnType_INTEGER
+	valid := &Column{Name: NewString("gid"), Value: &ColumnValue{Type: &integer, VInt: NewInt64(1)}}
+	invalid := &Column{Name: NewString("age"), Value: &ColumnValue{Type: &inf_min}}
+
+	buf, err := proto.Marshal(&GetRowResponse{Consumed: consumed, Row: &Row{PrimaryKeyColumns: []*Column{valid}, AttributeColumns: []*Column{invalid}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := DecodeRequest("GetRow", buf)
+	if err != nil || resp[1].Interface() == nil {
+		t.Fatalf("INF_MIN in a row should fail, not %v, %v", resp, err)
+	}
+
+	buf, err = proto.Marshal(&GetRangeResponse{Consumed: consumed, NextStartPrimaryKey: []*Column{invalid}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = DecodeRequest("GetRange", buf); err != nil || resp[1].Interface() == nil {
+		t.Fatalf("INF_MIN in next_start_primary_key should fail, not %v, %v", resp, err)
+	}
+
+	// the valid row
+	buf, err = proto.Marshal(&GetRowResponse{Consumed: consumed, Row: &Row{PrimaryKeyColumns: []*Column{valid}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = DecodeRequest("GetRow", buf); err != nil || resp[1].Interface() != nil {
+		t.Fatalf("DecodeRequest error: %v, %v", resp, err)
+	}
+	row := resp[0].Interface().(*OTSGetRowResponse).Row
+	if row.GetPrimaryKeyColumns()["gid"] != int64(1) || row.GetOrderedPrimaryKeyColumns()[0].K != "gid" {
+		t.Fatalf("unexpected row: %v", row)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	. "github.com/GiterLab/goots/otstype"
 	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
 )
 
 const (
@@ -44,30 +45,25 @@ func init() {
 	}
 }
 
-func _get_unicode(value interface{}) string {
+func _get_unicode(value interface{}) (string, error) {
 	if v, ok := value.(string); ok {
-		return v
-	} else {
-		panic(errors.New(fmt.Sprintf("expect str or unicode type for string, not %v", reflect.TypeOf(value))))
+		return v, nil
 	}
 
-	return ""
+	return "", errors.New(fmt.Sprintf("expect str or unicode type for string, not %v", reflect.TypeOf(value)))
 }
 
-func _get_int32(value interface{}) int32 {
+func _get_int32(value interface{}) (int32, error) {
 	if v, ok := value.(int); ok {
-		if int32(v) < INT32_MIN || int32(v) > INT32_MAX {
-			panic(errors.New(fmt.Sprintf("%d exceeds the range of int32", v)))
+		if int64(v) < int64(INT32_MIN) || int64(v) > int64(INT32_MAX) {
+			return 0, errors.New(fmt.Sprintf("%d exceeds the range of int32", v))
 		}
-		return int32(v)
+		return int32(v), nil
 	} else if v, ok := value.(int32); ok {
-		if v < INT32_MIN || v > INT32_MAX {
-			panic(errors.New(fmt.Sprintf("%d exceeds the range of int32", v)))
-		}
-		return v
+		return v, nil
 	}
 
-	panic(errors.New(fmt.Sprintf("expect int or long for the value, not %v", reflect.TypeOf(value))))
+	return 0, errors.New(fmt.Sprintf("expect int or long for the value, not %v", reflect.TypeOf(value)))
 }
 
 func _make_repeated_column_names(pb *[]string, columns_to_get []string) error {
@@ -77,7 +73,11 @@ func _make_repeated_column_names(pb *[]string, columns_to_get []string) error {
 	}
 
 	for _, column_name := range columns_to_get {
-		*pb = append(*pb, _get_unicode(column_name))
+		name, err := _get_unicode(column_name)
+		if err != nil {
+			return err
+		}
+		*pb = append(*pb, name)
 	}
 
 	// *pb = columns_to_get[:] // not used
@@ -86,126 +86,73 @@ func _make_repeated_column_names(pb *[]string, columns_to_get []string) error {
 }
 
 func _make_column_value(pb *ColumnValue, value interface{}) error {
-	// you have to put 'int' under 'bool' in the switch case
-	// because a bool is also a int !!!
-
-	switch value.(type) {
-	case string:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_STRING
-		pb.Type = pcolumn_type
-		pb.VString = NewString(_get_unicode(value.(string)))
-
-	case bool:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_BOOLEAN
-		pb.Type = pcolumn_type
-		pb.VBool = NewBool(value.(bool))
-
-	case int:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_INTEGER
-		pb.Type = pcolumn_type
-		pb.VInt = NewInt64(int64(value.(int)))
-
-	case uint:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_INTEGER
-		pb.Type = pcolumn_type
-		pb.VInt = NewInt64(int64(value.(uint)))
-
-	case int8:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_INTEGER
-		pb.Type = pcolumn_type
-		pb.VInt = NewInt64(int64(value.(int8)))
-
-	case uint8:
+	if v, ok := value.(ColumnType); ok {
+		if v != ColumnType_INF_MIN && v != ColumnType_INF_MAX {
+			return errors.New("don't expect the value of ColumnType")
+		}
 		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_INTEGER
+		*pcolumn_type = v
 		pb.Type = pcolumn_type
-		pb.VInt = NewInt64(int64(value.(uint8)))
+		return nil
+	}
 
-	case int32:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_INTEGER
-		pb.Type = pcolumn_type
-		pb.VInt = NewInt64(int64(value.(int32)))
+	// the Go values are checked by NewColumnValue, e.g. uint64 overflowing int64
+	column_value, err := NewColumnValue(value)
+	if err != nil {
+		return err
+	}
 
-	case uint32:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_INTEGER
-		pb.Type = pcolumn_type
-		pb.VInt = NewInt64(int64(value.(uint32)))
+	return _make_typed_column_value(pb, column_value)
+}
 
-	case int64:
-		pcolumn_type := new(ColumnType)
+func _make_typed_column_value(pb *ColumnValue, column_value OTSColumnValue) error {
+	pcolumn_type := new(ColumnType)
+	switch column_value.Type() {
+	case OTSColumnType_INTEGER:
 		*pcolumn_type = ColumnType_INTEGER
-		pb.Type = pcolumn_type
-		pb.VInt = NewInt64(value.(int64))
+		v, _ := column_value.AsInteger()
+		pb.VInt = NewInt64(v)
 
-	case uint64:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_INTEGER
-		pb.Type = pcolumn_type
-		pb.VInt = NewInt64(int64(value.(uint64)))
+	case OTSColumnType_STRING:
+		*pcolumn_type = ColumnType_STRING
+		v, _ := column_value.AsString()
+		pb.VString = NewString(v)
 
-	case float32:
-		pcolumn_type := new(ColumnType)
-		*pcolumn_type = ColumnType_DOUBLE
-		pb.Type = pcolumn_type
-		pb.VDouble = NewFloat64(float64(value.(float32)))
+	case OTSColumnType_BOOLEAN:
+		*pcolumn_type = ColumnType_BOOLEAN
+		v, _ := column_value.AsBoolean()
+		pb.VBool = NewBool(v)
 
-	case float64:
-		pcolumn_type := new(ColumnType)
+	case OTSColumnType_DOUBLE:
 		*pcolumn_type = ColumnType_DOUBLE
-		pb.Type = pcolumn_type
-		pb.VDouble = NewFloat64(value.(float64))
+		v, _ := column_value.AsDouble()
+		pb.VDouble = NewFloat64(v)
 
-	case []byte:
-		pcolumn_type := new(ColumnType)
+	case OTSColumnType_BINARY:
 		*pcolumn_type = ColumnType_BINARY
-		pb.Type = pcolumn_type
-		pb.VBinary = value.([]byte)
-
-	case ColumnType:
-		v := value.(ColumnType)
-		if v == ColumnType_INF_MIN {
-			pcolumn_type := new(ColumnType)
-			*pcolumn_type = ColumnType_INF_MIN
-			pb.Type = pcolumn_type
-		} else if v == ColumnType_INF_MAX {
-			pcolumn_type := new(ColumnType)
-			*pcolumn_type = ColumnType_INF_MAX
-			pb.Type = pcolumn_type
-		} else {
-			return errors.New("don't expect the value of ColumnType")
-		}
+		pb.VBinary, _ = column_value.AsBinary()
 
-	case OTS_INF_MIN:
-		pcolumn_type := new(ColumnType)
+	case OTSColumnType_INF_MIN_NAME:
 		*pcolumn_type = ColumnType_INF_MIN
-		pb.Type = pcolumn_type
 
-	case OTS_INF_MAX:
-		pcolumn_type := new(ColumnType)
+	case OTSColumnType_INF_MAX_NAME:
 		*pcolumn_type = ColumnType_INF_MAX
-		pb.Type = pcolumn_type
 
 	default:
-		return errors.New(fmt.Sprintf("expect string, bool, (u)int, (u)int8, (u)int16, (u)int32, (u)int64, (u)float32 or (u)float64 for colum value, not %v", reflect.TypeOf(value)))
+		return errors.New(fmt.Sprintf("invalid column value type: %s", column_value.Type()))
 	}
+	pb.Type = pcolumn_type
 
 	return nil
 }
 
-func _get_column_type(type_str string) ColumnType {
+func _get_column_type(type_str string) (ColumnType, error) {
 	v, ok := ColumnType_value[type_str]
-	if ok {
-		return ColumnType(v)
-	} else {
-		panic(errors.New(fmt.Sprintf("column_type should be one of [INF_MIN, INF_MAX, INTEGER, STRING, BOOLEAN, DOUBLE, BINARY], not %s", type_str)))
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("column_type should be one of [INF_MIN, INF_MAX, INTEGER, STRING, BOOLEAN, DOUBLE, BINARY], not %s", type_str))
 	}
+
+	return ColumnType(v), nil
 }
 
 func _make_condition(pb *Condition, condition interface{}) error {
@@ -215,43 +162,216 @@ func _make_condition(pb *Condition, condition interface{}) error {
 	case *Condition:
 		*pb = *condition.(*Condition)
 	case string:
-		exp := condition.(string)
-		if v, ok := RowExistenceExpectation_value[exp]; ok {
-			item := new(RowExistenceExpectation)
-			*item = RowExistenceExpectation(v)
-			pb.RowExistence = item
-		} else {
-			return errors.New(fmt.Sprintf("condition value should be one of [IGNORE(0), EXPECT_EXIST(1), EXPECT_NOT_EXIST(2)], not %v", exp))
+		row_existence, err := _get_row_existence(condition.(string))
+		if err != nil {
+			return err
+		}
+		pb.RowExistence = row_existence
+	case OTSCondition:
+		return _make_ots_condition(pb, condition.(OTSCondition))
+	case *OTSCondition:
+		if condition.(*OTSCondition) == nil {
+			return errors.New("condition should not be nil")
 		}
+		return _make_ots_condition(pb, *condition.(*OTSCondition))
 	default:
-		return errors.New(fmt.Sprintf("condition should be one of [Condition, *Condition or string], not %v", reflect.TypeOf(condition)))
+		return errors.New(fmt.Sprintf("condition should be one of [Condition, *Condition, string or OTSCondition], not %v", reflect.TypeOf(condition)))
 	}
 
 	return nil
 }
 
-func _get_condition(condition_str string) Condition {
-	if v, ok := RowExistenceExpectation_value[condition_str]; ok {
-		item := new(RowExistenceExpectation)
-		*item = RowExistenceExpectation(v)
-		conditon := Condition{}
-		conditon.RowExistence = item
-	} else {
-		panic(errors.New(fmt.Sprintf("direction should be one of [IGNORE, EXPECT_EXIST, EXPECT_NOT_EXIST], not %s", condition_str)))
+func _make_ots_condition(pb *Condition, condition OTSCondition) error {
+	row_existence, err := _get_row_existence(condition.RowExistence)
+	if err != nil {
+		return err
+	}
+	pb.RowExistence = row_existence
+
+	// column condition is optional
+	pb.ColumnCondition, err = _make_filter(condition.ColumnCondition)
+	if err != nil {
+		return err
 	}
 
-	return Condition{}
+	return nil
 }
 
-func _get_direction(direction_str string) *Direction {
+func _get_row_existence(row_existence_str string) (*RowExistenceExpectation, error) {
+	v, ok := RowExistenceExpectation_value[row_existence_str]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("condition value should be one of [IGNORE(0), EXPECT_EXIST(1), EXPECT_NOT_EXIST(2)], not %v", row_existence_str))
+	}
+
+	item := new(RowExistenceExpectation)
+	*item = RowExistenceExpectation(v)
+	return item, nil
+}
+
+func _get_condition(condition_str string) (Condition, error) {
+	row_existence, err := _get_row_existence(condition_str)
+	if err != nil {
+		return Condition{}, err
+	}
+
+	return Condition{RowExistence: row_existence}, nil
+}
+
+func _get_direction(direction_str string) (*Direction, error) {
 	v, ok := Direction_value[direction_str]
-	if ok {
-		dir := new(Direction)
-		*dir = Direction(v)
-		return dir
-	} else {
-		panic(errors.New(fmt.Sprintf("direction should be one of [FORWARD, BACKWARD], not %s", direction_str)))
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("direction should be one of [FORWARD, BACKWARD], not %s", direction_str))
+	}
+
+	dir := new(Direction)
+	*dir = Direction(v)
+	return dir, nil
+}
+
+func _get_comparator_type(comparator_str string) (*ComparatorType, error) {
+	v, ok := ComparatorType_value[comparator_str]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("comparator should be one of [CT_EQUAL, CT_NOT_EQUAL, CT_GREATER_THAN, CT_GREATER_EQUAL, CT_LESS_THAN, CT_LESS_EQUAL], not %s", comparator_str))
 	}
+	comparator := new(ComparatorType)
+	*comparator = ComparatorType(v)
+
+	return comparator, nil
+}
+
+func _get_logical_operator(combinator_str string) (*LogicalOperator, error) {
+	v, ok := LogicalOperator_value[combinator_str]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("combinator should be one of [LO_NOT, LO_AND, LO_OR], not %s", combinator_str))
+	}
+	combinator := new(LogicalOperator)
+	*combinator = LogicalOperator(v)
+
+	return combinator, nil
+}
+
+func _make_relation_condition(pb *ColumnCondition, relation_condition OTSRelationCondition) error {
+	if relation_condition.ColumnName == "" {
+		return errors.New("column_name of relation condition should not be empty")
+	}
+
+	comparator, err := _get_comparator_type(relation_condition.Comparator)
+	if err != nil {
+		return err
+	}
+
+	switch relation_condition.ColumnValue.(type) {
+	case nil:
+		return errors.New(fmt.Sprintf("column_value of relation condition on column %s should not be nil", relation_condition.ColumnName))
+	case ColumnType, OTS_INF_MIN, OTS_INF_MAX:
+		return errors.New(fmt.Sprintf("INF_MIN or INF_MAX is not allowed in relation condition on column %s", relation_condition.ColumnName))
+	}
+
+	relation := new(RelationCondition)
+	relation.Comparator = comparator
+	relation.ColumnName = NewString(relation_condition.ColumnName)
+	relation.ColumnValue = new(ColumnValue)
+	err = _make_column_value(relation.ColumnValue, relation_condition.ColumnValue)
+	if err != nil {
+		return err
+	}
+	relation.PassIfMissing = NewBool(relation_condition.PassIfMissing)
+
+	condition, err := proto.Marshal(relation)
+	if err != nil {
+		return err
+	}
+
+	condition_type := new(ColumnConditionType)
+	*condition_type = ColumnConditionType_CCT_RELATION
+	pb.Type = condition_type
+	pb.Condition = condition
+
+	return nil
+}
+
+func _make_composite_condition(pb *ColumnCondition, composite_condition OTSCompositeCondition) error {
+	combinator, err := _get_logical_operator(composite_condition.Combinator)
+	if err != nil {
+		return err
+	}
+
+	sub_num := len(composite_condition.SubConditions)
+	if *combinator == LogicalOperator_LO_NOT && sub_num != 1 {
+		return errors.New(fmt.Sprintf("LO_NOT should have exactly one sub condition, not %d", sub_num))
+	}
+	if *combinator != LogicalOperator_LO_NOT && sub_num < 2 {
+		return errors.New(fmt.Sprintf("%s should have at least two sub conditions, not %d", composite_condition.Combinator, sub_num))
+	}
+
+	composite := new(CompositeCondition)
+	composite.Combinator = combinator
+	composite.SubConditions = make([]*ColumnCondition, sub_num)
+	for i, v := range composite_condition.SubConditions {
+		item := new(ColumnCondition)
+		err = _make_column_condition(item, v)
+		if err != nil {
+			return err
+		}
+		composite.SubConditions[i] = item
+	}
+
+	condition, err := proto.Marshal(composite)
+	if err != nil {
+		return err
+	}
+
+	condition_type := new(ColumnConditionType)
+	*condition_type = ColumnConditionType_CCT_COMPOSITE
+	pb.Type = condition_type
+	pb.Condition = condition
+
+	return nil
+}
+
+func _make_column_condition(pb *ColumnCondition, column_condition interface{}) error {
+	switch column_condition.(type) {
+	case ColumnCondition:
+		*pb = column_condition.(ColumnCondition)
+	case *ColumnCondition:
+		if column_condition.(*ColumnCondition) == nil {
+			return errors.New("column_condition should not be nil")
+		}
+		*pb = *column_condition.(*ColumnCondition)
+	case OTSRelationCondition:
+		return _make_relation_condition(pb, column_condition.(OTSRelationCondition))
+	case *OTSRelationCondition:
+		if column_condition.(*OTSRelationCondition) == nil {
+			return errors.New("column_condition should not be nil")
+		}
+		return _make_relation_condition(pb, *column_condition.(*OTSRelationCondition))
+	case OTSCompositeCondition:
+		return _make_composite_condition(pb, column_condition.(OTSCompositeCondition))
+	case *OTSCompositeCondition:
+		if column_condition.(*OTSCompositeCondition) == nil {
+			return errors.New("column_condition should not be nil")
+		}
+		return _make_composite_condition(pb, *column_condition.(*OTSCompositeCondition))
+	default:
+		return errors.New(fmt.Sprintf("column_condition should be one of [ColumnCondition, OTSRelationCondition or OTSCompositeCondition], not %v", reflect.TypeOf(column_condition)))
+	}
+
+	return nil
+}
+
+// filter is optional, nil means no filter
+func _make_filter(filter OTSColumnCondition) (*ColumnCondition, error) {
+	if filter == nil {
+		return nil, nil
+	}
+
+	pb := new(ColumnCondition)
+	err := _make_column_condition(pb, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pb, nil
 }
 
 func _make_column_schema(pb *ColumnSchema, schema_tuple interface{}) error {
@@ -260,15 +380,27 @@ func _make_column_schema(pb *ColumnSchema, schema_tuple interface{}) error {
 		schema_name, schema_type := *schema_tuple.(ColumnSchema).Name, *schema_tuple.(ColumnSchema).Type
 		pb.Name = new(string)
 		pb.Type = new(ColumnType)
-		*pb.Name = _get_unicode(schema_name)
+		name, err := _get_unicode(schema_name)
+		if err != nil {
+			return err
+		}
+		*pb.Name = name
 		*pb.Type = schema_type
 	case TupleString:
 		schema_name, schema_type := schema_tuple.(TupleString).GetName(), schema_tuple.(TupleString).GetType()
 		pb.Name = new(string)
 		pb.Type = new(ColumnType)
-		*pb.Name = _get_unicode(schema_name)
+		name, err := _get_unicode(schema_name)
+		if err != nil {
+			return err
+		}
+		*pb.Name = name
 		if v, ok := schema_type.(string); ok {
-			*pb.Type = _get_column_type(v)
+			column_type, err := _get_column_type(v)
+			if err != nil {
+				return err
+			}
+			*pb.Type = column_type
 		} else {
 			return errors.New(fmt.Sprintf("schema_tuple should be (string, string), not (string, %v)", reflect.TypeOf(schema_type)))
 		}
@@ -284,9 +416,17 @@ func _make_column_schema_python(pb *ColumnSchema, schema_tuple TupleString) erro
 	schema_name, schema_type := schema_tuple.GetName(), schema_tuple.GetType()
 	pb.Name = new(string)
 	pb.Type = new(ColumnType)
-	*pb.Name = _get_unicode(schema_name)
+	name, err := _get_unicode(schema_name)
+	if err != nil {
+		return err
+	}
+	*pb.Name = name
 	if v, ok := schema_type.(string); ok {
-		*pb.Type = _get_column_type(v)
+		column_type, err := _get_column_type(v)
+		if err != nil {
+			return err
+		}
+		*pb.Type = column_type
 	} else {
 		return errors.New(fmt.Sprintf("schema_tuple should be (string, string), not (string, %v)", reflect.TypeOf(schema_type)))
 	}
@@ -355,7 +495,11 @@ func _make_columns_with_dict(pb *[]*Column, column_dict interface{}) error {
 		for k, column := range column_dict.([]Column) {
 			item := new(Column)
 			item.Name = new(string)
-			*item.Name = _get_unicode(column.GetName())
+			name, err := _get_unicode(column.GetName())
+			if err != nil {
+				return err
+			}
+			*item.Name = name
 			item.Value = column.GetValue()
 			(*pb)[k] = item
 		}
@@ -368,7 +512,11 @@ func _make_columns_with_dict(pb *[]*Column, column_dict interface{}) error {
 		for k, column := range column_dict.([]*Column) {
 			item := new(Column)
 			item.Name = new(string)
-			*item.Name = _get_unicode((*column).GetName())
+			name, err := _get_unicode((*column).GetName())
+			if err != nil {
+				return err
+			}
+			*item.Name = name
 			item.Value = (*column).GetValue()
 			(*pb)[k] = item
 		}
@@ -383,17 +531,58 @@ func _make_columns_with_dict(pb *[]*Column, column_dict interface{}) error {
 			item := new(Column)
 			item.Name = NewString(name)
 			item.Value = new(ColumnValue)
-			_make_column_value(item.Value, column)
+			if err := _make_column_value(item.Value, column); err != nil {
+				return err
+			}
 			(*pb)[i] = item
 			i++
 		}
 
+	case ListString:
+		*pb = make([]*Column, len(column_dict.(ListString)))
+		for i, column := range column_dict.(ListString) {
+			item := new(Column)
+			item.Name = NewString(column.GetName())
+			item.Value = new(ColumnValue)
+			if err := _make_column_value(item.Value, column.V); err != nil {
+				return err
+			}
+			(*pb)[i] = item
+		}
+
 	default:
-		return errors.New(fmt.Sprintf("type of schema_list is shoud be one of [[]Column []*Column or DictString]. not %v", reflect.TypeOf(column_dict)))
+		return errors.New(fmt.Sprintf("type of schema_list is shoud be one of [[]Column []*Column DictString or ListString]. not %v", reflect.TypeOf(column_dict)))
 	}
 	return nil
 }
 
+// primary key columns in the order of OTSPrimaryKeyInterface, OTSPrimaryKey is sorted by name
+func _make_primary_key(pb *[]*Column, primary_key interface{}) error {
+	switch v := primary_key.(type) {
+	case *OTSPrimaryKey:
+		if v == nil {
+			return errors.New("primary_key should not be nil")
+		}
+	case *OTSOrderedPrimaryKey:
+		if v == nil {
+			return errors.New("primary_key should not be nil")
+		}
+	case OTSPrimaryKey:
+		return _make_columns_with_dict(pb, v.PrimaryKeyList())
+	case OTSOrderedPrimaryKey:
+		return _make_columns_with_dict(pb, ListString(v))
+	case nil:
+		return errors.New("primary_key should not be nil")
+	}
+
+	list, ok := primary_key.(OTSPrimaryKeyInterface)
+	if !ok {
+		return errors.New(fmt.Sprintf("primary_key should be an instance of [OTSPrimaryKey, *OTSPrimaryKey or *OTSOrderedPrimaryKey], not %v", reflect.TypeOf(primary_key)))
+	}
+
+	return _make_columns_with_dict(pb, list.PrimaryKeyList())
+}
+
 func _make_update_of_attribute_columns_with_dict(pb *[]*ColumnUpdate, column_dict interface{}) error {
 	switch column_dict.(type) {
 	case []ColumnUpdate:
@@ -406,7 +595,11 @@ func _make_update_of_attribute_columns_with_dict(pb *[]*ColumnUpdate, column_dic
 			item.Type = new(OperationType)
 			*item.Type = column_update.GetType()
 			item.Name = new(string)
-			*item.Name = _get_unicode(column_update.GetName())
+			name, err := _get_unicode(column_update.GetName())
+			if err != nil {
+				return err
+			}
+			*item.Name = name
 			item.Value = new(ColumnValue)
 			item.Value = column_update.GetValue()
 			(*pb)[k] = item
@@ -421,7 +614,11 @@ func _make_update_of_attribute_columns_with_dict(pb *[]*ColumnUpdate, column_dic
 			item.Type = new(OperationType)
 			*item.Type = (*column_update).GetType()
 			item.Name = new(string)
-			*item.Name = _get_unicode((*column_update).GetName())
+			name, err := _get_unicode((*column_update).GetName())
+			if err != nil {
+				return err
+			}
+			*item.Name = name
 			item.Value = new(ColumnValue)
 			item.Value = (*column_update).GetValue()
 			(*pb)[k] = item
@@ -439,9 +636,15 @@ func _make_update_of_attribute_columns_with_dict(pb *[]*ColumnUpdate, column_dic
 						item.Type = new(OperationType)
 						*item.Type = OperationType_PUT
 						item.Name = new(string)
-						*item.Name = _get_unicode(k)
+						name, err := _get_unicode(k)
+						if err != nil {
+							return err
+						}
+						*item.Name = name
 						item.Value = new(ColumnValue)
-						_make_column_value(item.Value, v)
+						if err := _make_column_value(item.Value, v); err != nil {
+							return err
+						}
 						*pb = append(*pb, item)
 					}
 				case OTSColumnsToPut:
@@ -450,9 +653,15 @@ func _make_update_of_attribute_columns_with_dict(pb *[]*ColumnUpdate, column_dic
 						item.Type = new(OperationType)
 						*item.Type = OperationType_PUT
 						item.Name = new(string)
-						*item.Name = _get_unicode(k)
+						name, err := _get_unicode(k)
+						if err != nil {
+							return err
+						}
+						*item.Name = name
 						item.Value = new(ColumnValue)
-						_make_column_value(item.Value, v)
+						if err := _make_column_value(item.Value, v); err != nil {
+							return err
+						}
 						*pb = append(*pb, item)
 					}
 				default:
@@ -467,7 +676,11 @@ func _make_update_of_attribute_columns_with_dict(pb *[]*ColumnUpdate, column_dic
 						item.Type = new(OperationType)
 						*item.Type = OperationType_DELETE
 						item.Name = new(string)
-						*item.Name = _get_unicode(v)
+						name, err := _get_unicode(v)
+						if err != nil {
+							return err
+						}
+						*item.Name = name
 						*pb = append(*pb, item)
 					}
 				case OTSColumnsToDelete:
@@ -476,7 +689,11 @@ func _make_update_of_attribute_columns_with_dict(pb *[]*ColumnUpdate, column_dic
 						item.Type = new(OperationType)
 						*item.Type = OperationType_DELETE
 						item.Name = new(string)
-						*item.Name = _get_unicode(v)
+						name, err := _get_unicode(v)
+						if err != nil {
+							return err
+						}
+						*item.Name = name
 						*pb = append(*pb, item)
 					}
 				default:
@@ -498,16 +715,24 @@ func _make_table_meta(pb *TableMeta, table_meta interface{}) error {
 	switch table_meta.(type) {
 	case TableMeta:
 		pb.TableName = new(string)
-		*pb.TableName = _get_unicode(*table_meta.(TableMeta).TableName)
+		name, err := _get_unicode(*table_meta.(TableMeta).TableName)
+		if err != nil {
+			return err
+		}
+		*pb.TableName = name
 		primary_key := new([]*ColumnSchema)
-		err := _make_schemas_with_list(primary_key, table_meta.(TableMeta).PrimaryKey)
+		err = _make_schemas_with_list(primary_key, table_meta.(TableMeta).PrimaryKey)
 		if err != nil {
 			return err
 		}
 		pb.PrimaryKey = (*primary_key)[:]
 	case TupleString:
 		pb.TableName = new(string)
-		*pb.TableName = _get_unicode(table_meta.(TupleString).GetName())
+		name, err := _get_unicode(table_meta.(TupleString).GetName())
+		if err != nil {
+			return err
+		}
+		*pb.TableName = name
 		if v, ok := table_meta.(TupleString).V.([]TupleString); ok {
 			primary_key := new([]*ColumnSchema)
 			err := _make_schemas_with_list(primary_key, v)
@@ -548,12 +773,28 @@ func _make_capacity_unit(pb *CapacityUnit, capacity_unit interface{}) error {
 		if capacity_unit.(CapacityUnit).Read == nil || capacity_unit.(CapacityUnit).Write == nil {
 			return errors.New("both of read and write of CapacityUnit are required")
 		}
-		pb.Read = NewInt32(_get_int32(*capacity_unit.(CapacityUnit).Read))
-		pb.Write = NewInt32(_get_int32(*capacity_unit.(CapacityUnit).Write))
+		read, err := _get_int32(*capacity_unit.(CapacityUnit).Read)
+		if err != nil {
+			return err
+		}
+		pb.Read = NewInt32(read)
+		write, err := _get_int32(*capacity_unit.(CapacityUnit).Write)
+		if err != nil {
+			return err
+		}
+		pb.Write = NewInt32(write)
 
 	case OTSCapacityUnit:
-		pb.Read = NewInt32(_get_int32(capacity_unit.(OTSCapacityUnit).Read))
-		pb.Write = NewInt32(_get_int32(capacity_unit.(OTSCapacityUnit).Write))
+		read, err := _get_int32(capacity_unit.(OTSCapacityUnit).Read)
+		if err != nil {
+			return err
+		}
+		pb.Read = NewInt32(read)
+		write, err := _get_int32(capacity_unit.(OTSCapacityUnit).Write)
+		if err != nil {
+			return err
+		}
+		pb.Write = NewInt32(write)
 	}
 
 	return nil
@@ -592,16 +833,32 @@ func _make_update_capacity_unit(pb *CapacityUnit, capacity_unit interface{}) err
 		}
 
 		if capacity_unit.(CapacityUnit).Read != nil {
-			pb.Read = NewInt32(_get_int32(*capacity_unit.(CapacityUnit).Read))
+			read, err := _get_int32(*capacity_unit.(CapacityUnit).Read)
+			if err != nil {
+				return err
+			}
+			pb.Read = NewInt32(read)
 		}
 
 		if capacity_unit.(CapacityUnit).Write != nil {
-			pb.Write = NewInt32(_get_int32(*capacity_unit.(CapacityUnit).Write))
+			write, err := _get_int32(*capacity_unit.(CapacityUnit).Write)
+			if err != nil {
+				return err
+			}
+			pb.Write = NewInt32(write)
 		}
 
 	case OTSCapacityUnit:
-		pb.Read = NewInt32(_get_int32(capacity_unit.(OTSCapacityUnit).Read))
-		pb.Write = NewInt32(_get_int32(capacity_unit.(OTSCapacityUnit).Write))
+		read, err := _get_int32(capacity_unit.(OTSCapacityUnit).Read)
+		if err != nil {
+			return err
+		}
+		pb.Read = NewInt32(read)
+		write, err := _get_int32(capacity_unit.(OTSCapacityUnit).Write)
+		if err != nil {
+			return err
+		}
+		pb.Write = NewInt32(write)
 	}
 
 	return nil
@@ -640,12 +897,17 @@ func _make_batch_get_row(pb *BatchGetRowRequest, batch_list interface{}) error {
 		for i, v := range batch_list.([]TableInBatchGetRowRequest) {
 			table_item := new(TableInBatchGetRowRequest)
 			// table_name
-			table_name := _get_unicode(*v.TableName)
+			table_name, err := _get_unicode(*v.TableName)
+			if err != nil {
+				return err
+			}
 			table_item.TableName = new(string)
 			*table_item.TableName = table_name
 			// columns_to_get
 			columns_to_get := new([]string)
-			_make_repeated_column_names(columns_to_get, v.ColumnsToGet)
+			if err := _make_repeated_column_names(columns_to_get, v.ColumnsToGet); err != nil {
+				return err
+			}
 			table_item.ColumnsToGet = *columns_to_get
 			// row_list
 			table_item.Rows = make([]*RowInBatchGetRowRequest, len(v.Rows))
@@ -668,12 +930,17 @@ func _make_batch_get_row(pb *BatchGetRowRequest, batch_list interface{}) error {
 		for i, v := range batch_list.([]*TableInBatchGetRowRequest) {
 			table_item := new(TableInBatchGetRowRequest)
 			// table_name
-			table_name := _get_unicode(*(*v).TableName)
+			table_name, err := _get_unicode(*(*v).TableName)
+			if err != nil {
+				return err
+			}
 			table_item.TableName = new(string)
 			*table_item.TableName = table_name
 			// columns_to_get
 			columns_to_get := new([]string)
-			_make_repeated_column_names(columns_to_get, (*v).ColumnsToGet)
+			if err := _make_repeated_column_names(columns_to_get, (*v).ColumnsToGet); err != nil {
+				return err
+			}
 			table_item.ColumnsToGet = *columns_to_get
 			// row_list
 			table_item.Rows = make([]*RowInBatchGetRowRequest, len(v.Rows))
@@ -698,14 +965,30 @@ func _make_batch_get_row(pb *BatchGetRowRequest, batch_list interface{}) error {
 			table_item.TableName = NewString(v.TableName)
 			// columns_to_get
 			columns_to_get := new([]string)
-			_make_repeated_column_names(columns_to_get, v.ColumnsToGet)
+			if err := _make_repeated_column_names(columns_to_get, v.ColumnsToGet); err != nil {
+				return err
+			}
 			table_item.ColumnsToGet = *columns_to_get
+			// filter
+			filter, err := _make_filter(v.Filter)
+			if err != nil {
+				return err
+			}
+			table_item.Filter = filter
 			// row_list
 			table_item.Rows = make([]*RowInBatchGetRowRequest, len(v.Rows))
 			for i1, v1 := range v.Rows {
 				row := new(RowInBatchGetRowRequest)
 				primary_key := new([]*Column)
-				err := _make_columns_with_dict(primary_key, DictString(v1))
+				var pk interface{} = v1
+				if v.SchemaOfPrimaryKey != nil {
+					ordered, err := NewPrimaryKeyFromSchema(v.SchemaOfPrimaryKey, v1)
+					if err != nil {
+						return errors.New(fmt.Sprintf("row %d of table %s: %s", i1, v.TableName, err))
+					}
+					pk = ordered
+				}
+				err := _make_primary_key(primary_key, pk)
 				if err != nil {
 					return err
 				}
@@ -764,12 +1047,12 @@ func _make_put_row_item(pb *PutRowInBatchWriteRowRequest, put_row_item interface
 
 	case OTSPutRowItem:
 		pb.Condition = new(Condition)
-		err := _make_condition(pb.Condition, put_row_item.(OTSPutRowItem).Condition)
+		err := _make_ots_condition(pb.Condition, OTSCondition{RowExistence: put_row_item.(OTSPutRowItem).Condition, ColumnCondition: put_row_item.(OTSPutRowItem).ColumnCondition})
 		if err != nil {
 			return err
 		}
 		primary_key := new([]*Column)
-		err = _make_columns_with_dict(primary_key, DictString(put_row_item.(OTSPutRowItem).PrimaryKey))
+		err = _make_primary_key(primary_key, put_row_item.(OTSPutRowItem).PrimaryKey)
 		if err != nil {
 			return err
 		}
@@ -830,12 +1113,12 @@ func _make_update_row_item(pb *UpdateRowInBatchWriteRowRequest, update_row_item
 
 	case OTSUpdateRowItem:
 		pb.Condition = new(Condition)
-		err := _make_condition(pb.Condition, update_row_item.(OTSUpdateRowItem).Condition)
+		err := _make_ots_condition(pb.Condition, OTSCondition{RowExistence: update_row_item.(OTSUpdateRowItem).Condition, ColumnCondition: update_row_item.(OTSUpdateRowItem).ColumnCondition})
 		if err != nil {
 			return err
 		}
 		primary_key := new([]*Column)
-		err = _make_columns_with_dict(primary_key, DictString(update_row_item.(OTSUpdateRowItem).PrimaryKey))
+		err = _make_primary_key(primary_key, update_row_item.(OTSUpdateRowItem).PrimaryKey)
 		if err != nil {
 			return err
 		}
@@ -884,12 +1167,12 @@ func _make_delete_row_item(pb *DeleteRowInBatchWriteRowRequest, delete_row_item
 
 	case OTSDeleteRowItem:
 		pb.Condition = new(Condition)
-		err := _make_condition(pb.Condition, delete_row_item.(OTSDeleteRowItem).Condition)
+		err := _make_ots_condition(pb.Condition, OTSCondition{RowExistence: delete_row_item.(OTSDeleteRowItem).Condition, ColumnCondition: delete_row_item.(OTSDeleteRowItem).ColumnCondition})
 		if err != nil {
 			return err
 		}
 		primary_key := new([]*Column)
-		err = _make_columns_with_dict(primary_key, DictString(delete_row_item.(OTSDeleteRowItem).PrimaryKey))
+		err = _make_primary_key(primary_key, delete_row_item.(OTSDeleteRowItem).PrimaryKey)
 		if err != nil {
 			return err
 		}
@@ -910,7 +1193,10 @@ func _make_batch_write_row(pb *BatchWriteRowRequest, batch_list interface{}) err
 		for i, v := range batch_list.([]TableInBatchWriteRowRequest) {
 			table_item := new(TableInBatchWriteRowRequest)
 			// table_name
-			table_name := _get_unicode(*v.TableName)
+			table_name, err := _get_unicode(*v.TableName)
+			if err != nil {
+				return err
+			}
 			table_item.TableName = new(string)
 			*table_item.TableName = table_name
 
@@ -955,7 +1241,10 @@ func _make_batch_write_row(pb *BatchWriteRowRequest, batch_list interface{}) err
 		for i, v := range batch_list.([]*TableInBatchWriteRowRequest) {
 			table_item := new(TableInBatchWriteRowRequest)
 			// table_name
-			table_name := _get_unicode(*v.TableName)
+			table_name, err := _get_unicode(*v.TableName)
+			if err != nil {
+				return err
+			}
 			table_item.TableName = new(string)
 			*table_item.TableName = table_name
 
@@ -1000,7 +1289,10 @@ func _make_batch_write_row(pb *BatchWriteRowRequest, batch_list interface{}) err
 		for i, v := range batch_list.(OTSBatchWriteRowRequest) {
 			table_item := new(TableInBatchWriteRowRequest)
 			// table_name
-			table_name := _get_unicode(v.TableName)
+			table_name, err := _get_unicode(v.TableName)
+			if err != nil {
+				return err
+			}
 			table_item.TableName = new(string)
 			*table_item.TableName = table_name
 
@@ -1068,11 +1360,11 @@ func TestEncoder() {
 	// _make_column_value(&pb, 123)
 	// fmt.Println(pb, pb.GetVInt())
 
-	// func _get_column_type(type_str string) ColumnType
-	// pb := _get_column_type("INF_MIN")
-	// fmt.Println(pb)
-	// pb = _get_column_type("tobyzxj")
-	// fmt.Println(pb)
+	// func _get_column_type(type_str string) (ColumnType, error)
+	// pb, err := _get_column_type("INF_MIN")
+	// fmt.Println(pb, err)
+	// pb, err = _get_column_type("tobyzxj")
+	// fmt.Println(pb, err)
 
 	// func _make_condition(pb *Condition, condition interface{}) error
 	// [1]
@@ -1944,8 +2236,6 @@ func _encode_create_table(table_meta *OTSTableMeta, reserved_throughput *OTSRese
 		return nil, err
 	}
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
@@ -1953,16 +2243,12 @@ func _encode_delete_table(table_name string) (req *DeleteTableRequest, err error
 	pb := new(DeleteTableRequest)
 	pb.TableName = NewString(table_name)
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
 func _encode_list_table() (req *ListTableRequest, err error) {
 	pb := new(ListTableRequest)
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
@@ -1975,8 +2261,6 @@ func _encode_update_table(table_name string, reserved_throughput *OTSReservedThr
 		return nil, err
 	}
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
@@ -1984,16 +2268,14 @@ func _encode_describe_table(table_name string) (req *DescribeTableRequest, err e
 	pb := new(DescribeTableRequest)
 	pb.TableName = NewString(table_name)
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
-func _encode_get_row(table_name string, primary_key *OTSPrimaryKey, columns_to_get *OTSColumnsToGet) (req *GetRowRequest, err error) {
+func _encode_get_row(table_name string, primary_key OTSPrimaryKeyInterface, columns_to_get *OTSColumnsToGet, filter OTSColumnCondition) (req *GetRowRequest, err error) {
 	pb := new(GetRowRequest)
 	pb.TableName = NewString(table_name)
 	_primary_key := new([]*Column)
-	err = _make_columns_with_dict(_primary_key, DictString(*primary_key))
+	err = _make_primary_key(_primary_key, primary_key)
 	if err != nil {
 		return nil, err
 	}
@@ -2010,12 +2292,15 @@ func _encode_get_row(table_name string, primary_key *OTSPrimaryKey, columns_to_g
 		pb.ColumnsToGet = nil
 	}
 
-	print_request_message(pb)
+	pb.Filter, err = _make_filter(filter)
+	if err != nil {
+		return nil, err
+	}
 
 	return pb, nil
 }
 
-func _encode_put_row(table_name string, condition interface{}, primary_key *OTSPrimaryKey, attribute_columns *OTSAttribute) (req *PutRowRequest, err error) {
+func _encode_put_row(table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface, attribute_columns *OTSAttribute) (req *PutRowRequest, err error) {
 	pb := new(PutRowRequest)
 	pb.TableName = NewString(table_name)
 	pb.Condition = new(Condition)
@@ -2025,7 +2310,7 @@ func _encode_put_row(table_name string, condition interface{}, primary_key *OTSP
 	}
 
 	_primary_key := new([]*Column)
-	err = _make_columns_with_dict(_primary_key, DictString(*primary_key))
+	err = _make_primary_key(_primary_key, primary_key)
 	if err != nil {
 		return nil, err
 	}
@@ -2038,12 +2323,10 @@ func _encode_put_row(table_name string, condition interface{}, primary_key *OTSP
 	}
 	pb.AttributeColumns = *_attribute_columns
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
-func _encode_update_row(table_name string, condition interface{}, primary_key *OTSPrimaryKey, update_of_attribute_columns *OTSUpdateOfAttribute) (req *UpdateRowRequest, err error) {
+func _encode_update_row(table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface, update_of_attribute_columns *OTSUpdateOfAttribute) (req *UpdateRowRequest, err error) {
 	pb := new(UpdateRowRequest)
 	pb.TableName = NewString(table_name)
 	pb.Condition = new(Condition)
@@ -2053,7 +2336,7 @@ func _encode_update_row(table_name string, condition interface{}, primary_key *O
 	}
 
 	_primary_key := new([]*Column)
-	err = _make_columns_with_dict(_primary_key, DictString(*primary_key))
+	err = _make_primary_key(_primary_key, primary_key)
 	if err != nil {
 		return nil, err
 	}
@@ -2066,12 +2349,10 @@ func _encode_update_row(table_name string, condition interface{}, primary_key *O
 	}
 	pb.AttributeColumns = *_update_of_attribute_columns
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
-func _encode_delete_row(table_name string, condition interface{}, primary_key *OTSPrimaryKey) (req *DeleteRowRequest, err error) {
+func _encode_delete_row(table_name string, condition interface{}, primary_key OTSPrimaryKeyInterface) (req *DeleteRowRequest, err error) {
 	pb := new(DeleteRowRequest)
 	pb.TableName = NewString(table_name)
 	pb.Condition = new(Condition)
@@ -2081,14 +2362,12 @@ func _encode_delete_row(table_name string, condition interface{}, primary_key *O
 	}
 
 	_primary_key := new([]*Column)
-	err = _make_columns_with_dict(_primary_key, DictString(*primary_key))
+	err = _make_primary_key(_primary_key, primary_key)
 	if err != nil {
 		return nil, err
 	}
 	pb.PrimaryKey = *_primary_key
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
@@ -2099,8 +2378,6 @@ func _encode_batch_get_row(batch_list *OTSBatchGetRowRequest) (req *BatchGetRowR
 		return nil, err
 	}
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
@@ -2111,29 +2388,31 @@ func _encode_batch_write_row(batch_list *OTSBatchWriteRowRequest) (req *BatchWri
 		return nil, err
 	}
 
-	print_request_message(pb)
-
 	return pb, nil
 }
 
 func _encode_get_range(table_name string, direction string,
-	inclusive_start_primary_key *OTSPrimaryKey,
-	exclusive_end_primary_key *OTSPrimaryKey,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
 	columns_to_get *OTSColumnsToGet,
-	limit int32) (req *GetRangeRequest, err error) {
+	limit int32,
+	filter OTSColumnCondition) (req *GetRangeRequest, err error) {
 	pb := new(GetRangeRequest)
 	pb.TableName = NewString(table_name)
-	pb.Direction = _get_direction(direction)
+	pb.Direction, err = _get_direction(direction)
+	if err != nil {
+		return nil, err
+	}
 
 	_start_primary_key := new([]*Column)
-	err = _make_columns_with_dict(_start_primary_key, DictString(*inclusive_start_primary_key))
+	err = _make_primary_key(_start_primary_key, inclusive_start_primary_key)
 	if err != nil {
 		return nil, err
 	}
 	pb.InclusiveStartPrimaryKey = *_start_primary_key
 
 	_end_primary_key := new([]*Column)
-	err = _make_columns_with_dict(_end_primary_key, DictString(*exclusive_end_primary_key))
+	err = _make_primary_key(_end_primary_key, exclusive_end_primary_key)
 	if err != nil {
 		return nil, err
 	}
@@ -2156,7 +2435,10 @@ func _encode_get_range(table_name string, direction string,
 		pb.ColumnsToGet = nil
 	}
 
-	print_request_message(pb)
+	pb.Filter, err = _make_filter(filter)
+	if err != nil {
+		return nil, err
+	}
 
 	return pb, nil
 }
@@ -2164,8 +2446,19 @@ func _encode_get_range(table_name string, direction string,
 // request encode for ots2
 func EncodeRequest(api_name string, args ...interface{}) (req []reflect.Value, err error) {
 	if _, ok := api_encode_map[api_name]; !ok {
-		return nil, errors.New(fmt.Sprintf("No PB encode method for API %s" + api_name))
+		return nil, fmt.Errorf("No PB encode method for API %s", api_name)
 	}
+	// the helpers return errors, reflect panics if args do not match the encoder, return it as an error
+	defer func() {
+		if r := recover(); r != nil {
+			req = nil
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
 
 	req, err = api_encode_map.Call(api_name, args...)
 	if err != nil {
@@ -12,6 +12,7 @@ import (
 
 	. "github.com/GiterLab/goots/otstype"
 	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
 )
 
 func Test_encode_create_table(t *testing.T) {
@@ -20,13 +21,13 @@ func Test_encode_create_table(t *testing.T) {
 	table_meta := OTSTableMeta{
 		TableName: "myTable",
 		SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{
-			"gid": "INTEGER",
-			"uid": "INTEGER",
+			{K: "gid", V: "INTEGER"},
+			{K: "uid", V: "INTEGER"},
 		},
 	}
 
 	reserved_throughput := OTSReservedThroughput{
-		OTSCapacityUnit{0, 0},
+		CapacityUnit: OTSCapacityUnit{Read: 0, Write: 0},
 	}
 
 	req, _ := _encode_create_table(&table_meta, &reserved_throughput)
@@ -60,7 +61,7 @@ func Test_encode_update_table(t *testing.T) {
 	t.Log("testing _encode_update_table...")
 	// ----
 	reserved_throughput := OTSReservedThroughput{
-		OTSCapacityUnit{0, 0},
+		CapacityUnit: OTSCapacityUnit{Read: 0, Write: 0},
 	}
 	req, _ := _encode_update_table("myTable", &reserved_throughput)
 	t.Log("UpdateTableRequest:", req)
@@ -75,13 +76,13 @@ func Test_EncodeRequest(t *testing.T) {
 	table_meta := OTSTableMeta{
 		TableName: "myTable",
 		SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{
-			"gid": "INTEGER",
-			"uid": "INTEGER",
+			{K: "gid", V: "INTEGER"},
+			{K: "uid", V: "INTEGER"},
 		},
 	}
 
 	reserved_throughput := OTSReservedThroughput{
-		OTSCapacityUnit{0, 0},
+		CapacityUnit: OTSCapacityUnit{Read: 0, Write: 0},
 	}
 
 	req, err := EncodeRequest("CreateTable", &table_meta, &reserved_throughput)
@@ -128,3 +129,189 @@ func Test_EncodeRequest(t *testing.T) {
 	t.Log("test EncodeRequest ok!")
 	// t.Fail()
 }
+
+func Test_encode_get_row_with_filter(t *testing.T) {
+	primary_key := OTSPrimaryKey{
+		"gid": 1,
+		"uid": 101,
+	}
+	filter := NewAndCondition(
+		NewRelationCondition("age", OTSComparatorType_GREATER_THAN, 18, false),
+		NewNotCondition(NewRelationCondition("name", OTSComparatorType_EQUAL, "张三", true)),
+	)
+
+	req, err := _encode_get_row("myTable", &primary_key, nil, filter)
+	if err != nil {
+		t.Fatalf("_encode_get_row error: %s", err)
+	}
+	if req.GetFilter().GetType() != ColumnConditionType_CCT_COMPOSITE {
+		t.Fatalf("filter type should be CCT_COMPOSITE, not %s", req.GetFilter().GetType())
+	}
+
+	and := new(CompositeCondition)
+	if err = proto.Unmarshal(req.GetFilter().GetCondition(), and); err != nil {
+		t.Fatalf("unmarshal CompositeCondition error: %s", err)
+	}
+	if and.GetCombinator() != LogicalOperator_LO_AND || len(and.GetSubConditions()) != 2 {
+		t.Fatalf("unexpected CompositeCondition: %v", and)
+	}
+
+	age := new(RelationCondition)
+	if err = proto.Unmarshal(and.GetSubConditions()[0].GetCondition(), age); err != nil {
+		t.Fatalf("unmarshal RelationCondition error: %s", err)
+	}
+	if age.GetColumnName() != "age" || age.GetComparator() != ComparatorType_CT_GREATER_THAN ||
+		age.GetColumnValue().GetVInt() != 18 || age.GetPassIfMissing() {
+		t.Fatalf("unexpected RelationCondition: %v", age)
+	}
+
+	not := new(CompositeCondition)
+	if err = proto.Unmarshal(and.GetSubConditions()[1].GetCondition(), not); err != nil {
+		t.Fatalf("unmarshal CompositeCondition error: %s", err)
+	}
+	name := new(RelationCondition)
+	if err = proto.Unmarshal(not.GetSubConditions()[0].GetCondition(), name); err != nil {
+		t.Fatalf("unmarshal RelationCondition error: %s", err)
+	}
+	if not.GetCombinator() != LogicalOperator_LO_NOT || name.GetColumnValue().GetVString() != "张三" || !name.GetPassIfMissing() {
+		t.Fatalf("unexpected NOT condition: %v, %v", not, name)
+	}
+}
+
+func Test_encode_get_range_without_filter(t *testing.T) {
+	start := OTSPrimaryKey{"gid": 1, "uid": OTSColumnType_INF_MIN}
+	end := OTSPrimaryKey{"gid": 4, "uid": OTSColumnType_INF_MAX}
+
+	req, err := EncodeRequest("GetRange", "myTable", OTSDirection_FORWARD, &start, &end, (*OTSColumnsToGet)(nil), int32(100), nil)
+	if err != nil {
+		t.Fatalf("EncodeRequest error: %s", err)
+	}
+	if req[1].Interface() != nil {
+		t.Fatalf("EncodeRequest error: %v", req[1].Interface())
+	}
+	if req[0].Interface().(*GetRangeRequest).Filter != nil {
+		t.Fatal("filter should be nil")
+	}
+}
+
+func Test_encode_helpers_invalid(t *testing.T) {
+	if _, err := _get_unicode(1); err == nil {
+		t.Fatal("int should not be a string")
+	}
+	if _, err := _get_int32(int(INT32_MAX) + 1); err == nil {
+		t.Fatal("int overflowing int32 should fail")
+	}
+	if _, err := _get_int32("1"); err == nil {
+		t.Fatal("string should not be an int32")
+	}
+	if _, err := _get_column_type("DATE"); err == nil {
+		t.Fatal("unknown column type should fail")
+	}
+	if _, err := _get_condition("EXIST"); err == nil {
+		t.Fatal("unknown condition should fail")
+	}
+
+	// invalid parameters are returned as errors
+	start := OTSPrimaryKey{"gid": 1}
+	end := OTSPrimaryKey{"gid": 4}
+	req, err := EncodeRequest("GetRange", "myTable", "UP", &start, &end, (*OTSColumnsToGet)(nil), int32(100), nil)
+	if err != nil || req[1].Interface() == nil {
+		t.Fatalf("unknown direction should fail, not %v, %v", req, err)
+	}
+	req, err = EncodeRequest("CreateTable", &OTSTableMeta{TableName: "myTable", SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{{K: "gid", V: "DATE"}}},
+		&OTSReservedThroughput{CapacityUnit: OTSCapacityUnit{Read: 100, Write: 100}})
+	if err != nil || req[1].Interface() == nil {
+		t.Fatalf("unknown column type should fail, not %v, %v", req, err)
+	}
+}
+
+func Test_make_column_condition_invalid(t *testing.T) {
+	pb := new(ColumnCondition)
+	if err := _make_column_condition(pb, NewAndCondition(NewRelationCondition("age", OTSComparatorType_EQUAL, 1, false))); err == nil {
+		t.Fatal("LO_AND with one sub condition should fail")
+	}
+	if err := _make_column_condition(pb, NewRelationCondition("age", "CT_LIKE", 1, false)); err == nil {
+		t.Fatal("unknown comparator should fail")
+	}
+	if err := _make_column_condition(pb, NewRelationCondition("age", OTSComparatorType_EQUAL, OTSColumnType_INF_MAX, false)); err == nil {
+		t.Fatal("INF_MAX in relation condition should fail")
+	}
+}
+
+func Test_encode_update_row_with_column_condition(t *testing.T) {
+	primary_key := OTSPrimaryKey{"gid": 1, "uid": 101}
+	update_of_attribute_columns := OTSUpdateOfAttribute{
+		OTSOperationType_PUT: OTSColumnsToPut{"version": 8},
+	}
+	condition := NewCondition(OTSCondition_EXPECT_EXIST,
+		NewRelationCondition("version", OTSComparatorType_EQUAL, 7, false))
+
+	req, err := _encode_update_row("myTable", condition, &primary_key, &update_of_attribute_columns)
+	if err != nil {
+		t.Fatalf("_encode_update_row error: %s", err)
+	}
+	if req.GetCondition().GetRowExistence() != RowExistenceExpectation_EXPECT_EXIST {
+		t.Fatalf("row existence should be EXPECT_EXIST, not %s", req.GetCondition().GetRowExistence())
+	}
+	if req.GetCondition().GetColumnCondition().GetType() != ColumnConditionType_CCT_RELATION {
+		t.Fatalf("column condition type should be CCT_RELATION, not %s", req.GetCondition().GetColumnCondition().GetType())
+	}
+
+	version := new(RelationCondition)
+	if err = proto.Unmarshal(req.GetCondition().GetColumnCondition().GetCondition(), version); err != nil {
+		t.Fatalf("unmarshal RelationCondition error: %s", err)
+	}
+	if version.GetColumnName() != "version" || version.GetColumnValue().GetVInt() != 7 {
+		t.Fatalf("unexpected RelationCondition: %v", version)
+	}
+
+	// row existence only
+	pb := new(Condition)
+	if err = _make_condition(pb, OTSCondition_IGNORE); err != nil {
+		t.Fatalf("_make_condition error: %s", err)
+	}
+	if pb.GetRowExistence() != RowExistenceExpectation_IGNORE || pb.ColumnCondition != nil {
+		t.Fatalf("unexpected Condition: %v", pb)
+	}
+	if err = _make_condition(pb, OTSCondition{RowExistence: "EXPECT"}); err == nil {
+		t.Fatal("unknown row existence should fail")
+	}
+}
+
+func Test_encode_batch_write_row_with_column_condition(t *testing.T) {
+	batch_list := &OTSBatchWriteRowRequest{
+		{
+			TableName: "myTable",
+			PutRows: OTSPutRows{
+				{Condition: OTSCondition_EXPECT_NOT_EXIST, PrimaryKey: OTSPrimaryKey{"gid": 1, "uid": 101}},
+			},
+			UpdateRows: OTSUpdateRows{
+				{
+					Condition:                OTSCondition_EXPECT_EXIST,
+					ColumnCondition:          NewRelationCondition("version", OTSComparatorType_EQUAL, 7, false),
+					PrimaryKey:               OTSPrimaryKey{"gid": 1, "uid": 102},
+					UpdateOfAttributeColumns: OTSUpdateOfAttribute{OTSOperationType_PUT: OTSColumnsToPut{"version": 8}},
+				},
+			},
+		},
+	}
+
+	req, err := _encode_batch_write_row(batch_list)
+	if err != nil {
+		t.Fatalf("_encode_batch_write_row error: %s", err)
+	}
+	put_condition := req.GetTables()[0].GetPutRows()[0].GetCondition()
+	if put_condition.GetRowExistence() != RowExistenceExpectation_EXPECT_NOT_EXIST || put_condition.ColumnCondition != nil {
+		t.Fatalf("unexpected Condition: %v", put_condition)
+	}
+	update_condition := req.GetTables()[0].GetUpdateRows()[0].GetCondition()
+	if update_condition.GetRowExistence() != RowExistenceExpectation_EXPECT_EXIST || update_condition.GetColumnCondition().GetType() != ColumnConditionType_CCT_RELATION {
+		t.Fatalf("unexpected Condition: %v", update_condition)
+	}
+
+	// the row existence is required
+	(*batch_list)[0].UpdateRows[0].Condition = ""
+	if _, err = _encode_batch_write_row(batch_list); err == nil {
+		t.Fatal("empty row existence should fail")
+	}
+}
@@ -54,6 +54,11 @@ func (f Funcmap) Call(name string, args ...interface{}) (result []reflect.Value,
 
 	in := make([]reflect.Value, len(args))
 	for k, v := range args {
+		if v == nil {
+			// untyped nil (e.g. an optional interface argument) has no reflect.Value
+			in[k] = reflect.Zero(f[name].Type().In(k))
+			continue
+		}
 		in[k] = reflect.ValueOf(v)
 	}
 	result = f[name].Call(in)
@@ -6,6 +6,7 @@
 package goots
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha1"
@@ -70,11 +71,15 @@ type ots_protocol struct {
 	api_version   string
 	user_id       string
 	user_key      string
+	user_token    string
 	instance_name string
 	encoding      string
 	encoder       func(api_name string, args ...interface{}) (req []reflect.Value, err error)
 	decoder       func(api_name string, args ...interface{}) (req []reflect.Value, err error)
 	logger        string
+	ots_logger    OTSLogger // the logger of client, the protobuf messages are written to it for debugging
+	log_fields    []interface{}
+	log_ctx       context.Context // the context of API call, passed to OTSContextLogger
 }
 
 func (o *ots_protocol) Set(user_id, user_key, instance_name, encoding, logger string) *ots_protocol {
@@ -101,6 +106,28 @@ func (o *ots_protocol) Set(user_id, user_key, instance_name, encoding, logger st
 	return o
 }
 
+// a copy of protocol signing with the credentials, used by one request
+func (o *ots_protocol) _with_credentials(credentials *OTSCredentials) *ots_protocol {
+	protocol := *o
+	protocol.user_id = credentials.AccessId
+	protocol.user_key = credentials.AccessKey
+	protocol.user_token = credentials.SecurityToken
+
+	return &protocol
+}
+
+// write the protobuf message to the logger of client
+func (o *ots_protocol) _log_message(msg string, api_name string, pb proto.Message) {
+	if o.ots_logger == nil || pb == nil {
+		return
+	}
+
+	_log(o.log_ctx, o.ots_logger, log_level_debug, msg, _log_fields(o.log_fields,
+		"api", api_name,
+		"type", reflect.TypeOf(pb).Elem().Name(),
+		"protobuf", proto.CompactTextString(pb))...)
+}
+
 func (o *ots_protocol) _make_headers_string(headers DictString) string {
 	if len(headers) == 0 {
 		return "\n"
@@ -184,6 +211,9 @@ func (o *ots_protocol) _make_headers(body []byte, query string) (headers DictStr
 		"x-ots-instancename": o.instance_name,
 		"x-ots-contentmd5":   md5,
 	}
+	if o.user_token != "" {
+		headers["x-ots-ststoken"] = o.user_token
+	}
 
 	signature, err := o._make_request_signature(query, headers)
 	if err != nil {
@@ -359,15 +389,18 @@ func (o *ots_protocol) make_request(api_name string, args ...interface{}) (query
 		return "", DictString{}, nil, fmt.Errorf("Unknown type: %v", t)
 	}
 
+	if coder.DebugEncoderEnable {
+		if message, ok := pb[0].Interface().(proto.Message); ok {
+			o._log_message("Request Debug", api_name, message)
+		}
+	}
+
 	query = "/" + api_name
 	headers, err = o._make_headers(body, query)
 	if err != nil {
 		return "", DictString{}, nil, err
 	}
 
-	// prevent to generate formatted message which is time consuming
-	OTSError{}.Log(OTSLoggerEnable, "OTS request, API: %s, Headers: %s, Protobuf: %v", api_name, fmt.Sprintf("%v", headers), pb[0].Interface())
-
 	return query, headers, body, nil
 }
 
@@ -389,21 +422,19 @@ func (o *ots_protocol) parse_response(api_name, reason string, status int, heade
 	ret, err := o.decoder(api_name, body)
 	if err != nil {
 		request_id := o._get_request_id_string(headers)
-		error_message := fmt.Sprintf("Response format is invalid, %s, RequestID: %s, HTTP status: %s, Body: %v.", err, request_id, status, body)
+		error_message := fmt.Sprintf("Response format is invalid, %s, RequestID: %s, HTTP status: %d, Body: %v.", err, request_id, status, body)
 		return nil, ots_service_err.SetErrorMessage(error_message).SetHttpStatus(status).SetRequestId(request_id).SetErrorCode(fmt.Sprintf("%d", status))
 	}
-
-	// prevent to generate formatted message which is time consuming
-	request_id := o._get_request_id_string(headers)
-	OTSError{}.Log(OTSLoggerEnable, "OTS response, API: %s, RequestID: %s, Protobuf: %v.", api_name, request_id, ret[0].Interface())
+	if coder.DebugDecoderEnable {
+		if message := coder.NewResponseMessage(api_name); message != nil && proto.Unmarshal(body, message) == nil {
+			o._log_message("Response Debug", api_name, message)
+		}
+	}
 
 	return ret, nil
 }
 
 func (o *ots_protocol) handle_error(api_name, query, reason string, status int, headers DictString, body []byte) (ots_service_err *OTSServiceError) {
-	// prevent to generate formatted message which is time consuming
-	OTSError{}.Log(OTSLoggerEnable, "OTS response, API: %s, Status: %d, Reason: %s, Headers: %s", api_name, status, reason, headers)
-
 	ots_service_err = new(OTSServiceError)
 	request_id := o._get_request_id_string(headers)
 	if _, ok := api_list[api_name]; !ok {
@@ -439,15 +470,12 @@ func (o *ots_protocol) handle_error(api_name, query, reason string, status int,
 				}
 			}
 
-			OTSError{}.Log(OTSLoggerEnable, "OTS request failed, API: %s, HTTPStatus: %s, ErrorCode: %s, ErrorMessage: %s,  RequestID: %s", api_name, reason, pb_err.GetCode(), pb_err.GetMessage(), request_id)
 			return ots_service_err.SetErrorMessage(pb_err.GetMessage()).SetHttpStatus(status).SetErrorCode(pb_err.GetCode()).SetRequestId(request_id)
 		} else {
 			error_message := fmt.Sprintf("HTTP status: %d, reason: %s.", status, reason)
 			return ots_service_err.SetErrorMessage(error_message).SetHttpStatus(status).SetErrorCode(pb_err.GetCode()).SetRequestId(request_id)
 		}
 	}
-
-	return nil
 }
 
 ///////////////////////////////////////
@@ -191,7 +191,7 @@ func Test_make_request(t *testing.T) {
 	}
 
 	reserved_throughput := OTSReservedThroughput{
-		OTSCapacityUnit{0, 0},
+		CapacityUnit: OTSCapacityUnit{Read: 0, Write: 0},
 	}
 
 	query, headers, body, err := protocol.make_request("CreateTable", &table_meta, &reserved_throughput)
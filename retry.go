@@ -6,10 +6,14 @@
 package goots
 
 import (
+	"context"
 	"math"
 	"math/rand"
 	"net"
+	"sync"
 	"time"
+
+	. "github.com/GiterLab/goots/otstype"
 )
 
 // RetryPolicy 是重试策略的接口，包含2个未实现的方法和它们的参数列表。要实现一个重试策略，
@@ -256,3 +260,316 @@ func (self NoDelayRetryPolicy) ShouldRetry(retry_times int, exception *OTSServic
 
 	return false
 }
+
+// 重试时可以获取的API 调用信息
+type OTSRetryInfo struct {
+	// API 名，如 "GetRow"
+	Api string
+	// 已重试的次数
+	RetryTimes int
+	// 最近一次请求的错误，OnCallDone 中调用成功时为nil
+	Exception *OTSServiceError
+	// 重复执行不会改变结果：读操作，或者行条件为EXPECT_NOT_EXIST 的PutRow（BatchWriteRow 中只有这样的PutRow）
+	Idempotent bool
+	// 第一次请求的时间
+	StartTime time.Time
+	// ctx 的截止时间，没有截止时间时为零值
+	Deadline time.Time
+	// 上一次重试的间隔，单位为秒，第一次重试时为0
+	LastDelay float64
+}
+
+// 可以获取OTSRetryInfo 的重试策略，OTSClient 优先调用WithInfo 的方法
+type RetryPolicyWithInfoInterface interface {
+	RetryPolicyInterface
+	GetRetryDelayWithInfo(info *OTSRetryInfo) float64
+	ShouldRetryWithInfo(info *OTSRetryInfo) bool
+	// API 调用结束（成功或不再重试）时调用
+	OnCallDone(info *OTSRetryInfo)
+}
+
+// BackoffRetryPolicy 的随机抖动方式
+const (
+	// 在[0, 退避间隔] 中随机
+	OTSRetryJitter_FULL = "FULL"
+	// 在[退避间隔/2, 退避间隔] 中随机，与DefaultRetryPolicy 相同
+	OTSRetryJitter_EQUAL = "EQUAL"
+	// 在[起始间隔, 上一次间隔*3] 中随机
+	OTSRetryJitter_DECORRELATED = "DECORRELATED"
+)
+
+// 指数退避的重试策略，支持随机抖动、总耗时限制、按API 设置最大重试次数、幂等写操作的重试以及重试预算
+//
+// 		重试策略保存了重试预算，多个OTSClient 共用同一个BackoffRetryPolicy 时也共用其重试预算。
+//
+// 		示例：
+//
+// 		retry_policy := NewBackoffRetryPolicy()
+// 		retry_policy.MaxElapsedTime = 10
+// 		retry_policy.ApiMaxRetryTimes = map[string]int{"BatchWriteRow": 10}
+// 		retry_policy.Budget = NewRetryBudget(100, 0.1)
+// 		ots_client.RetryPolicy = retry_policy
+//
+type BackoffRetryPolicy struct {
+	// 最大重试次数
+	MaxRetryTimes int
+	// 按API 名设置的最大重试次数，优先于MaxRetryTimes
+	ApiMaxRetryTimes map[string]int
+
+	// 最大重试间隔，单位为秒
+	MaxRetryDelay float64
+	// 每次重试间隔的递增倍数
+	ScaleFactor float64
+	// 两种错误的起始重试间隔，单位为秒
+	ServerThrottlingExceptionDelayFactor float64
+	StabilityExceptionDelayFactor        float64
+	// 随机抖动方式，取值为 OTSRetryJitter_FULL、OTSRetryJitter_EQUAL 或 OTSRetryJitter_DECORRELATED
+	Jitter string
+
+	// 从第一次请求开始的最长重试时间，单位为秒，0 表示不限制
+	MaxElapsedTime float64
+	// 是否对幂等的写操作重试服务端内部错误（如5xx）
+	RetryIdempotentWrites bool
+	// 可选，重试预算
+	Budget *OTSRetryBudget
+}
+
+// 说明：创建一个默认参数与OTSDefaultRetryPolicy 相同，使用OTSRetryJitter_FULL 并重试幂等写操作的重试策略。
+func NewBackoffRetryPolicy() *BackoffRetryPolicy {
+	return &BackoffRetryPolicy{
+		MaxRetryTimes:                        OTSDefaultRetryPolicy.MaxRetryTimes,
+		MaxRetryDelay:                        OTSDefaultRetryPolicy.MaxRetryDelay,
+		ScaleFactor:                          OTSDefaultRetryPolicy.ScaleFactor,
+		ServerThrottlingExceptionDelayFactor: OTSDefaultRetryPolicy.ServerThrottlingExceptionDelayFactor,
+		StabilityExceptionDelayFactor:        OTSDefaultRetryPolicy.StabilityExceptionDelayFactor,
+		Jitter:                               OTSRetryJitter_FULL,
+		RetryIdempotentWrites:                true,
+	}
+}
+
+func (self *BackoffRetryPolicy) GetRetryDelay(retry_times int, exception *OTSServiceError, api_name string) float64 {
+	return self.GetRetryDelayWithInfo(&OTSRetryInfo{Api: api_name, RetryTimes: retry_times, Exception: exception, Idempotent: is_repeatable_api(api_name)})
+}
+
+func (self *BackoffRetryPolicy) ShouldRetry(retry_times int, exception *OTSServiceError, api_name string) bool {
+	return self.ShouldRetryWithInfo(&OTSRetryInfo{Api: api_name, RetryTimes: retry_times, Exception: exception, Idempotent: is_repeatable_api(api_name)})
+}
+
+func (self *BackoffRetryPolicy) GetRetryDelayWithInfo(info *OTSRetryInfo) float64 {
+	base := self.StabilityExceptionDelayFactor
+	if is_server_throttling_exception(info.Exception) {
+		base = self.ServerThrottlingExceptionDelayFactor
+	}
+
+	var delay float64
+	switch self.Jitter {
+	case OTSRetryJitter_DECORRELATED:
+		delay = base + rand.Float64()*(math.Max(info.LastDelay*3, base)-base)
+		if delay > self.MaxRetryDelay {
+			delay = self.MaxRetryDelay
+		}
+	case OTSRetryJitter_EQUAL:
+		delay_limit := math.Min(base*math.Pow(self.ScaleFactor, float64(info.RetryTimes)), self.MaxRetryDelay)
+		delay = delay_limit*0.5 + delay_limit*0.5*rand.Float64()
+	default:
+		delay_limit := math.Min(base*math.Pow(self.ScaleFactor, float64(info.RetryTimes)), self.MaxRetryDelay)
+		delay = delay_limit * rand.Float64()
+	}
+
+	// do not sleep beyond the elapsed time and the deadline
+	if remaining, ok := self._remaining(info); ok && delay > remaining {
+		delay = remaining
+	}
+
+	return delay
+}
+
+func (self *BackoffRetryPolicy) ShouldRetryWithInfo(info *OTSRetryInfo) bool {
+	max_retry_times := self.MaxRetryTimes
+	if n, ok := self.ApiMaxRetryTimes[info.Api]; ok {
+		max_retry_times = n
+	}
+	if info.RetryTimes >= max_retry_times {
+		return false
+	}
+
+	if remaining, ok := self._remaining(info); ok && remaining <= 0 {
+		return false
+	}
+
+	if !self._can_retry(info) {
+		return false
+	}
+
+	if self.Budget != nil && !self.Budget._withdraw() {
+		return false
+	}
+
+	return true
+}
+
+func (self *BackoffRetryPolicy) OnCallDone(info *OTSRetryInfo) {
+	if self.Budget != nil && info.Exception == nil {
+		self.Budget._deposit()
+	}
+}
+
+func (self *BackoffRetryPolicy) _can_retry(info *OTSRetryInfo) bool {
+	if should_retry_no_matter_which_api(info.Exception) {
+		return true
+	}
+
+	if is_repeatable_api(info.Api) || (self.RetryIdempotentWrites && info.Idempotent) {
+		return should_retry_when_api_repeatable(info.RetryTimes, info.Exception, info.Api)
+	}
+
+	return false
+}
+
+// the remaining seconds before the elapsed time or the deadline is reached
+func (self *BackoffRetryPolicy) _remaining(info *OTSRetryInfo) (float64, bool) {
+	remaining, ok := math.Inf(1), false
+	if self.MaxElapsedTime > 0 && !info.StartTime.IsZero() {
+		remaining, ok = self.MaxElapsedTime-time.Since(info.StartTime).Seconds(), true
+	}
+	if !info.Deadline.IsZero() {
+		remaining, ok = math.Min(remaining, time.Until(info.Deadline).Seconds()), true
+	}
+
+	return remaining, ok
+}
+
+// 重试预算，限制重试占请求的比例，避免服务端异常时的重试风暴
+//
+// 		预算初始为MaxTokens，每次重试消耗1，每次成功的API 调用增加TokenRatio，最多为MaxTokens，
+// 		不足1 时不再重试。如 NewRetryBudget(100, 0.1) 允许突发100 次重试，之后重试最多占成功调用的10%。
+type OTSRetryBudget struct {
+	MaxTokens  float64
+	TokenRatio float64
+
+	lock   sync.Mutex
+	tokens float64
+}
+
+func NewRetryBudget(max_tokens, token_ratio float64) *OTSRetryBudget {
+	return &OTSRetryBudget{MaxTokens: max_tokens, TokenRatio: token_ratio, tokens: max_tokens}
+}
+
+// 说明：返回剩余的重试预算。
+func (o *OTSRetryBudget) Tokens() float64 {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.tokens
+}
+
+func (o *OTSRetryBudget) _withdraw() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.tokens < 1 {
+		return false
+	}
+	o.tokens -= 1
+	return true
+}
+
+func (o *OTSRetryBudget) _deposit() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.tokens += o.TokenRatio
+	if o.tokens > o.MaxTokens {
+		o.tokens = o.MaxTokens
+	}
+}
+
+// the retry state of an API call, passed to the retry policy
+type retry_state struct {
+	policy RetryPolicyInterface
+	info   OTSRetryInfo
+}
+
+func (o *OTSClient) _new_retry_state(ctx context.Context, call *OTSCall, start_time time.Time) *retry_state {
+	state := &retry_state{policy: o.RetryPolicy}
+	state.info.Api = call.Api
+	state.info.StartTime = start_time
+	state.info.Idempotent = is_repeatable_api(call.Api) || _is_idempotent_write(call)
+	if deadline, ok := ctx.Deadline(); ok {
+		state.info.Deadline = deadline
+	}
+
+	return state
+}
+
+func (o *retry_state) should_retry(retry_times int, exception *OTSServiceError) bool {
+	policy, ok := o.policy.(RetryPolicyWithInfoInterface)
+	if !ok {
+		return o.policy.ShouldRetry(retry_times, exception, o.info.Api)
+	}
+
+	o.info.RetryTimes, o.info.Exception = retry_times, exception
+	return policy.ShouldRetryWithInfo(&o.info)
+}
+
+func (o *retry_state) get_retry_delay(retry_times int, exception *OTSServiceError) float64 {
+	policy, ok := o.policy.(RetryPolicyWithInfoInterface)
+	if !ok {
+		return o.policy.GetRetryDelay(retry_times, exception, o.info.Api)
+	}
+
+	o.info.RetryTimes, o.info.Exception = retry_times, exception
+	delay := policy.GetRetryDelayWithInfo(&o.info)
+	o.info.LastDelay = delay
+	return delay
+}
+
+func (o *retry_state) done(retry_times int, exception *OTSServiceError) {
+	if policy, ok := o.policy.(RetryPolicyWithInfoInterface); ok {
+		o.info.RetryTimes, o.info.Exception = retry_times, exception
+		policy.OnCallDone(&o.info)
+	}
+}
+
+// PutRow with EXPECT_NOT_EXIST can not overwrite a row after the first attempt succeeded
+func _is_idempotent_write(call *OTSCall) bool {
+	switch call.Api {
+	case "PutRow":
+		if len(call.Args) > 1 {
+			return _is_expect_not_exist(call.Args[1])
+		}
+	case "BatchWriteRow":
+		if len(call.Args) > 0 {
+			batch_list, ok := call.Args[0].(*OTSBatchWriteRowRequest)
+			if !ok || batch_list == nil {
+				return false
+			}
+			for _, table := range *batch_list {
+				if len(table.UpdateRows) > 0 || len(table.DeleteRows) > 0 {
+					return false
+				}
+				for _, row := range table.PutRows {
+					if !_is_expect_not_exist(row.Condition) {
+						return false
+					}
+				}
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+func _is_expect_not_exist(condition interface{}) bool {
+	switch v := condition.(type) {
+	case string:
+		return v == OTSCondition_EXPECT_NOT_EXIST
+	case OTSCondition:
+		return v.RowExistence == OTSCondition_EXPECT_NOT_EXIST
+	case *OTSCondition:
+		return v != nil && v.RowExistence == OTSCondition_EXPECT_NOT_EXIST
+	}
+
+	return false
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test retry for ots2
+package goots
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_backoff_retry_policy_delay(t *testing.T) {
+	retry_policy := NewBackoffRetryPolicy()
+	busy := &OTSServiceError{Code: "OTSServerBusy"}
+
+	for i := 0; i < 100; i++ {
+		// 0.5 * 2^2 = 2
+		info := &OTSRetryInfo{Api: "GetRow", RetryTimes: 2, Exception: busy}
+		retry_policy.Jitter = OTSRetryJitter_FULL
+		if delay := retry_policy.GetRetryDelayWithInfo(info); delay < 0 || delay > 2 {
+			t.Fatalf("unexpected full jitter delay: %v", delay)
+		}
+		retry_policy.Jitter = OTSRetryJitter_EQUAL
+		if delay := retry_policy.GetRetryDelayWithInfo(info); delay < 1 || delay > 2 {
+			t.Fatalf("unexpected equal jitter delay: %v", delay)
+		}
+		retry_policy.Jitter = OTSRetryJitter_DECORRELATED
+		info.LastDelay = 0.4
+		if delay := retry_policy.GetRetryDelayWithInfo(info); delay < 0.5 || delay > 1.2 {
+			t.Fatalf("unexpected decorrelated jitter delay: %v", delay)
+		}
+
+		// limited by the elapsed time and the deadline
+		info.StartTime = time.Now().Add(-9900 * time.Millisecond)
+		retry_policy.MaxElapsedTime = 10
+		if delay := retry_policy.GetRetryDelayWithInfo(info); delay > 0.1 {
+			t.Fatalf("delay should be limited by the elapsed time, not %v", delay)
+		}
+		retry_policy.MaxElapsedTime = 0
+		info.Deadline = time.Now().Add(100 * time.Millisecond)
+		if delay := retry_policy.GetRetryDelayWithInfo(info); delay > 0.1 {
+			t.Fatalf("delay should be limited by the deadline, not %v", delay)
+		}
+	}
+
+	// the elapsed time is used up
+	retry_policy.MaxElapsedTime = 1
+	if retry_policy.ShouldRetryWithInfo(&OTSRetryInfo{Api: "GetRow", Exception: busy, StartTime: time.Now().Add(-time.Second)}) {
+		t.Fatal("should not retry after MaxElapsedTime")
+	}
+	if !retry_policy.ShouldRetryWithInfo(&OTSRetryInfo{Api: "GetRow", Exception: busy, StartTime: time.Now()}) {
+		t.Fatal("should retry before MaxElapsedTime")
+	}
+}
+
+func Test_backoff_retry_policy(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+
+	retry_policy := NewBackoffRetryPolicy()
+	retry_policy.ServerThrottlingExceptionDelayFactor = 0
+	retry_policy.StabilityExceptionDelayFactor = 0
+	retry_policy.ApiMaxRetryTimes = map[string]int{"ListTable": 1}
+	client.RetryPolicy = retry_policy
+
+	attempts := int32(0)
+	ots_emulator.SetInjectError(func(api_name string) (int, string, string) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return 500, "OTSInternalServerError", "Internal server error."
+		}
+		return 0, "", ""
+	})
+
+	// idempotent writes are retried on 5xx
+	atomic.StoreInt32(&attempts, 0)
+	if _, ots_err := client.PutRow("myTable", OTSCondition_EXPECT_NOT_EXIST, &OTSPrimaryKey{"gid": 1, "uid": 1}, &OTSAttribute{"age": 1}); ots_err != nil || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("PutRow with EXPECT_NOT_EXIST should be retried: %v, %d", ots_err, atomic.LoadInt32(&attempts))
+	}
+	atomic.StoreInt32(&attempts, 0)
+	if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, &OTSPrimaryKey{"gid": 1, "uid": 2}, &OTSAttribute{"age": 2}); !errors.Is(ots_err, ErrInternalServerError) || atomic.LoadInt32(&attempts) != 1 {
+		t.Fatalf("PutRow with IGNORE should not be retried: %v, %d", ots_err, atomic.LoadInt32(&attempts))
+	}
+	retry_policy.RetryIdempotentWrites = false
+	atomic.StoreInt32(&attempts, 0)
+	if _, ots_err := client.PutRow("myTable", OTSCondition_EXPECT_NOT_EXIST, &OTSPrimaryKey{"gid": 1, "uid": 3}, &OTSAttribute{"age": 3}); !errors.Is(ots_err, ErrInternalServerError) || atomic.LoadInt32(&attempts) != 1 {
+		t.Fatalf("PutRow should not be retried: %v, %d", ots_err, atomic.LoadInt32(&attempts))
+	}
+
+	// per-API max retry times
+	ots_emulator.SetInjectError(func(api_name string) (int, string, string) {
+		atomic.AddInt32(&attempts, 1)
+		return 503, "OTSServerBusy", "Server is busy."
+	})
+	atomic.StoreInt32(&attempts, 0)
+	if _, ots_err := client.ListTable(); !errors.Is(ots_err, ErrServerBusy) || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("ListTable should be retried once: %v, %d", ots_err, atomic.LoadInt32(&attempts))
+	}
+
+	// the deadline of ctx
+	retry_policy.ServerThrottlingExceptionDelayFactor = 10
+	retry_policy.MaxRetryDelay = 10
+	retry_policy.Jitter = OTSRetryJitter_EQUAL
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start_time := time.Now()
+	if _, ots_err := client.DescribeTableWithContext(ctx, "myTable"); ots_err == nil || time.Since(start_time) > 2*time.Second {
+		t.Fatalf("DescribeTable should fail before the deadline: %v, %v", ots_err, time.Since(start_time))
+	}
+
+	// the retry budget is used up, only GetRow is counted as the request abandoned at the deadline may still be handled
+	get_row_attempts := int32(0)
+	ots_emulator.SetInjectError(func(api_name string) (int, string, string) {
+		if api_name == "GetRow" {
+			atomic.AddInt32(&get_row_attempts, 1)
+		}
+		return 503, "OTSServerBusy", "Server is busy."
+	})
+	retry_policy.ServerThrottlingExceptionDelayFactor = 0
+	retry_policy.Budget = NewRetryBudget(2, 0.5)
+	if _, ots_err := client.GetRow("myTable", &OTSPrimaryKey{"gid": 1, "uid": 1}, nil); !errors.Is(ots_err, ErrServerBusy) || atomic.LoadInt32(&get_row_attempts) != 3 {
+		t.Fatalf("GetRow should be retried twice: %v, %d", ots_err, atomic.LoadInt32(&get_row_attempts))
+	}
+	if tokens := retry_policy.Budget.Tokens(); tokens != 0 {
+		t.Fatalf("unexpected tokens: %v", tokens)
+	}
+	ots_emulator.SetInjectError(nil)
+	if _, ots_err := client.DescribeTable("myTable"); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if tokens := retry_policy.Budget.Tokens(); tokens != 0.5 {
+		t.Fatalf("unexpected tokens: %v", tokens)
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// parallel range scan for ots2
+package goots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+const (
+	DEFAULT_SCAN_WORKERS   = 4
+	SCAN_SPLITS_PER_WORKER = 4 // 切分的份数为worker数的倍数，使各个worker的负载更均衡
+)
+
+// one piece of the scan range, [start, end)
+type scan_range struct {
+	start *OTSOrderedPrimaryKey
+	end   *OTSOrderedPrimaryKey
+}
+
+// 说明：并行扫描一个范围内的所有数据。按第一个主键列把范围切分成多份，由多个worker并发执行GetRange。
+//
+// 		``table_name``是对应的表名。
+// 		``inclusive_start_primary_key``表示范围的起始主键（在范围内）。
+// 		``exclusive_end_primary_key``表示范围的结束主键（不在范围内）。
+// 		第一个主键列的类型必须为INTEGER或STRING，值可以为OTSColumnType_INF_MIN或OTSColumnType_INF_MAX。
+// 		STRING类型的切分点由范围两端的公共前缀加可打印ASCII字符组成，两端在公共前缀之后的第一个字符都不是ASCII时不切分。
+// 		``columns_to_get``是可选参数，表示要获取的列的名称列表，类型为``otstype.OTSColumnsToGet``；如果为nil，表示获取所有列。
+// 		``workers``表示并发的worker数量；如果为0，则使用DEFAULT_SCAN_WORKERS。
+// 		``callback``对每一行数据调用一次，会被多个worker并发调用，行之间的顺序不保证；返回错误时停止扫描。
+// 		``filter``是可选参数，表示服务端过滤条件，类型为``otstype.OTSColumnCondition``；不满足条件的行不会返回。
+//
+// 		返回：所有请求合计消耗的CapacityUnit。
+// 		      错误信息。
+//
+// 		示例：
+//
+// 		inclusive_start_primary_key := &OTSPrimaryKey{
+// 			"gid": OTSColumnType_INF_MIN,
+// 			"uid": OTSColumnType_INF_MIN,
+// 		}
+// 		exclusive_end_primary_key := &OTSPrimaryKey{
+// 			"gid": OTSColumnType_INF_MAX,
+// 			"uid": OTSColumnType_INF_MAX,
+// 		}
+// 		var rows_lock sync.Mutex
+// 		rows := OTSRows{}
+// 		consumed, ots_err := ots_client.ParallelScan("myTable", inclusive_start_primary_key, exclusive_end_primary_key, nil, 8,
+// 			func(row *OTSRow) error {
+// 				rows_lock.Lock()
+// 				defer rows_lock.Unlock()
+// 				rows = append(rows, row)
+// 				return nil
+// 			})
+//
+func (o *OTSClient) ParallelScan(table_name string,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
+	columns_to_get *OTSColumnsToGet,
+	workers int,
+	callback func(row *OTSRow) error,
+	filter ...OTSColumnCondition) (consumed *OTSCapacityUnit, err *OTSError) {
+	return o.ParallelScanWithContext(context.Background(), table_name, inclusive_start_primary_key, exclusive_end_primary_key, columns_to_get, workers, callback, filter...)
+}
+
+// 说明：同ParallelScan，ctx用于控制整个扫描的超时和取消。
+func (o *OTSClient) ParallelScanWithContext(ctx context.Context, table_name string,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
+	columns_to_get *OTSColumnsToGet,
+	workers int,
+	callback func(row *OTSRow) error,
+	filter ...OTSColumnCondition) (consumed *OTSCapacityUnit, err *OTSError) {
+	if table_name == "" {
+		return nil, new(OTSError).SetClientMessage("[ParallelScan] table_name should not be empty")
+	}
+	if _is_nil_primary_key(inclusive_start_primary_key) {
+		return nil, new(OTSError).SetClientMessage("[ParallelScan] inclusive_start_primary_key should not be nil")
+	}
+	if _is_nil_primary_key(exclusive_end_primary_key) {
+		return nil, new(OTSError).SetClientMessage("[ParallelScan] exclusive_end_primary_key should not be nil")
+	}
+	if callback == nil {
+		return nil, new(OTSError).SetClientMessage("[ParallelScan] callback should not be nil")
+	}
+	if workers < 0 {
+		return nil, new(OTSError).SetClientMessage("[ParallelScan] workers should not be negative")
+	}
+	if workers == 0 {
+		workers = DEFAULT_SCAN_WORKERS
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// the order of primary key columns is only known by the table meta
+	describe_response, ots_err := o.DescribeTableWithContext(ctx, table_name)
+	if ots_err != nil {
+		return nil, ots_err
+	}
+	ranges, e := _split_scan_range(describe_response.TableMeta.SchemaOfPrimaryKey,
+		inclusive_start_primary_key, exclusive_end_primary_key, workers*SCAN_SPLITS_PER_WORKER)
+	if e != nil {
+		return nil, new(OTSError).SetClientMessage("[ParallelScan] %s", e)
+	}
+	if workers > len(ranges) {
+		workers = len(ranges)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	total := new(OTSCapacityUnit)
+	jobs := make(chan scan_range)
+
+	// keep the first error only, the others are caused by canceling
+	set_error := func(e *OTSError) {
+		lock.Lock()
+		if err == nil {
+			err = e
+		}
+		lock.Unlock()
+		cancel()
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				iter := o.XGetRangeWithContext(ctx, table_name, OTSDirection_FORWARD, r.start, r.end, columns_to_get, 0, filter...)
+				for iter.Next() {
+					if e := callback(iter.Row()); e != nil {
+						set_error(new(OTSError).SetClientMessage("[ParallelScan] callback error: %s", e))
+						iter.Close()
+					}
+				}
+				if iter.Err() != nil {
+					set_error(iter.Err())
+				}
+
+				lock.Lock()
+				total.Read += iter.Consumed().GetRead()
+				total.Write += iter.Consumed().GetWrite()
+				lock.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, r := range ranges {
+		select {
+		case jobs <- r:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err == nil && ctx.Err() != nil {
+		// canceled by the caller before all ranges were dispatched
+		err = new(OTSError).SetClientMessage("[ParallelScan] %s", ctx.Err())
+	}
+	if err != nil {
+		return total, err
+	}
+
+	return total, nil
+}
+
+// split [start, end) on the first primary key column into at most splits pieces
+func _split_scan_range(schema_of_primary_key OTSSchemaOfPrimaryKey, inclusive_start_primary_key, exclusive_end_primary_key OTSPrimaryKeyInterface, splits int) ([]scan_range, error) {
+	if len(schema_of_primary_key) == 0 {
+		return nil, errors.New("schema of primary key is empty")
+	}
+
+	// send the primary keys in the order of schema
+	start, err := NewPrimaryKeyFromSchema(schema_of_primary_key, _to_primary_key(inclusive_start_primary_key))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("inclusive_start_primary_key: %s", err))
+	}
+	end, err := NewPrimaryKeyFromSchema(schema_of_primary_key, _to_primary_key(exclusive_end_primary_key))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("exclusive_end_primary_key: %s", err))
+	}
+	if err = ValidateRange(OTSDirection_FORWARD, start, end); err != nil {
+		return nil, err
+	}
+
+	first_name := schema_of_primary_key[0].GetName()
+	first_type, _ := schema_of_primary_key[0].GetType().(string)
+	start_value := start.Get(first_name)
+	end_value := end.Get(first_name)
+
+	var points []interface{}
+	switch first_type {
+	case OTSColumnType_INTEGER:
+		lo, err := _scan_integer_key(start_value)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := _scan_integer_key(end_value)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range _split_uint64(lo, hi, splits) {
+			points = append(points, int64(v^(1<<63)))
+		}
+
+	case OTSColumnType_STRING:
+		if points, err = _scan_string_points(start_value, end_value, splits); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.New(fmt.Sprintf("the first primary key column should be INTEGER or STRING, not %s", first_type))
+	}
+
+	ranges := make([]scan_range, 0, len(points)+1)
+	range_start := start
+	for _, v := range points {
+		split_point := OTSOrderedPrimaryKey{{K: first_name, V: v}}
+		for _, pk := range schema_of_primary_key[1:] {
+			split_point = append(split_point, TupleString{K: pk.GetName(), V: OTSColumnType_INF_MIN})
+		}
+		ranges = append(ranges, scan_range{range_start, &split_point})
+		range_start = &split_point
+	}
+	ranges = append(ranges, scan_range{range_start, end})
+
+	return ranges, nil
+}
+
+func _to_primary_key(primary_key OTSPrimaryKeyInterface) OTSPrimaryKey {
+	return OTSOrderedPrimaryKey(GetPrimaryKeyList(primary_key)).ToPrimaryKey()
+}
+
+// at most n-1 points evenly distributed in (lo, hi)
+func _split_uint64(lo, hi uint64, n int) []uint64 {
+	if n < 2 || hi <= lo {
+		return nil
+	}
+
+	step := (hi - lo) / uint64(n)
+	if step == 0 {
+		step = 1
+	}
+
+	points := []uint64{}
+	for i := uint64(1); i < uint64(n); i++ {
+		p := lo + step*i
+		if p <= lo || p >= hi {
+			break
+		}
+		points = append(points, p)
+	}
+
+	return points
+}
+
+// map an INTEGER primary key to uint64 with the same order
+func _scan_integer_key(value interface{}) (uint64, error) {
+	column_value, err := NewColumnValue(value)
+	if err != nil {
+		return 0, err
+	}
+
+	var v int64
+	switch {
+	case column_value.IsInfMin():
+		v = math.MinInt64
+	case column_value.IsInfMax():
+		v = math.MaxInt64
+	default:
+		if v, err = column_value.AsInteger(); err != nil {
+			return 0, errors.New(fmt.Sprintf("the first primary key column is INTEGER, not %s", column_value.Type()))
+		}
+	}
+
+	return uint64(v) ^ (1 << 63), nil
+}
+
+// the STRING split points are made of printable ASCII, so they are always valid UTF-8
+const (
+	scan_string_key_min_char = ' '
+	scan_string_key_max_char = '~'
+	scan_string_key_base     = scan_string_key_max_char - scan_string_key_min_char + 1
+	scan_string_key_length   = 8 // base^8 fits in uint64
+)
+
+// at most splits-1 STRING points in (start_value, end_value), interpolated over
+// the printable ASCII after the common prefix of the range
+func _scan_string_points(start_value, end_value interface{}, splits int) ([]interface{}, error) {
+	start, err := NewColumnValue(start_value)
+	if err != nil {
+		return nil, err
+	}
+	end, err := NewColumnValue(end_value)
+	if err != nil {
+		return nil, err
+	}
+
+	var start_str, end_str string
+	if !start.IsInfMin() {
+		if start_str, err = start.AsString(); err != nil {
+			return nil, errors.New(fmt.Sprintf("the first primary key column is STRING, not %s", start.Type()))
+		}
+	}
+	end_inf_max := end.IsInfMax()
+	if end.IsInfMin() {
+		return nil, nil
+	} else if !end_inf_max {
+		if end_str, err = end.AsString(); err != nil {
+			return nil, errors.New(fmt.Sprintf("the first primary key column is STRING, not %s", end.Type()))
+		}
+	}
+
+	prefix := ""
+	lo := _string_key_to_uint64(start_str)
+	hi := _string_key_to_uint64(end_str)
+	if end_inf_max {
+		hi = _string_key_max()
+	} else {
+		// the common prefix ends at a rune boundary, so the points keep the runes of prefix whole
+		i := 0
+		for i < len(start_str) && i < len(end_str) && start_str[i] == end_str[i] {
+			i++
+		}
+		for i > 0 && ((i < len(start_str) && !utf8.RuneStart(start_str[i])) || (i < len(end_str) && !utf8.RuneStart(end_str[i]))) {
+			i--
+		}
+		prefix = start_str[:i]
+		lo, hi = _string_key_to_uint64(start_str[i:]), _string_key_to_uint64(end_str[i:])
+	}
+
+	// the characters out of the alphabet are rounded, drop the points not strictly in the range
+	points := []interface{}{}
+	last := start_str
+	for _, v := range _split_uint64(lo, hi, splits) {
+		point := prefix + _uint64_to_string_key(v)
+		if point <= last || (!end_inf_max && point >= end_str) {
+			continue
+		}
+		points = append(points, point)
+		last = point
+	}
+
+	return points, nil
+}
+
+// map the first scan_string_key_length bytes of s to uint64 with the same order, the characters
+// below the alphabet round the rest down and the characters above it round the rest up
+func _string_key_to_uint64(s string) uint64 {
+	var v uint64
+	rounded := false
+	var digit uint64
+	for i := 0; i < scan_string_key_length; i++ {
+		switch {
+		case rounded:
+		case i >= len(s):
+			digit = 0
+		case s[i] < scan_string_key_min_char:
+			digit, rounded = 0, true
+		case s[i] > scan_string_key_max_char:
+			digit, rounded = scan_string_key_base-1, true
+		default:
+			digit = uint64(s[i] - scan_string_key_min_char)
+		}
+		v = v*scan_string_key_base + digit
+	}
+
+	return v
+}
+
+func _uint64_to_string_key(v uint64) string {
+	b := make([]byte, scan_string_key_length)
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(scan_string_key_min_char + v%scan_string_key_base)
+		v /= scan_string_key_base
+	}
+
+	// the trailing minimum characters make no difference in order
+	return strings.TrimRight(string(b), string(scan_string_key_min_char))
+}
+
+// the largest value of _string_key_to_uint64
+func _string_key_max() uint64 {
+	v := uint64(0)
+	for i := 0; i < scan_string_key_length; i++ {
+		v = v*scan_string_key_base + scan_string_key_base - 1
+	}
+
+	return v
+}
@@ -0,0 +1,164 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test parallel range scan for ots2
+package goots
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_split_scan_range_integer(t *testing.T) {
+	schema := OTSSchemaOfPrimaryKey{
+		{K: "gid", V: "INTEGER"},
+		{K: "uid", V: "INTEGER"},
+	}
+	start := OTSPrimaryKey{"gid": 0, "uid": OTSColumnType_INF_MIN}
+	end := OTSPrimaryKey{"gid": 100, "uid": OTSColumnType_INF_MAX}
+
+	ranges, err := _split_scan_range(schema, &start, &end, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 4 {
+		t.Fatalf("number of ranges excpected %d but %d", 4, len(ranges))
+	}
+	if ranges[0].start.Get("gid") != 0 || ranges[3].end.Get("gid") != 100 {
+		t.Fatal("the first and last range should keep the original bounds")
+	}
+	for i, v := range []int64{25, 50, 75} {
+		split_point := ranges[i].end
+		if split_point.Get("gid") != v || split_point.Get("uid") != OTSColumnType_INF_MIN {
+			t.Errorf("split point %d excpected gid=%d but %v", i, v, split_point)
+		}
+		if ranges[i+1].start != ranges[i].end {
+			t.Errorf("range %d should start at the end of range %d", i+1, i)
+		}
+	}
+
+	// INF bounds cover the whole int64 space
+	start = OTSPrimaryKey{"gid": OTSColumnType_INF_MIN, "uid": OTSColumnType_INF_MIN}
+	end = OTSPrimaryKey{"gid": OTSColumnType_INF_MAX, "uid": OTSColumnType_INF_MAX}
+	ranges, err = _split_scan_range(schema, &start, &end, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 2 || ranges[0].end.Get("gid") != int64(-1) {
+		t.Errorf("unexpected ranges: %v", ranges)
+	}
+
+	// too narrow to split
+	start = OTSPrimaryKey{"gid": 1, "uid": OTSColumnType_INF_MIN}
+	end = OTSPrimaryKey{"gid": 2, "uid": OTSColumnType_INF_MAX}
+	ranges, err = _split_scan_range(schema, &start, &end, 8)
+	if err != nil || len(ranges) != 1 {
+		t.Errorf("number of ranges excpected %d but %d, %v", 1, len(ranges), err)
+	}
+}
+
+func Test_split_scan_range_string(t *testing.T) {
+	schema := OTSSchemaOfPrimaryKey{
+		{K: "name", V: "STRING"},
+		{K: "uid", V: "INTEGER"},
+	}
+	start := OTSPrimaryKey{"name": "user_a", "uid": OTSColumnType_INF_MIN}
+	end := OTSPrimaryKey{"name": "user_z", "uid": OTSColumnType_INF_MAX}
+
+	ranges, err := _split_scan_range(schema, &start, &end, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 5 {
+		t.Fatalf("number of ranges excpected %d but %d", 5, len(ranges))
+	}
+	last := "user_a"
+	for _, r := range ranges[:4] {
+		v := r.end.Get("name").(string)
+		if v <= last || v >= "user_z" {
+			t.Errorf("split point %q should be in (%q, %q)", v, last, "user_z")
+		}
+		last = v
+	}
+
+	start = OTSPrimaryKey{"name": OTSColumnType_INF_MIN, "uid": OTSColumnType_INF_MIN}
+	end = OTSPrimaryKey{"name": OTSColumnType_INF_MAX, "uid": OTSColumnType_INF_MAX}
+	ranges, err = _split_scan_range(schema, &start, &end, 4)
+	if err != nil || len(ranges) != 4 {
+		t.Errorf("number of ranges excpected %d but %d, %v", 4, len(ranges), err)
+	}
+
+	// every split point is valid UTF-8 and strictly in the range
+	for _, c := range []struct {
+		start, end interface{}
+		splits     int
+	}{
+		{"user_a", "user_z", 16},
+		{OTSColumnType_INF_MIN, OTSColumnType_INF_MAX, 16},
+		{"用户a", "用户z", 16},
+		{"user_\u00e8", "user_\u00e9", 16},
+		{"a\x01", "a\x7f\u00e9", 16},
+		{"", "\u4e2d", 16},
+	} {
+		start = OTSPrimaryKey{"name": c.start, "uid": OTSColumnType_INF_MIN}
+		end = OTSPrimaryKey{"name": c.end, "uid": OTSColumnType_INF_MAX}
+		ranges, err = _split_scan_range(schema, &start, &end, c.splits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.start == OTSColumnType_INF_MIN && len(ranges) != c.splits {
+			t.Errorf("number of ranges excpected %d but %d", c.splits, len(ranges))
+		}
+		last, _ := c.start.(string)
+		for _, r := range ranges[:len(ranges)-1] {
+			v := r.end.Get("name").(string)
+			if !utf8.ValidString(v) {
+				t.Errorf("split point %q between %q and %q is not valid UTF-8", v, c.start, c.end)
+			}
+			if v <= last {
+				t.Errorf("split point %q should be after %q", v, last)
+			}
+			if end_str, ok := c.end.(string); ok && v >= end_str {
+				t.Errorf("split point %q should be before %q", v, end_str)
+			}
+			last = v
+		}
+	}
+
+	if _, err = _split_scan_range(OTSSchemaOfPrimaryKey{{K: "flag", V: "BOOLEAN"}}, &OTSPrimaryKey{"flag": false}, &OTSPrimaryKey{"flag": true}, 4); err == nil {
+		t.Error("BOOLEAN primary key should not be split")
+	}
+}
+
+func Test_split_scan_range_order(t *testing.T) {
+	// the order of schema is not the order of names
+	schema := OTSSchemaOfPrimaryKey{
+		{K: "uid", V: "INTEGER"},
+		{K: "gid", V: "INTEGER"},
+	}
+	start := OTSPrimaryKey{"gid": OTSColumnType_INF_MIN, "uid": 0}
+	end := OTSPrimaryKey{"gid": OTSColumnType_INF_MAX, "uid": 100}
+
+	ranges, err := _split_scan_range(schema, &start, &end, 2)
+	if err != nil || len(ranges) != 2 {
+		t.Fatalf("number of ranges excpected %d but %d, %v", 2, len(ranges), err)
+	}
+	for _, r := range ranges {
+		for _, primary_key := range []*OTSOrderedPrimaryKey{r.start, r.end} {
+			if len(*primary_key) != 2 || (*primary_key)[0].K != "uid" || (*primary_key)[1].K != "gid" {
+				t.Errorf("primary key should be in the order of schema: %v", primary_key)
+			}
+		}
+	}
+	if ranges[0].end.Get("uid") != int64(50) {
+		t.Errorf("unexpected split point: %v", ranges[0].end)
+	}
+
+	// all primary key columns are required
+	if _, err = _split_scan_range(schema, &OTSPrimaryKey{"uid": 0}, &end, 2); err == nil {
+		t.Error("primary key without gid should fail")
+	}
+}
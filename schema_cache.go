@@ -0,0 +1,231 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Cached table meta for ots2
+package goots
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+// the time to skip DescribeTable of a table after it fails, e.g. it is not allowed
+const schema_failure_cache_time = 30 * time.Second
+
+// the table meta cached by table names, the zero value is an empty cache
+type ots_schema_cache struct {
+	lock     sync.Mutex
+	tables   map[string]*OTSTableMeta
+	failures map[string]time.Time // the time when DescribeTable of the table failed
+}
+
+func (o *ots_schema_cache) set(table_meta *OTSTableMeta) {
+	if table_meta == nil {
+		return
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.tables == nil {
+		o.tables = map[string]*OTSTableMeta{}
+	}
+	o.tables[table_meta.TableName] = table_meta
+	delete(o.failures, table_meta.TableName)
+}
+
+func (o *ots_schema_cache) get(table_name string) *OTSTableMeta {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.tables[table_name]
+}
+
+func (o *ots_schema_cache) invalidate(table_name string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	delete(o.tables, table_name)
+	delete(o.failures, table_name)
+}
+
+// remember that the table meta of table_name can not be got for a while
+func (o *ots_schema_cache) set_failure(table_name string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.failures == nil {
+		o.failures = map[string]time.Time{}
+	}
+	o.failures[table_name] = time.Now()
+}
+
+// whether the table meta of table_name failed to be got recently
+func (o *ots_schema_cache) failed(table_name string) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	failure_time, ok := o.failures[table_name]
+	if ok && time.Since(failure_time) >= schema_failure_cache_time {
+		delete(o.failures, table_name)
+		return false
+	}
+
+	return ok
+}
+
+// the cache of table meta, the cache of Validator if it is set
+func (o *OTSClient) _schema_cache() *ots_schema_cache {
+	if o.Validator != nil {
+		return &o.Validator.tables
+	}
+
+	return o.schemas
+}
+
+// keep the cached table meta up to date with the result of call
+func (o *OTSClient) _schema_observe(call *OTSCall, response interface{}, ots_service_error *OTSServiceError) {
+	cache := o._schema_cache()
+	if cache == nil {
+		return
+	}
+
+	if ots_service_error != nil {
+		if errors.Is(ots_service_error, ErrObjectNotExist) {
+			for _, table_name := range strings.Split(call._get_table_name(), ",") {
+				cache.invalidate(table_name)
+			}
+		}
+		return
+	}
+
+	switch call.Api {
+	case "CreateTable":
+		if table_meta, ok := call.Args[0].(*OTSTableMeta); ok {
+			cache.set(table_meta)
+		}
+	case "DescribeTable":
+		if describe_response, ok := response.(*OTSDescribeTableResponse); ok && describe_response.TableMeta != nil {
+			cache.set(describe_response.TableMeta)
+		}
+	case "DeleteTable":
+		cache.invalidate(call.Args[0].(string))
+	}
+}
+
+// the schema of primary key cached in Validator or OTSClient, DescribeTable is called if it is not cached,
+// an error other than OTSObjectNotExist is returned as a client error
+func (o *OTSClient) _get_schema_of_primary_key(ctx context.Context, api_name string, table_name string) (OTSSchemaOfPrimaryKey, *OTSError) {
+	if cache := o._schema_cache(); cache != nil {
+		if table_meta := cache.get(table_name); table_meta != nil {
+			return table_meta.SchemaOfPrimaryKey, nil
+		}
+	}
+
+	describe_response, ots_err := o.DescribeTableWithContext(ctx, table_name)
+	if ots_err != nil {
+		if errors.Is(ots_err, ErrObjectNotExist) || ctx.Err() != nil {
+			return nil, ots_err
+		}
+		return nil, new(OTSError).SetClientMessage("[%s] the schema of table %s is unknown, use *OTSOrderedPrimaryKey for the primary key with more than one column: %s", api_name, table_name, ots_err)
+	}
+	if describe_response.TableMeta == nil {
+		return nil, new(OTSError).SetClientMessage("[%s] the schema of table %s is unknown, use *OTSOrderedPrimaryKey for the primary key with more than one column", api_name, table_name)
+	}
+
+	return describe_response.TableMeta.SchemaOfPrimaryKey, nil
+}
+
+// OTSPrimaryKey and *OTSPrimaryKey in args are sent in the order of the cached schema,
+// they are sorted by names if the schema is unknown, args is not modified
+func (o *OTSClient) _order_primary_keys(api_name string, args []interface{}) []interface{} {
+	cache := o._schema_cache()
+	if cache == nil {
+		return args
+	}
+
+	order := func(table_name string, primary_key OTSPrimaryKeyInterface) OTSPrimaryKeyInterface {
+		var pk OTSPrimaryKey
+		switch v := primary_key.(type) {
+		case *OTSPrimaryKey:
+			if v == nil {
+				return primary_key
+			}
+			pk = *v
+		case OTSPrimaryKey:
+			pk = v
+		default:
+			return primary_key
+		}
+		table_meta := cache.get(table_name)
+		if table_meta == nil {
+			return primary_key
+		}
+		ordered, err := NewPrimaryKeyFromSchema(table_meta.SchemaOfPrimaryKey, pk)
+		if err != nil {
+			// the columns are checked by Validator or the server
+			return primary_key
+		}
+		return ordered
+	}
+
+	ordered_args := make([]interface{}, len(args))
+	copy(ordered_args, args)
+	order_arg := func(table_name string, i int) {
+		if primary_key, ok := args[i].(OTSPrimaryKeyInterface); ok {
+			ordered_args[i] = order(table_name, primary_key)
+		}
+	}
+	switch api_name {
+	case "GetRow":
+		order_arg(args[0].(string), 1)
+	case "PutRow", "UpdateRow", "DeleteRow":
+		order_arg(args[0].(string), 2)
+	case "GetRange":
+		order_arg(args[0].(string), 2)
+		order_arg(args[0].(string), 3)
+	case "BatchGetRow":
+		batch_list, _ := args[0].(*OTSBatchGetRowRequest)
+		if batch_list == nil {
+			return args
+		}
+		ordered_list := make(OTSBatchGetRowRequest, len(*batch_list))
+		for i, table := range *batch_list {
+			if table_meta := cache.get(table.TableName); table.SchemaOfPrimaryKey == nil && table_meta != nil {
+				table.SchemaOfPrimaryKey = table_meta.SchemaOfPrimaryKey
+			}
+			ordered_list[i] = table
+		}
+		ordered_args[0] = &ordered_list
+	case "BatchWriteRow":
+		batch_list, _ := args[0].(*OTSBatchWriteRowRequest)
+		if batch_list == nil {
+			return args
+		}
+		ordered_list := make(OTSBatchWriteRowRequest, len(*batch_list))
+		for i, table := range *batch_list {
+			table.PutRows = append(OTSPutRows(nil), table.PutRows...)
+			for j := range table.PutRows {
+				table.PutRows[j].PrimaryKey = order(table.TableName, table.PutRows[j].PrimaryKey)
+			}
+			table.UpdateRows = append(OTSUpdateRows(nil), table.UpdateRows...)
+			for j := range table.UpdateRows {
+				table.UpdateRows[j].PrimaryKey = order(table.TableName, table.UpdateRows[j].PrimaryKey)
+			}
+			table.DeleteRows = append(OTSDeleteRows(nil), table.DeleteRows...)
+			for j := range table.DeleteRows {
+				table.DeleteRows[j].PrimaryKey = order(table.TableName, table.DeleteRows[j].PrimaryKey)
+			}
+			ordered_list[i] = table
+		}
+		ordered_args[0] = &ordered_list
+	}
+
+	return ordered_args
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// scripted test server for ots2
+package goots
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+	. "github.com/GiterLab/goots/protobuf"
+	"github.com/golang/protobuf/proto"
+)
+
+// handler of the test server, returns the http status and the response message (*Error if the status is not 200)
+type test_handler func(api_name string, body []byte) (int, proto.Message)
+
+// create a client with a test server which signs the responses of handler the way the client checks them
+func newWithTestServer(t *testing.T, handler test_handler) (*OTSClient, *httptest.Server) {
+	OTSErrorPanicMode = false
+	var client *OTSClient
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		status, message := handler(strings.TrimPrefix(r.URL.Path, "/"), body)
+		data, err := proto.Marshal(message)
+		if err != nil {
+			t.Errorf("marshal response of %s: %s", r.URL.Path, err)
+			status, data = 500, nil
+		}
+
+		headers := DictString{
+			"x-ots-contentmd5":  base64Encode(md5Encode(data)),
+			"x-ots-requestid":   "00000000-0000-0000-0000-000000000000",
+			"x-ots-date":        time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"),
+			"x-ots-contenttype": "protocol buffer",
+		}
+		signature, _ := client.protocol._make_response_signature(r.URL.Path, headers)
+		for k, v := range headers {
+			w.Header().Set(k, v.(string))
+		}
+		w.Header().Set("Authorization", "OTS "+client.AccessId+":"+signature)
+		w.WriteHeader(status)
+		w.Write(data)
+	}))
+
+	var err error
+	client, err = New(server.URL, "test_accessid", "test_accesskey", "TestInstance")
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return client, server
+}
+
+// the error response of test handler
+func test_error(status int, code, message string) (int, proto.Message) {
+	return status, &Error{Code: proto.String(code), Message: proto.String(message)}
+}
+
+// the INTEGER primary key columns gid and uid
+func test_primary_key(gid, uid int64) []*Column {
+	return []*Column{
+		{Name: proto.String("gid"), Value: &ColumnValue{Type: ColumnType_INTEGER.Enum(), VInt: proto.Int64(gid)}},
+		{Name: proto.String("uid"), Value: &ColumnValue{Type: ColumnType_INTEGER.Enum(), VInt: proto.Int64(uid)}},
+	}
+}
+
+func test_consumed(read, write int32) *ConsumedCapacity {
+	return &ConsumedCapacity{CapacityUnit: &CapacityUnit{Read: proto.Int32(read), Write: proto.Int32(write)}}
+}
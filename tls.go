@@ -0,0 +1,54 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// TLS config for ots2
+package goots
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+)
+
+// 说明：根据PEM 格式的证书文件创建TLS 配置，用于OTSClient.TLSConfig。
+//
+// 		``root_ca_file``是校验服务端证书的根证书文件，为空时使用系统根证书。
+// 		``cert_file``和``key_file``是客户端证书和私钥文件，为空时不使用客户端证书。
+// 		``min_version``是允许的最低TLS 版本（如 tls.VersionTLS12），为0 时为TLS 1.2。
+//
+// 		返回：TLS 配置。
+// 		      错误信息。
+//
+// 		示例：
+//
+// 		tls_config, err := NewTLSConfig("ca.pem", "", "", tls.VersionTLS12)
+// 		ots_client, err := New(ENDPOINT, ACCESSID, ACCESSKEY, INSTANCENAME, 50, 50, "ots2-client", "utf8", tls_config)
+//
+func NewTLSConfig(root_ca_file, cert_file, key_file string, min_version uint16) (*tls.Config, error) {
+	tls_config := &tls.Config{MinVersion: min_version}
+	if tls_config.MinVersion == 0 {
+		tls_config.MinVersion = tls.VersionTLS12
+	}
+
+	if root_ca_file != "" {
+		pem, err := os.ReadFile(root_ca_file)
+		if err != nil {
+			return nil, new(OTSClientError).SetErrorMessage("read root CA file error: %s", err)
+		}
+		tls_config.RootCAs = x509.NewCertPool()
+		if !tls_config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, new(OTSClientError).SetErrorMessage("no certificate found in root CA file %s", root_ca_file)
+		}
+	}
+
+	if cert_file != "" || key_file != "" {
+		cert, err := tls.LoadX509KeyPair(cert_file, key_file)
+		if err != nil {
+			return nil, new(OTSClientError).SetErrorMessage("load client certificate error: %s", err)
+		}
+		tls_config.Certificates = []tls.Certificate{cert}
+	}
+
+	return tls_config, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test TLS config for ots2
+package goots
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GiterLab/goots/emulator"
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_client_tls(t *testing.T) {
+	OTSErrorPanicMode = false
+	server := httptest.NewUnstartedServer(emulator.New("test_accessid", "test_accesskey", "TestInstance"))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	// verify the server certificate by default
+	client, err := New(server.URL, "test_accessid", "test_accesskey", "TestInstance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+	if _, ots_err := client.ListTable(); ots_err == nil {
+		t.Fatal("self-signed certificate should be rejected by default")
+	}
+
+	// explicit insecure mode
+	if err = client.SetWithError(DictString{"TLSConfig": &tls.Config{InsecureSkipVerify: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// custom root CA
+	ca_file := filepath.Join(t.TempDir(), "ca.pem")
+	ca_pem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err = os.WriteFile(ca_file, ca_pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tls_config, err := NewTLSConfig(ca_file, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tls_config.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("MinVersion should be TLS 1.2, not %x", tls_config.MinVersion)
+	}
+	client, err = New(server.URL, "test_accessid", "test_accesskey", "TestInstance", 50, 50, DEFAULT_LOGGER_NAME, DEFAULT_ENCODING, tls_config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ots_err := client.ListTable(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// minimum TLS version
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+	if err = client.SetWithError(DictString{"TLSConfig": &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS13}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ots_err := client.ListTable(); ots_err == nil {
+		t.Fatal("server with TLS 1.2 should be rejected when MinVersion is TLS 1.3")
+	}
+
+	if _, err = NewTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), "", "", 0); err == nil {
+		t.Fatal("missing root CA file should fail")
+	}
+}
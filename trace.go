@@ -0,0 +1,105 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Tracing for ots2
+package goots
+
+import (
+	"context"
+	"time"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+// 链路追踪接口，用法与OpenTelemetry 的trace.Tracer 相同，可以通过OTSClient.Tracer 为每个OTSClient 单独设置
+//
+// 		每次API 调用创建一个名为 "OTS <API名>" 的span，其中每次HTTP 请求（包括重试）创建一个名为
+// 		"OTS <API名> attempt" 的子span。父span 从调用API 时传入的ctx 中获取，即XxxWithContext 的ctx。
+//
+// 		OpenTelemetry 的适配示例：
+//
+// 		type otel_tracer struct{ tracer trace.Tracer }
+// 		type otel_span struct{ span trace.Span }
+//
+// 		func (t otel_tracer) Start(ctx context.Context, span_name string) (context.Context, ots2.OTSSpan) {
+// 			ctx, span := t.tracer.Start(ctx, span_name, trace.WithSpanKind(trace.SpanKindClient))
+// 			return ctx, otel_span{span}
+// 		}
+// 		func (s otel_span) SetAttributes(kv ...interface{}) {
+// 			for i := 0; i+1 < len(kv); i += 2 {
+// 				s.span.SetAttributes(attribute.String(kv[i].(string), fmt.Sprint(kv[i+1])))
+// 			}
+// 		}
+// 		func (s otel_span) RecordError(err error) { s.span.RecordError(err); s.span.SetStatus(codes.Error, err.Error()) }
+// 		func (s otel_span) End()                  { s.span.End() }
+//
+// 		ots_client.Tracer = otel_tracer{otel.Tracer("github.com/GiterLab/goots")}
+//
+type OTSTracer interface {
+	Start(ctx context.Context, span_name string) (context.Context, OTSSpan)
+}
+
+// span 的属性：
+// 		ots.api, ots.table, ots.instance          API 名、表名（多个表以逗号分隔）和实例名
+// 		ots.retry_times                           重试次数，attempt span 中为第几次重试
+// 		ots.request_id, http.status_code          最后一次请求的x-ots-requestid 和HTTP 状态码
+// 		ots.error_code                            错误号
+// 		ots.read_cu, ots.write_cu                 消耗的读写CapacityUnit
+type OTSSpan interface {
+	// kv 为交替出现的键值对，键为string，值为string、int、int64、int32 或bool
+	SetAttributes(kv ...interface{})
+	RecordError(err error)
+	End()
+}
+
+// 不记录任何信息的OTSSpan
+type OTSNopSpan struct{}
+
+func (OTSNopSpan) SetAttributes(kv ...interface{}) {}
+func (OTSNopSpan) RecordError(err error)           {}
+func (OTSNopSpan) End()                            {}
+
+func (o *OTSClient) _start_span(ctx context.Context, span_name string) (context.Context, OTSSpan) {
+	if o.Tracer == nil {
+		return ctx, OTSNopSpan{}
+	}
+
+	return o.Tracer.Start(ctx, span_name)
+}
+
+// annotate and end the span of an API call
+func (o *OTSClient) _end_call_span(span OTSSpan, call *OTSCall, response interface{}, ots_service_error *OTSServiceError) {
+	defer span.End()
+
+	span.SetAttributes("ots.api", call.Api, "ots.table", call._get_table_name(), "ots.instance", o.InstanceName)
+	if n := len(call.Attempts); n > 0 {
+		last := call.Attempts[n-1]
+		span.SetAttributes("ots.retry_times", n-1, "ots.request_id", last.RequestId, "http.status_code", last.HttpStatus)
+	}
+	if ots_service_error != nil {
+		span.SetAttributes("ots.error_code", ots_service_error.Code)
+		span.RecordError(ots_service_error)
+		return
+	}
+
+	read, write := int64(0), int64(0)
+	_foreach_consumed(call, response, func(table_name string, read_cu, write_cu int32) {
+		read += int64(read_cu)
+		write += int64(write_cu)
+	})
+	span.SetAttributes("ots.read_cu", read, "ots.write_cu", write)
+}
+
+// record an HTTP attempt in call, then annotate and end its span
+func (o *OTSClient) _end_attempt(call *OTSCall, span OTSSpan, retry_times int, start_time time.Time, status int, headers DictString, ots_service_error *OTSServiceError) {
+	call._add_attempt(retry_times, start_time, status, headers, ots_service_error)
+	attempt := call.Attempts[len(call.Attempts)-1]
+
+	span.SetAttributes("ots.api", call.Api, "ots.retry_times", retry_times, "ots.request_id", attempt.RequestId, "http.status_code", status)
+	if ots_service_error != nil {
+		span.SetAttributes("ots.error_code", ots_service_error.Code)
+		span.RecordError(attempt.Error)
+	}
+	span.End()
+}
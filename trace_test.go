@@ -0,0 +1,102 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test tracing for ots2
+package goots
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+type span_key struct{}
+
+type recording_span struct {
+	name       string
+	parent     *recording_span
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recording_span) SetAttributes(kv ...interface{}) {
+	for i := 0; i+1 < len(kv); i += 2 {
+		s.attributes[kv[i].(string)] = kv[i+1]
+	}
+}
+func (s *recording_span) RecordError(err error) { s.err = err }
+func (s *recording_span) End()                  { s.ended = true }
+
+type recording_tracer struct {
+	lock  sync.Mutex
+	spans []*recording_span
+}
+
+func (t *recording_tracer) Start(ctx context.Context, span_name string) (context.Context, OTSSpan) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	span := &recording_span{name: span_name, attributes: map[string]interface{}{}}
+	span.parent, _ = ctx.Value(span_key{}).(*recording_span)
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, span_key{}, span), span
+}
+
+func Test_tracer(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	client.RetryPolicy = OTSNoDelayRetryPolicy
+	tracer := &recording_tracer{}
+	client.Tracer = tracer
+
+	calls := 0
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		calls += 1
+		if calls == 1 {
+			return 503, "OTSServerBusy", "Server is busy."
+		}
+		return 0, "", ""
+	}
+	root := &recording_span{name: "root", attributes: map[string]interface{}{}}
+	ctx := context.WithValue(context.Background(), span_key{}, root)
+	primary_key := &OTSPrimaryKey{"gid": 1, "uid": 101}
+	if _, ots_err := client.PutRowWithContext(ctx, "myTable", OTSCondition_IGNORE, primary_key, &OTSAttribute{"age": 20}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("expect 1 call span and 2 attempt spans, not %d", len(tracer.spans))
+	}
+	call_span := tracer.spans[0]
+	if call_span.name != "OTS PutRow" || call_span.parent != root || !call_span.ended ||
+		call_span.attributes["ots.table"] != "myTable" || call_span.attributes["ots.api"] != "PutRow" ||
+		call_span.attributes["ots.retry_times"] != 1 || call_span.attributes["http.status_code"] != 200 ||
+		call_span.attributes["ots.write_cu"] != int64(1) || call_span.attributes["ots.request_id"] == "" {
+		t.Fatalf("unexpected call span: %+v", call_span)
+	}
+	for i, attempt_span := range tracer.spans[1:] {
+		if attempt_span.name != "OTS PutRow attempt" || attempt_span.parent != call_span || !attempt_span.ended ||
+			attempt_span.attributes["ots.retry_times"] != i {
+			t.Fatalf("unexpected attempt span: %+v", attempt_span)
+		}
+	}
+	if tracer.spans[1].attributes["ots.error_code"] != "OTSServerBusy" || tracer.spans[1].attributes["http.status_code"] != 503 || tracer.spans[1].err == nil {
+		t.Fatalf("unexpected failed attempt span: %+v", tracer.spans[1])
+	}
+	if tracer.spans[2].err != nil {
+		t.Fatalf("unexpected succeeded attempt span: %+v", tracer.spans[2])
+	}
+
+	// the error of call
+	tracer.spans = nil
+	if _, ots_err := client.DescribeTable("notExistTable"); ots_err == nil {
+		t.Fatal("describe a missing table should fail")
+	}
+	if len(tracer.spans) != 2 || tracer.spans[0].err == nil || tracer.spans[0].attributes["ots.error_code"] != "OTSObjectNotExist" ||
+		tracer.spans[0].attributes["ots.table"] != "notExistTable" {
+		t.Fatalf("unexpected spans: %+v", tracer.spans)
+	}
+}
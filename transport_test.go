@@ -0,0 +1,94 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test connection pool for ots2
+package goots
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GiterLab/goots/emulator"
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_client_transport(t *testing.T) {
+	OTSErrorPanicMode = false
+	ots_emulator := emulator.New("test_accessid", "test_accesskey", "TestInstance")
+	var conns int32
+	server := httptest.NewUnstartedServer(ots_emulator)
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client, err := New(server.URL, "test_accessid", "test_accesskey", "TestInstance", 5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.CloseIdleConnections()
+	transport := client.transport
+	if transport == nil || transport.MaxConnsPerHost != 2 || transport.MaxIdleConnsPerHost != 2 {
+		t.Fatalf("unexpected transport: %v", transport)
+	}
+
+	// keep-alive
+	for i := 0; i < 5; i++ {
+		if _, ots_err := client.ListTable(); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("sequential requests should reuse one connection, not %d", n)
+	}
+
+	// MaxConnection limits the active connections
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ots_err := client.ListTable(); ots_err != nil {
+				t.Error(ots_err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&conns); n > 2 {
+		t.Fatalf("connections should not exceed MaxConnection, got %d", n)
+	}
+	// the shared transport is not modified by the requests
+	if transport.Dial != nil || transport.Proxy != nil || transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("transport should not be modified by the requests: %v", transport)
+	}
+
+	// another client has its own transport
+	other, err := New(server.URL, "test_accessid", "test_accesskey", "TestInstance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.transport == transport || client.transport != transport {
+		t.Fatal("each client should own its transport")
+	}
+
+	// the transport is recreated when the pool settings are changed
+	if err = client.SetWithError(DictString{"MaxConnection": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if client.transport == transport || client.transport.MaxConnsPerHost != 4 {
+		t.Fatalf("transport should be recreated, MaxConnsPerHost: %d", client.transport.MaxConnsPerHost)
+	}
+	if client._get_socket_timeout() != 5*time.Second {
+		t.Fatalf("unexpected socket timeout: %s", client._get_socket_timeout())
+	}
+}
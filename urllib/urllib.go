@@ -7,6 +7,7 @@ package urllib
 
 import (
 	"bytes"
+	"context"
 	"compress/gzip"
 	"crypto/tls"
 	"encoding/json"
@@ -202,6 +203,14 @@ func (b *HttpRequest) SetTLSClientConfig(config *tls.Config) *HttpRequest {
 	return b
 }
 
+// SetContext sets the context of request, the request is canceled when the ctx is done.
+func (b *HttpRequest) SetContext(ctx context.Context) *HttpRequest {
+	if ctx != nil {
+		b.req = b.req.WithContext(ctx)
+	}
+	return b
+}
+
 // Header add header item string in request.
 func (b *HttpRequest) Header(key, value string) *HttpRequest {
 	b.req.Header.Set(key, value)
@@ -237,7 +246,9 @@ func (b *HttpRequest) SetCookie(cookie *http.Cookie) *HttpRequest {
 	return b
 }
 
-// Set transport to
+// Set transport to, the transport is used as it is and may be shared by
+// concurrent requests, so TlsClientConfig, Proxy and ConnectTimeout of
+// the settings are not applied to it.
 func (b *HttpRequest) SetTransport(transport http.RoundTripper) *HttpRequest {
 	b.setting.Transport = transport
 	return b
@@ -394,19 +405,6 @@ func (b *HttpRequest) SendOut() (*http.Response, error) {
 			Proxy:           b.setting.Proxy,
 			Dial:            TimeoutDialer(b.setting.ConnectTimeout),
 		}
-	} else {
-		// if b.transport is *http.Transport then set the settings.
-		if t, ok := trans.(*http.Transport); ok {
-			if t.TLSClientConfig == nil {
-				t.TLSClientConfig = b.setting.TlsClientConfig
-			}
-			if t.Proxy == nil {
-				t.Proxy = b.setting.Proxy
-			}
-			if t.Dial == nil {
-				t.Dial = TimeoutDialer(b.setting.ConnectTimeout)
-			}
-		}
 	}
 
 	var jar http.CookieJar = nil
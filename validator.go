@@ -0,0 +1,481 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Client-side request validation for ots2
+package goots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+// OTS 的服务限制，见OTS 文档；BatchGetRow 和BatchWriteRow 的行数限制见BATCH_GET_ROW_MAX_ROWS 和BATCH_WRITE_ROW_MAX_ROWS
+const (
+	OTS_MAX_TABLE_NAME_LENGTH         = 255
+	OTS_MAX_COLUMN_NAME_LENGTH        = 255
+	OTS_MAX_PRIMARY_KEY_COLUMNS       = 4
+	OTS_MAX_PRIMARY_KEY_STRING_LENGTH = 1024
+	OTS_MAX_PRIMARY_KEY_BINARY_LENGTH = 1024
+	OTS_MAX_ATTRIBUTE_STRING_LENGTH   = 64 * 1024
+	OTS_MAX_ATTRIBUTE_BINARY_LENGTH   = 64 * 1024
+	OTS_MAX_COLUMNS_PER_ROW           = 1024
+	OTS_MAX_ROW_SIZE                  = 1024 * 1024
+)
+
+// the names of table and column
+var name_regexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// 请求的客户端校验，通过OTSClient.Validator 设置，为nil 时不校验
+//
+// 		请求在编码前按OTS 的服务限制检查表名、列名、列值大小、每行的列数和大小、
+// 		BatchGetRow 和BatchWriteRow 的行数，以及UpdateRow 的属性列是否为空，失败时返回ClientError，不会发送请求。
+// 		CheckSchema 为true 时还会按表的主键检查主键列的列名和类型，*OTSPrimaryKey 按列名对应，
+// 		*OTSOrderedPrimaryKey 还会检查列的顺序，表结构来自缓存，
+// 		缓存中没有时调用DescribeTable 获取，CreateTable 和DescribeTable 成功后更新，
+// 		DeleteTable 成功或返回OTSObjectNotExist 后清除；DescribeTable 失败时（如没有权限）
+// 		30 秒内不再检查该表的主键。
+//
+// 		缓存按表名保存，每个OTSClient 应使用单独的OTSValidator。
+//
+// 		示例：
+//
+// 		ots_client.Validator = NewValidator()
+// 		_, ots_err := ots_client.PutRow("myTable", OTSCondition_IGNORE, primary_key, attribute_columns)
+// 		if ots_err != nil && ots_err.ClientError != nil {
+// 			// 请求没有发送
+// 		}
+//
+type OTSValidator struct {
+	// 是否按表的主键检查主键列
+	CheckSchema bool
+
+	tables ots_schema_cache
+}
+
+func NewValidator() *OTSValidator {
+	return &OTSValidator{
+		CheckSchema: true,
+	}
+}
+
+// 说明：设置缓存的表结构，如已知表结构时避免调用DescribeTable。
+func (o *OTSValidator) SetTableMeta(table_meta *OTSTableMeta) {
+	o.tables.set(table_meta)
+}
+
+// 说明：返回缓存的表结构，没有时返回nil。
+func (o *OTSValidator) TableMeta(table_name string) *OTSTableMeta {
+	return o.tables.get(table_name)
+}
+
+// 说明：清除缓存的表结构，下次请求时重新获取。
+func (o *OTSValidator) Invalidate(table_name string) {
+	o.tables.invalidate(table_name)
+}
+
+// validate the arguments of call, get_schema returns nil if the schema is unknown
+func (o *OTSValidator) _validate(call *OTSCall, get_schema func(table_name string) (OTSSchemaOfPrimaryKey, error)) error {
+	args := call.Args
+	switch call.Api {
+	case "CreateTable":
+		table_meta, _ := args[0].(*OTSTableMeta)
+		return _validate_table_meta(table_meta)
+
+	case "DeleteTable", "DescribeTable", "UpdateTable":
+		return _validate_table_name(args[0].(string))
+
+	case "GetRow":
+		return o._validate_row(args[0].(string), get_schema, args[1], nil, args[2], false)
+
+	case "PutRow":
+		attribute_columns, _ := args[3].(*OTSAttribute)
+		if attribute_columns == nil {
+			return o._validate_row(args[0].(string), get_schema, args[2], nil, nil, false)
+		}
+		return o._validate_row(args[0].(string), get_schema, args[2], DictString(*attribute_columns), nil, false)
+
+	case "UpdateRow":
+		update_of_attribute_columns, _ := args[3].(*OTSUpdateOfAttribute)
+		if update_of_attribute_columns == nil {
+			return errors.New("update_of_attribute_columns should not be empty")
+		}
+		put, deleted, err := _get_update_columns(*update_of_attribute_columns)
+		if err != nil {
+			return err
+		}
+		return o._validate_row(args[0].(string), get_schema, args[2], put, deleted, false)
+
+	case "DeleteRow":
+		return o._validate_row(args[0].(string), get_schema, args[2], nil, nil, false)
+
+	case "BatchGetRow":
+		return o._validate_batch_get_row(args[0].(*OTSBatchGetRowRequest), get_schema)
+
+	case "BatchWriteRow":
+		return o._validate_batch_write_row(args[0].(*OTSBatchWriteRowRequest), get_schema)
+
+	case "GetRange":
+		table_name := args[0].(string)
+		if err := o._validate_row(table_name, get_schema, args[2], nil, args[4], true); err != nil {
+			return errors.New(fmt.Sprintf("inclusive_start_primary_key: %s", err))
+		}
+		if err := o._validate_row(table_name, get_schema, args[3], nil, nil, true); err != nil {
+			return errors.New(fmt.Sprintf("exclusive_end_primary_key: %s", err))
+		}
+	}
+
+	return nil
+}
+
+func (o *OTSValidator) _validate_batch_get_row(batch_list *OTSBatchGetRowRequest, get_schema func(table_name string) (OTSSchemaOfPrimaryKey, error)) error {
+	rows := 0
+	for _, table := range *batch_list {
+		if len(table.Rows) == 0 {
+			return errors.New(fmt.Sprintf("no row to get in table %s", table.TableName))
+		}
+		for i := range table.Rows {
+			if err := o._validate_row(table.TableName, get_schema, table.Rows[i], nil, table.ColumnsToGet, false); err != nil {
+				return errors.New(fmt.Sprintf("row %d of table %s: %s", i, table.TableName, err))
+			}
+		}
+		rows += len(table.Rows)
+	}
+	if rows == 0 {
+		return errors.New("no row to get")
+	}
+	if rows > BATCH_GET_ROW_MAX_ROWS {
+		return errors.New(fmt.Sprintf("BatchGetRow gets at most %d rows, not %d", BATCH_GET_ROW_MAX_ROWS, rows))
+	}
+
+	return nil
+}
+
+func (o *OTSValidator) _validate_batch_write_row(batch_list *OTSBatchWriteRowRequest, get_schema func(table_name string) (OTSSchemaOfPrimaryKey, error)) error {
+	rows := 0
+	for _, table := range *batch_list {
+		table_rows := len(table.PutRows) + len(table.UpdateRows) + len(table.DeleteRows)
+		if table_rows == 0 {
+			return errors.New(fmt.Sprintf("no row to write in table %s", table.TableName))
+		}
+		for i, item := range table.PutRows {
+			if err := o._validate_row(table.TableName, get_schema, item.PrimaryKey, DictString(item.AttributeColumns), nil, false); err != nil {
+				return errors.New(fmt.Sprintf("put row %d of table %s: %s", i, table.TableName, err))
+			}
+		}
+		for i, item := range table.UpdateRows {
+			put, deleted, err := _get_update_columns(item.UpdateOfAttributeColumns)
+			if err == nil {
+				err = o._validate_row(table.TableName, get_schema, item.PrimaryKey, put, deleted, false)
+			}
+			if err != nil {
+				return errors.New(fmt.Sprintf("update row %d of table %s: %s", i, table.TableName, err))
+			}
+		}
+		for i, item := range table.DeleteRows {
+			if err := o._validate_row(table.TableName, get_schema, item.PrimaryKey, nil, nil, false); err != nil {
+				return errors.New(fmt.Sprintf("delete row %d of table %s: %s", i, table.TableName, err))
+			}
+		}
+		rows += table_rows
+	}
+	if rows == 0 {
+		return errors.New("no row to write")
+	}
+	if rows > BATCH_WRITE_ROW_MAX_ROWS {
+		return errors.New(fmt.Sprintf("BatchWriteRow writes at most %d rows, not %d", BATCH_WRITE_ROW_MAX_ROWS, rows))
+	}
+
+	return nil
+}
+
+// validate a row, column_names is the columns to get or to delete, INF_MIN and INF_MAX are allowed for GetRange
+func (o *OTSValidator) _validate_row(table_name string, get_schema func(table_name string) (OTSSchemaOfPrimaryKey, error),
+	primary_key interface{}, attribute_columns DictString, column_names interface{}, allow_inf bool) error {
+	if err := _validate_table_name(table_name); err != nil {
+		return err
+	}
+
+	pk, _ := primary_key.(OTSPrimaryKeyInterface)
+	if pk == nil || _is_nil_primary_key(pk) {
+		return errors.New("primary key should not be nil")
+	}
+	list := pk.PrimaryKeyList()
+	if o.CheckSchema {
+		schema_of_primary_key, err := get_schema(table_name)
+		if err != nil {
+			return err
+		}
+		if schema_of_primary_key != nil {
+			if err = _validate_primary_key_schema(schema_of_primary_key, pk); err != nil {
+				return err
+			}
+		}
+	}
+
+	row_size := 0
+	if len(list) > OTS_MAX_PRIMARY_KEY_COLUMNS {
+		return errors.New(fmt.Sprintf("primary key has at most %d columns, not %d", OTS_MAX_PRIMARY_KEY_COLUMNS, len(list)))
+	}
+	for _, column := range list {
+		size, err := _validate_column(column.K, column.V, OTS_MAX_PRIMARY_KEY_STRING_LENGTH, OTS_MAX_PRIMARY_KEY_BINARY_LENGTH, allow_inf)
+		if err != nil {
+			return errors.New(fmt.Sprintf("primary key column %s", err))
+		}
+		row_size += size
+	}
+
+	for name, value := range attribute_columns {
+		size, err := _validate_column(name, value, OTS_MAX_ATTRIBUTE_STRING_LENGTH, OTS_MAX_ATTRIBUTE_BINARY_LENGTH, false)
+		if err != nil {
+			return errors.New(fmt.Sprintf("attribute column %s", err))
+		}
+		row_size += size
+	}
+
+	names := _get_column_names(column_names)
+	for _, name := range names {
+		if err := _validate_column_name(name); err != nil {
+			return err
+		}
+	}
+
+	columns := len(list) + len(attribute_columns) + len(names)
+	if columns > OTS_MAX_COLUMNS_PER_ROW {
+		return errors.New(fmt.Sprintf("a row has at most %d columns, not %d", OTS_MAX_COLUMNS_PER_ROW, columns))
+	}
+	if row_size > OTS_MAX_ROW_SIZE {
+		return errors.New(fmt.Sprintf("the size of row should be at most %d bytes, not %d", OTS_MAX_ROW_SIZE, row_size))
+	}
+
+	return nil
+}
+
+// check the names, order and types of primary key columns, OTSPrimaryKey and *OTSPrimaryKey are matched by names
+func _validate_primary_key_schema(schema_of_primary_key OTSSchemaOfPrimaryKey, primary_key OTSPrimaryKeyInterface) error {
+	list := primary_key.PrimaryKeyList()
+	by_name := false
+	switch primary_key.(type) {
+	case *OTSPrimaryKey, OTSPrimaryKey:
+		by_name = true
+	}
+
+	schema_names := make([]string, len(schema_of_primary_key))
+	for i, column_schema := range schema_of_primary_key {
+		schema_names[i] = column_schema.GetName()
+	}
+	names := make([]string, len(list))
+	values := make(map[string]interface{}, len(list))
+	for i, column := range list {
+		names[i] = column.K
+		values[column.K] = column.V
+	}
+
+	for _, name := range names {
+		if schema_of_primary_key.Get(name) == nil {
+			return errors.New(fmt.Sprintf("primary key column %s is not in the schema (%s)", name, strings.Join(schema_names, ", ")))
+		}
+	}
+	if len(list) != len(schema_of_primary_key) {
+		return errors.New(fmt.Sprintf("primary key should have %d columns (%s), not %d", len(schema_of_primary_key), strings.Join(schema_names, ", "), len(list)))
+	}
+
+	for i, column_schema := range schema_of_primary_key {
+		name := column_schema.GetName()
+		if !by_name && list[i].K != name {
+			return errors.New(fmt.Sprintf("primary key columns should be in the order of schema (%s), not (%s)",
+				strings.Join(schema_names, ", "), strings.Join(names, ", ")))
+		}
+
+		column_value, err := NewColumnValue(values[name])
+		if err != nil {
+			return errors.New(fmt.Sprintf("primary key column %s: %s", name, err))
+		}
+		column_type, _ := column_schema.GetType().(string)
+		if !column_value.IsInfMin() && !column_value.IsInfMax() && column_value.Type() != column_type {
+			return errors.New(fmt.Sprintf("primary key column %s should be %s, not %s", name, column_type, column_value.Type()))
+		}
+	}
+
+	return nil
+}
+
+// validate the name and value of a column, return the size of column
+func _validate_column(name string, value interface{}, max_string_length, max_binary_length int, allow_inf bool) (int, error) {
+	if err := _validate_column_name(name); err != nil {
+		return 0, err
+	}
+
+	column_value, err := NewColumnValue(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("%s: %s", name, err))
+	}
+
+	size := len(name)
+	switch column_value.Type() {
+	case OTSColumnType_INF_MIN_NAME, OTSColumnType_INF_MAX_NAME:
+		if !allow_inf {
+			return 0, errors.New(fmt.Sprintf("%s: %s is only allowed in GetRange", name, column_value.Type()))
+		}
+	case OTSColumnType_STRING:
+		v, _ := column_value.AsString()
+		if len(v) > max_string_length {
+			return 0, errors.New(fmt.Sprintf("%s: the length of STRING should be at most %d bytes, not %d", name, max_string_length, len(v)))
+		}
+		size += len(v)
+	case OTSColumnType_BINARY:
+		v, _ := column_value.AsBinary()
+		if len(v) > max_binary_length {
+			return 0, errors.New(fmt.Sprintf("%s: the length of BINARY should be at most %d bytes, not %d", name, max_binary_length, len(v)))
+		}
+		size += len(v)
+	case OTSColumnType_BOOLEAN:
+		size += 1
+	default:
+		size += 8
+	}
+
+	return size, nil
+}
+
+func _validate_table_name(table_name string) error {
+	if len(table_name) > OTS_MAX_TABLE_NAME_LENGTH {
+		return errors.New(fmt.Sprintf("the length of table name should be at most %d, not %d", OTS_MAX_TABLE_NAME_LENGTH, len(table_name)))
+	}
+	if !name_regexp.MatchString(table_name) {
+		return errors.New(fmt.Sprintf("invalid table name %q, it should start with a letter or '_' and contain only letters, digits and '_'", table_name))
+	}
+
+	return nil
+}
+
+func _validate_column_name(name string) error {
+	if len(name) > OTS_MAX_COLUMN_NAME_LENGTH {
+		return errors.New(fmt.Sprintf("the length of column name should be at most %d, not %d", OTS_MAX_COLUMN_NAME_LENGTH, len(name)))
+	}
+	if !name_regexp.MatchString(name) {
+		return errors.New(fmt.Sprintf("invalid column name %q, it should start with a letter or '_' and contain only letters, digits and '_'", name))
+	}
+
+	return nil
+}
+
+func _validate_table_meta(table_meta *OTSTableMeta) error {
+	if table_meta == nil {
+		return errors.New("table_meta should not be nil")
+	}
+	if err := _validate_table_name(table_meta.TableName); err != nil {
+		return err
+	}
+
+	columns := len(table_meta.SchemaOfPrimaryKey)
+	if columns == 0 || columns > OTS_MAX_PRIMARY_KEY_COLUMNS {
+		return errors.New(fmt.Sprintf("primary key should have 1 to %d columns, not %d", OTS_MAX_PRIMARY_KEY_COLUMNS, columns))
+	}
+	for _, column_schema := range table_meta.SchemaOfPrimaryKey {
+		if err := _validate_column_name(column_schema.GetName()); err != nil {
+			return err
+		}
+		switch column_schema.GetType() {
+		case OTSColumnType_INTEGER, OTSColumnType_STRING, OTSColumnType_BINARY:
+		default:
+			return errors.New(fmt.Sprintf("the type of primary key column %s should be INTEGER, STRING or BINARY, not %v", column_schema.GetName(), column_schema.GetType()))
+		}
+	}
+
+	return nil
+}
+
+// the columns to put and the names of columns to delete in update_of_attribute_columns
+func _get_update_columns(update_of_attribute_columns OTSUpdateOfAttribute) (put DictString, deleted []string, err error) {
+	for operation, columns := range update_of_attribute_columns {
+		switch operation {
+		case OTSOperationType_PUT:
+			switch v := columns.(type) {
+			case OTSColumnsToPut:
+				put = DictString(v)
+			case DictString:
+				put = v
+			}
+		case OTSOperationType_DELETE:
+			deleted = _get_column_names(columns)
+		default:
+			return nil, nil, errors.New(fmt.Sprintf("operation type in update_of_attribute_columns should be PUT or DELETE, not %s", operation))
+		}
+	}
+	if len(put) == 0 && len(deleted) == 0 {
+		return nil, nil, errors.New("update_of_attribute_columns should not be empty")
+	}
+
+	return put, deleted, nil
+}
+
+func _get_column_names(column_names interface{}) []string {
+	switch v := column_names.(type) {
+	case *OTSColumnsToGet:
+		if v != nil {
+			return *v
+		}
+	case OTSColumnsToGet:
+		return v
+	case OTSColumnsToDelete:
+		return v
+	case []string:
+		return v
+	}
+
+	return nil
+}
+
+// validate the request of call before sending, a failed validation returns an error with ClientError
+func (o *OTSClient) _validate(ctx context.Context, call *OTSCall) *OTSServiceError {
+	if o.Validator == nil {
+		return nil
+	}
+
+	var schema_error *OTSServiceError
+	get_schema := func(table_name string) (OTSSchemaOfPrimaryKey, error) {
+		if table_meta := o.Validator.TableMeta(table_name); table_meta != nil {
+			return table_meta.SchemaOfPrimaryKey, nil
+		}
+		if o.Validator.tables.failed(table_name) {
+			// DescribeTable failed recently, skip the checks of schema
+			return nil, nil
+		}
+
+		describe_response, ots_err := o.DescribeTableWithContext(ctx, table_name)
+		if ots_err == nil && describe_response != nil && describe_response.TableMeta != nil {
+			return describe_response.TableMeta.SchemaOfPrimaryKey, nil
+		}
+		if ots_err != nil && errors.Is(ots_err, ErrObjectNotExist) {
+			// the table does not exist, the request would fail too
+			schema_error = ots_err.ServiceError
+			return nil, ots_err.ServiceError
+		}
+
+		// skip the checks of schema, e.g. DescribeTable is not allowed,
+		// and do not call DescribeTable of the table again for a while
+		if ctx.Err() == nil {
+			o.Validator.tables.set_failure(table_name)
+		}
+		return nil, nil
+	}
+
+	err := o.Validator._validate(call, get_schema)
+	if schema_error != nil {
+		return schema_error
+	}
+	if err != nil {
+		client_error := new(OTSClientError).SetErrorMessage("[%s] %s", call.Api, err)
+		ots_service_error := new(OTSServiceError).SetErrorMessage("%s", client_error.Message)
+		ots_service_error.Err = client_error
+		return ots_service_error
+	}
+
+	return nil
+}
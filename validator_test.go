@@ -0,0 +1,185 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test request validation for ots2
+package goots
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_validator(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	validator := NewValidator()
+	client.Validator = validator
+
+	requests := map[string]int{}
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		requests[api_name] += 1
+		return 0, "", ""
+	}
+
+	// the schema is cached by CreateTable
+	table_meta := &OTSTableMeta{
+		TableName: "orderTable",
+		SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{
+			{K: "uid", V: "INTEGER"},
+			{K: "gid", V: "STRING"},
+		},
+	}
+	if ots_err := client.CreateTable(table_meta, &OTSReservedThroughput{CapacityUnit: OTSCapacityUnit{Read: 100, Write: 100}}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	primary_key := &OTSOrderedPrimaryKey{{K: "uid", V: 1}, {K: "gid", V: "a"}}
+	update_of_attribute_columns := &OTSUpdateOfAttribute{OTSOperationType_PUT: OTSColumnsToPut{"age": 1}}
+
+	cases := []struct {
+		name    string
+		invoke  func() *OTSError
+		message string
+	}{
+		{"order of primary key", func() *OTSError {
+			_, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, &OTSOrderedPrimaryKey{{K: "gid", V: "a"}, {K: "uid", V: 1}}, &OTSAttribute{"age": 1})
+			return ots_err
+		}, "in the order of schema (uid, gid), not (gid, uid)"},
+		{"type of map primary key", func() *OTSError {
+			_, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, &OTSPrimaryKey{"uid": 1, "gid": 1}, &OTSAttribute{"age": 1})
+			return ots_err
+		}, "primary key column gid should be STRING, not INTEGER"},
+		{"type of primary key", func() *OTSError {
+			_, ots_err := client.GetRow("orderTable", &OTSOrderedPrimaryKey{{K: "uid", V: "1"}, {K: "gid", V: "a"}}, nil)
+			return ots_err
+		}, "primary key column uid should be INTEGER, not STRING"},
+		{"name of primary key", func() *OTSError {
+			_, ots_err := client.DeleteRow("orderTable", OTSCondition_IGNORE, &OTSOrderedPrimaryKey{{K: "uid", V: 1}, {K: "pid", V: "a"}})
+			return ots_err
+		}, "primary key column pid is not in the schema"},
+		{"INF_MIN", func() *OTSError {
+			_, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, &OTSOrderedPrimaryKey{{K: "uid", V: 1}, {K: "gid", V: OTSColumnType_INF_MIN}}, &OTSAttribute{"age": 1})
+			return ots_err
+		}, "INF_MIN is only allowed in GetRange"},
+		{"column name", func() *OTSError {
+			_, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, primary_key, &OTSAttribute{"1age": 1})
+			return ots_err
+		}, `invalid column name "1age"`},
+		{"oversized value", func() *OTSError {
+			_, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, primary_key, &OTSAttribute{"name": strings.Repeat("a", OTS_MAX_ATTRIBUTE_STRING_LENGTH+1)})
+			return ots_err
+		}, "the length of STRING should be at most 65536 bytes"},
+		{"type of primary key in schema", func() *OTSError {
+			table_meta := &OTSTableMeta{TableName: "boolTable", SchemaOfPrimaryKey: OTSSchemaOfPrimaryKey{{K: "ok", V: OTSColumnType_BOOLEAN}}}
+			return client.CreateTable(table_meta, &OTSReservedThroughput{CapacityUnit: OTSCapacityUnit{Read: 100, Write: 100}})
+		}, "should be INTEGER, STRING or BINARY, not BOOLEAN"},
+		{"empty update", func() *OTSError {
+			_, ots_err := client.UpdateRow("orderTable", OTSCondition_IGNORE, primary_key, &OTSUpdateOfAttribute{OTSOperationType_PUT: OTSColumnsToPut{}})
+			return ots_err
+		}, "update_of_attribute_columns should not be empty"},
+		{"too many rows", func() *OTSError {
+			put_rows := make(OTSPutRows, BATCH_WRITE_ROW_MAX_ROWS+1)
+			for i := range put_rows {
+				put_rows[i] = OTSPutRowItem{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSPrimaryKey{"gid": 1, "uid": i}}
+			}
+			_, ots_err := client.BatchWriteRow(&OTSBatchWriteRowRequest{{TableName: "myTable", PutRows: put_rows}})
+			return ots_err
+		}, "BatchWriteRow writes at most 200 rows, not 201"},
+	}
+	for _, c := range cases {
+		ots_err := c.invoke()
+		if ots_err == nil || ots_err.ClientError == nil || ots_err.ServiceError != nil || !strings.Contains(ots_err.ClientError.Message, c.message) {
+			t.Fatalf("%s: unexpected error %v", c.name, ots_err)
+		}
+	}
+	if _, err := _validate_column("uid", make([]byte, OTS_MAX_PRIMARY_KEY_BINARY_LENGTH+1), OTS_MAX_PRIMARY_KEY_STRING_LENGTH, OTS_MAX_PRIMARY_KEY_BINARY_LENGTH, false); err == nil ||
+		!strings.Contains(err.Error(), "the length of BINARY should be at most 1024 bytes") {
+		t.Fatalf("oversized primary key: unexpected error %v", err)
+	}
+	// the schema of myTable is got by DescribeTable for BatchWriteRow
+	if len(requests) != 2 || requests["CreateTable"] != 1 || requests["DescribeTable"] != 1 {
+		t.Fatalf("the invalid requests should not be sent: %v", requests)
+	}
+
+	if _, ots_err := client.PutRow("orderTable", OTSCondition_IGNORE, primary_key, &OTSAttribute{"age": 1}); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err := client.UpdateRow("orderTable", OTSCondition_IGNORE, primary_key, update_of_attribute_columns); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// map primary keys are matched by names
+	batch_list_write := &OTSBatchWriteRowRequest{
+		{
+			TableName:  "orderTable",
+			PutRows:    OTSPutRows{{Condition: OTSCondition_IGNORE, PrimaryKey: &OTSPrimaryKey{"uid": 2, "gid": "b"}, AttributeColumns: OTSAttribute{"age": 2}}},
+			DeleteRows: OTSDeleteRows{{Condition: OTSCondition_IGNORE, PrimaryKey: primary_key}},
+		},
+	}
+	if _, ots_err := client.BatchWriteRow(batch_list_write); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	batch_list_get := &OTSBatchGetRowRequest{{TableName: "orderTable", Rows: OTSPrimaryKeyRows{{"uid": 2, "gid": "b"}}}}
+	if _, ots_err := client.BatchGetRow(batch_list_get); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+
+	// the schema got by DescribeTable is cached
+	for i := 0; i < 2; i++ {
+		if _, ots_err := client.GetRow("myTable", &OTSPrimaryKey{"gid": 1, "uid": 1}, nil); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+	if requests["DescribeTable"] != 1 || validator.TableMeta("myTable") == nil {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+
+	// invalidated on OTSObjectNotExist
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		if api_name == "GetRow" {
+			return 404, "OTSObjectNotExist", "Requested table does not exist."
+		}
+		return 0, "", ""
+	}
+	if _, ots_err := client.GetRow("myTable", &OTSPrimaryKey{"gid": 1, "uid": 1}, nil); !errors.Is(ots_err, ErrObjectNotExist) {
+		t.Fatalf("error should be ErrObjectNotExist, not %v", ots_err)
+	}
+	if validator.TableMeta("myTable") != nil {
+		t.Fatal("the schema of myTable should be invalidated")
+	}
+
+	// the failure of DescribeTable is cached, the checks of schema are skipped
+	requests = map[string]int{}
+	ots_emulator.InjectError = func(api_name string) (int, string, string) {
+		requests[api_name] += 1
+		if api_name == "DescribeTable" {
+			return 403, "OTSAuthFailed", "DescribeTable is not allowed."
+		}
+		return 0, "", ""
+	}
+	for i := 0; i < 2; i++ {
+		if _, ots_err := client.GetRow("myTable", &OTSOrderedPrimaryKey{{K: "uid", V: 1}, {K: "gid", V: 1}}, nil); ots_err != nil {
+			t.Fatalf("the request should be sent without the checks of schema: %v", ots_err)
+		}
+	}
+	if requests["DescribeTable"] != 1 || requests["GetRow"] != 2 {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+	validator.Invalidate("myTable")
+	if _, ots_err := client.GetRow("myTable", &OTSPrimaryKey{"gid": 1, "uid": 1}, nil); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if requests["DescribeTable"] != 2 {
+		t.Fatalf("DescribeTable should be called again after Invalidate: %v", requests)
+	}
+	ots_emulator.InjectError = nil
+	if ots_err := client.DeleteTable("orderTable"); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if _, ots_err := client.GetRow("orderTable", primary_key, nil); !errors.Is(ots_err, ErrObjectNotExist) {
+		t.Fatalf("error should be ErrObjectNotExist, not %v", ots_err)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// range iterator for ots2
+package goots
+
+import (
+	"context"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+// OTS 限制了每次GetRange 最多返回5000 行
+const XGETRANGE_MAX_PAGE_LIMIT = 5000
+
+// XGetRange 返回的迭代器，按需逐页调用GetRange，自动处理next_start_primary_key
+//
+// 迭代器不是并发安全的，只能在一个goroutine中使用。
+type OTSRangeIterator struct {
+	client                      *OTSClient
+	ctx                         context.Context
+	table_name                  string
+	direction                   string
+	inclusive_start_primary_key OTSPrimaryKeyInterface
+	exclusive_end_primary_key   OTSPrimaryKeyInterface
+	columns_to_get              *OTSColumnsToGet
+	filter                      []OTSColumnCondition
+
+	count    int64 // 最多读取多少行，0表示没有限制
+	returned int64 // 已经返回的行数
+
+	rows  OTSRows // 当前页尚未返回的行
+	row   *OTSRow // 当前行
+	done  bool    // 范围内的数据已读完或迭代器已关闭
+	pages int     // 已经读取的页数
+
+	page_consumed *OTSCapacityUnit
+	consumed      OTSCapacityUnit
+
+	err *OTSError
+}
+
+// 说明：根据范围条件获取多行数据，返回一个迭代器，迭代器会自动跟随next_start_primary_key读取下一页，直到范围内的数据读完。
+//
+// 		``table_name``是对应的表名。
+// 		``direction``表示范围的方向，字符串格式，取值包括'FORWARD'和'BACKWARD'。
+// 		``inclusive_start_primary_key``表示范围的起始主键（在范围内）。
+// 		``exclusive_end_primary_key``表示范围的结束主键（不在范围内）。
+// 		``columns_to_get``是可选参数，表示要获取的列的名称列表，类型为``otstype.OTSColumnsToGet``；如果为nil，表示获取所有列。
+// 		``count``是可选参数，表示所有页合计最多读取多少行；如果为0，则没有限制。
+// 		``filter``是可选参数，表示服务端过滤条件，类型为``otstype.OTSColumnCondition``；不满足条件的行不会返回。
+//
+// 		返回：``OTSRangeIterator``迭代器。
+//
+// 		迭代器的方法：
+// 		``Next``读取下一行，没有更多数据或出错时返回false。
+// 		``Row``返回当前行。
+// 		``Err``返回迭代过程中的错误，正常结束时为nil。
+// 		``PageConsumed``返回最近一页消耗的CapacityUnit，``Consumed``返回所有页合计消耗的CapacityUnit。
+// 		``Close``提前结束迭代，之后不会再发出请求。
+//
+// 		示例：
+//
+// 		inclusive_start_primary_key := &OTSPrimaryKey{
+// 			"gid": 1,
+// 			"uid": OTSColumnType_INF_MIN,
+// 		}
+// 		exclusive_end_primary_key := &OTSPrimaryKey{
+// 			"gid": 4,
+// 			"uid": OTSColumnType_INF_MAX,
+// 		}
+// 		iter := ots_client.XGetRange("myTable", OTSDirection_FORWARD,
+// 			inclusive_start_primary_key, exclusive_end_primary_key, nil, 0)
+// 		defer iter.Close()
+// 		for iter.Next() {
+// 			fmt.Println(iter.Row())
+// 		}
+// 		if ots_err := iter.Err(); ots_err != nil {
+// 			fmt.Println(ots_err)
+// 		}
+// 		fmt.Println("read consumed:", iter.Consumed().GetRead())
+//
+func (o *OTSClient) XGetRange(table_name string, direction string,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
+	columns_to_get *OTSColumnsToGet,
+	count int64,
+	filter ...OTSColumnCondition) *OTSRangeIterator {
+	return o.XGetRangeWithContext(context.Background(), table_name, direction, inclusive_start_primary_key, exclusive_end_primary_key, columns_to_get, count, filter...)
+}
+
+// 说明：同XGetRange，ctx用于控制迭代过程中每一页请求（包括重试等待）的超时和取消。
+func (o *OTSClient) XGetRangeWithContext(ctx context.Context, table_name string, direction string,
+	inclusive_start_primary_key OTSPrimaryKeyInterface,
+	exclusive_end_primary_key OTSPrimaryKeyInterface,
+	columns_to_get *OTSColumnsToGet,
+	count int64,
+	filter ...OTSColumnCondition) *OTSRangeIterator {
+	iter := &OTSRangeIterator{
+		client:                      o,
+		ctx:                         ctx,
+		table_name:                  table_name,
+		direction:                   direction,
+		inclusive_start_primary_key: inclusive_start_primary_key,
+		exclusive_end_primary_key:   exclusive_end_primary_key,
+		columns_to_get:              columns_to_get,
+		filter:                      filter,
+		count:                       count,
+	}
+	if count < 0 {
+		iter.err = new(OTSError).SetClientMessage("[XGetRange] count should not be negative")
+		iter.done = true
+	}
+
+	return iter
+}
+
+// 读取下一行，没有更多数据、出错或已关闭时返回false
+func (o *OTSRangeIterator) Next() bool {
+	o.row = nil
+	if o.err != nil {
+		return false
+	}
+
+	for len(o.rows) == 0 {
+		if o.done || o._count_reached() {
+			return false
+		}
+		if !o._fetch_page() {
+			return false
+		}
+	}
+
+	o.row = o.rows[0]
+	o.rows = o.rows[1:]
+	o.returned += 1
+
+	return true
+}
+
+// 返回当前行，需要在Next返回true之后调用
+func (o *OTSRangeIterator) Row() *OTSRow {
+	return o.row
+}
+
+// 返回迭代过程中的错误，正常结束时为nil
+func (o *OTSRangeIterator) Err() *OTSError {
+	return o.err
+}
+
+// 返回最近一页消耗的CapacityUnit，尚未读取任何一页时为nil
+func (o *OTSRangeIterator) PageConsumed() *OTSCapacityUnit {
+	return o.page_consumed
+}
+
+// 返回所有页合计消耗的CapacityUnit
+func (o *OTSRangeIterator) Consumed() *OTSCapacityUnit {
+	consumed := o.consumed
+	return &consumed
+}
+
+// 返回已经读取的页数
+func (o *OTSRangeIterator) Pages() int {
+	return o.pages
+}
+
+// 提前结束迭代，之后Next返回false且不会再发出请求
+func (o *OTSRangeIterator) Close() {
+	o.done = true
+	o.rows = nil
+	o.row = nil
+}
+
+func (o *OTSRangeIterator) _count_reached() bool {
+	return o.count > 0 && o.returned >= o.count
+}
+
+func (o *OTSRangeIterator) _fetch_page() bool {
+	var limit int32
+	if o.count > 0 {
+		remaining := o.count - o.returned
+		if remaining > XGETRANGE_MAX_PAGE_LIMIT {
+			remaining = XGETRANGE_MAX_PAGE_LIMIT
+		}
+		limit = int32(remaining)
+	}
+
+	response, ots_err := o.client.GetRangeWithContext(o.ctx, o.table_name, o.direction,
+		o.inclusive_start_primary_key, o.exclusive_end_primary_key, o.columns_to_get, limit, o.filter...)
+	if ots_err != nil {
+		o.err = ots_err
+		o.done = true
+		return false
+	}
+
+	o.pages += 1
+	o.page_consumed = response.Consumed
+	if response.Consumed != nil {
+		o.consumed.Read += response.Consumed.GetRead()
+		o.consumed.Write += response.Consumed.GetWrite()
+	}
+
+	o.rows = response.GetRows()
+	next_start_primary_key := response.GetNextStartOrderedPrimaryKey()
+	if len(next_start_primary_key) == 0 {
+		o.done = true
+	} else {
+		o.inclusive_start_primary_key = &next_start_primary_key
+	}
+
+	return true
+}
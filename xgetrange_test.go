@@ -0,0 +1,91 @@
+// Copyright 2014 The GiterLab Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// test range iterator for ots2
+package goots
+
+import (
+	"sync/atomic"
+	"testing"
+
+	. "github.com/GiterLab/goots/otstype"
+)
+
+func Test_xget_range(t *testing.T) {
+	client, ots_emulator, server := newWithEmulator(t, "myTable")
+	defer server.Close()
+	ots_emulator.GetRangeMaxRows = 3
+
+	for i := 0; i < 10; i++ {
+		if _, ots_err := client.PutRow("myTable", OTSCondition_IGNORE, &OTSPrimaryKey{"gid": 1, "uid": i}, &OTSAttribute{"age": i}); ots_err != nil {
+			t.Fatal(ots_err)
+		}
+	}
+	requests := int32(0)
+	ots_emulator.SetInjectError(func(api_name string) (int, string, string) {
+		if api_name == "GetRange" {
+			atomic.AddInt32(&requests, 1)
+		}
+		return 0, "", ""
+	})
+	start := &OTSPrimaryKey{"gid": 1, "uid": OTSColumnType_INF_MIN}
+	end := &OTSPrimaryKey{"gid": 1, "uid": OTSColumnType_INF_MAX}
+
+	// follow next_start_primary_key page by page
+	iter := client.XGetRange("myTable", OTSDirection_FORWARD, start, end, nil, 0)
+	rows := 0
+	for iter.Next() {
+		if uid := iter.Row().PrimaryKeyColumns.Get("uid"); uid != int64(rows) {
+			t.Fatalf("row %d excpected uid=%d but %v", rows, rows, uid)
+		}
+		rows += 1
+	}
+	if ots_err := iter.Err(); ots_err != nil {
+		t.Fatal(ots_err)
+	}
+	if rows != 10 || iter.Pages() != 4 || atomic.LoadInt32(&requests) != 4 {
+		t.Fatalf("unexpected rows %d, pages %d, requests %d", rows, iter.Pages(), atomic.LoadInt32(&requests))
+	}
+	if iter.PageConsumed() == nil || iter.Consumed().GetRead() < iter.PageConsumed().GetRead() {
+		t.Fatalf("unexpected consumed: %v, %v", iter.PageConsumed(), iter.Consumed())
+	}
+	if iter.Next() || iter.Row() != nil {
+		t.Fatal("Next should return false after the range is read")
+	}
+
+	// count limits the rows of all pages
+	atomic.StoreInt32(&requests, 0)
+	iter = client.XGetRange("myTable", OTSDirection_BACKWARD, end, start, nil, 5)
+	rows = 0
+	for iter.Next() {
+		if uid := iter.Row().PrimaryKeyColumns.Get("uid"); uid != int64(9-rows) {
+			t.Fatalf("row %d excpected uid=%d but %v", rows, 9-rows, uid)
+		}
+		rows += 1
+	}
+	if iter.Err() != nil || rows != 5 || iter.Pages() != 2 || atomic.LoadInt32(&requests) != 2 {
+		t.Fatalf("unexpected rows %d, pages %d, requests %d, %v", rows, iter.Pages(), atomic.LoadInt32(&requests), iter.Err())
+	}
+
+	// no more requests after Close
+	atomic.StoreInt32(&requests, 0)
+	iter = client.XGetRange("myTable", OTSDirection_FORWARD, start, end, nil, 0)
+	if !iter.Next() || !iter.Next() {
+		t.Fatalf("Next should return true: %v", iter.Err())
+	}
+	iter.Close()
+	if iter.Next() || iter.Row() != nil || iter.Err() != nil || atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("Next should return false after Close: %v, %d", iter.Err(), atomic.LoadInt32(&requests))
+	}
+
+	// the error of GetRange stops the iteration
+	iter = client.XGetRange("notExistTable", OTSDirection_FORWARD, start, end, nil, 0)
+	if iter.Next() || iter.Err() == nil || iter.Err().ServiceError == nil {
+		t.Fatalf("error should be returned: %v", iter.Err())
+	}
+	iter = client.XGetRange("myTable", OTSDirection_FORWARD, start, end, nil, -1)
+	if iter.Next() || iter.Err() == nil || iter.Err().ClientError == nil {
+		t.Fatalf("negative count should be a client error: %v", iter.Err())
+	}
+}